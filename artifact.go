@@ -0,0 +1,263 @@
+package goethereumhelper
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// ErrNotArtifact is returned when parsing a JSON file that is not a contract artifact, like the
+// Hardhat .dbg.json and build-info files
+var ErrNotArtifact = errors.New("not a contract artifact")
+
+// LinkReference is the position of a library address in a bytecode, in bytes
+type LinkReference struct {
+	Start  int `json:"start"`
+	Length int `json:"length"`
+}
+
+// LinkReferences lists the library placeholders of a bytecode by source file and library name
+type LinkReferences map[string]map[string][]LinkReference
+
+// Artifact is a compiled contract read from a Hardhat or Foundry artifact
+type Artifact struct {
+	ContractName           string
+	SourceName             string
+	ABI                    abi.ABI
+	Bytecode               string // Creation bytecode in hex, with placeholders for the libraries not linked yet
+	DeployedBytecode       string // Runtime bytecode in hex, with placeholders for the libraries not linked yet
+	LinkReferences         LinkReferences
+	DeployedLinkReferences LinkReferences
+
+	// Libraries are the addresses linked by DeployArtifact, keyed by library name or by "source:name"
+	Libraries map[string]common.Address
+}
+
+// hardhatArtifact is the format of the Hardhat artifacts/**/*.json files
+type hardhatArtifact struct {
+	ContractName           string         `json:"contractName"`
+	SourceName             string         `json:"sourceName"`
+	Bytecode               string         `json:"bytecode"`
+	DeployedBytecode       string         `json:"deployedBytecode"`
+	LinkReferences         LinkReferences `json:"linkReferences"`
+	DeployedLinkReferences LinkReferences `json:"deployedLinkReferences"`
+}
+
+// foundryBytecode is the bytecode object of the Foundry out/**/*.json files
+type foundryBytecode struct {
+	Object         string         `json:"object"`
+	LinkReferences LinkReferences `json:"linkReferences"`
+}
+
+// foundryArtifact is the format of the Foundry out/**/*.json files
+type foundryArtifact struct {
+	Bytecode         foundryBytecode `json:"bytecode"`
+	DeployedBytecode foundryBytecode `json:"deployedBytecode"`
+	Metadata         json.RawMessage `json:"metadata"`
+}
+
+// LoadArtifact reads a Hardhat or Foundry contract artifact
+func LoadArtifact(path string) (artifact *Artifact, err error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	artifact, err = ParseArtifact(content)
+	if err != nil {
+		err = fmt.Errorf("could not read artifact %s: %w", path, err)
+		return
+	}
+	if artifact.ContractName == "" {
+		// Foundry names the artifact files after the contract: out/Token.sol/Token.json
+		artifact.ContractName = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		if artifact.SourceName == "" {
+			artifact.SourceName = filepath.Base(filepath.Dir(path))
+		}
+	}
+	return
+}
+
+// ParseArtifact parses the content of a Hardhat or Foundry contract artifact
+func ParseArtifact(content []byte) (artifact *Artifact, err error) {
+	var fields struct {
+		ABI      json.RawMessage `json:"abi"`
+		Bytecode json.RawMessage `json:"bytecode"`
+	}
+	err = json.Unmarshal(content, &fields)
+	if err != nil {
+		return
+	}
+	if len(fields.ABI) == 0 || len(fields.Bytecode) == 0 {
+		return nil, ErrNotArtifact
+	}
+	artifact = new(Artifact)
+	err = json.Unmarshal(fields.ABI, &artifact.ABI)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ABI: %w", err)
+	}
+
+	if bytes.HasPrefix(bytes.TrimSpace(fields.Bytecode), []byte("{")) {
+		var foundry foundryArtifact
+		err = json.Unmarshal(content, &foundry)
+		if err != nil {
+			return nil, err
+		}
+		artifact.Bytecode = foundry.Bytecode.Object
+		artifact.LinkReferences = foundry.Bytecode.LinkReferences
+		artifact.DeployedBytecode = foundry.DeployedBytecode.Object
+		artifact.DeployedLinkReferences = foundry.DeployedBytecode.LinkReferences
+		artifact.SourceName, artifact.ContractName = foundryCompilationTarget(foundry.Metadata)
+		return
+	}
+
+	var hardhat hardhatArtifact
+	err = json.Unmarshal(content, &hardhat)
+	if err != nil {
+		return nil, err
+	}
+	artifact.ContractName = hardhat.ContractName
+	artifact.SourceName = hardhat.SourceName
+	artifact.Bytecode = hardhat.Bytecode
+	artifact.DeployedBytecode = hardhat.DeployedBytecode
+	artifact.LinkReferences = hardhat.LinkReferences
+	artifact.DeployedLinkReferences = hardhat.DeployedLinkReferences
+	return
+}
+
+// foundryCompilationTarget returns the source and contract names in the metadata of a Foundry artifact,
+// which is an object in recent versions and a JSON string in older ones
+func foundryCompilationTarget(metadata json.RawMessage) (sourceName, contractName string) {
+	var encoded string
+	if json.Unmarshal(metadata, &encoded) == nil {
+		metadata = json.RawMessage(encoded)
+	}
+	var parsed struct {
+		Settings struct {
+			CompilationTarget map[string]string `json:"compilationTarget"`
+		} `json:"settings"`
+	}
+	if json.Unmarshal(metadata, &parsed) != nil {
+		return
+	}
+	for source, name := range parsed.Settings.CompilationTarget {
+		return source, name
+	}
+	return
+}
+
+// LoadArtifacts reads every contract artifact under dir, like Hardhat artifacts/ or Foundry out/,
+// skipping the other JSON files. Artifacts are keyed by "source:name" and, when the name is not
+// used by another contract, by contract name.
+func LoadArtifacts(dir string) (artifacts map[string]*Artifact, err error) {
+	artifacts = make(map[string]*Artifact)
+	names := make(map[string]int)
+	err = filepath.WalkDir(dir, func(path string, entry fs.DirEntry, errWalk error) error {
+		if errWalk != nil {
+			return errWalk
+		}
+		if entry.IsDir() || filepath.Ext(path) != ".json" || strings.HasSuffix(path, ".dbg.json") {
+			return nil
+		}
+		artifact, errLoad := LoadArtifact(path)
+		if errors.Is(errLoad, ErrNotArtifact) {
+			return nil
+		}
+		if errLoad != nil {
+			return errLoad
+		}
+		artifacts[artifact.SourceName+":"+artifact.ContractName] = artifact
+		names[artifact.ContractName]++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	unique := make([]*Artifact, 0, len(artifacts))
+	for _, artifact := range artifacts {
+		if names[artifact.ContractName] == 1 {
+			unique = append(unique, artifact)
+		}
+	}
+	for _, artifact := range unique {
+		artifacts[artifact.ContractName] = artifact
+	}
+	return
+}
+
+// LinkedBytecode returns the creation bytecode with the Libraries addresses in place of the placeholders
+func (a *Artifact) LinkedBytecode() ([]byte, error) {
+	return linkBytecode(a.Bytecode, a.LinkReferences, a.Libraries)
+}
+
+// LinkedDeployedBytecode returns the runtime bytecode with the Libraries addresses in place of the placeholders
+func (a *Artifact) LinkedDeployedBytecode() ([]byte, error) {
+	return linkBytecode(a.DeployedBytecode, a.DeployedLinkReferences, a.Libraries)
+}
+
+// linkBytecode writes the library addresses at the link references of a hex bytecode
+func linkBytecode(bytecode string, references LinkReferences, libraries map[string]common.Address) (code []byte, err error) {
+	linked := []byte(strings.TrimPrefix(bytecode, "0x"))
+	var missing []string
+	for source, sourceLibraries := range references {
+		for name, positions := range sourceLibraries {
+			address, found := libraries[source+":"+name]
+			if !found {
+				address, found = libraries[name]
+			}
+			if !found {
+				missing = append(missing, source+":"+name)
+				continue
+			}
+			addressHex := []byte(strings.TrimPrefix(strings.ToLower(address.Hex()), "0x"))
+			for _, position := range positions {
+				start, end := position.Start*2, (position.Start+position.Length)*2
+				if position.Length != common.AddressLength || end > len(linked) {
+					return nil, fmt.Errorf("invalid link reference of library %s at %d", name, position.Start)
+				}
+				copy(linked[start:end], addressHex)
+			}
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, fmt.Errorf("missing address of libraries %s", strings.Join(missing, ", "))
+	}
+	if bytes.Contains(linked, []byte("__")) {
+		return nil, errors.New("bytecode has library placeholders without link references")
+	}
+	return common.FromHex(string(linked)), nil
+}
+
+// DeployArtifact deploys artifact, ABI encoding the constructor args and linking the Libraries, and
+// returns the address and a bound contract to call it. It works with any bind.ContractBackend: with the
+// simulated backend Commit afterwards, with a live client wait for the deployment with bind.WaitDeployed.
+func DeployArtifact(ctx context.Context, backend bind.ContractBackend, transactor *bind.TransactOpts, artifact *Artifact, args ...interface{}) (address common.Address, tx *types.Transaction, contract *bind.BoundContract, err error) {
+	bytecode, err := artifact.LinkedBytecode()
+	if err != nil {
+		err = fmt.Errorf("could not link %s: %w", artifact.ContractName, err)
+		return
+	}
+	if len(bytecode) == 0 {
+		err = fmt.Errorf("%s has no bytecode, it is either abstract or an interface", artifact.ContractName)
+		return
+	}
+	opts := *transactor
+	opts.Context = ctx
+	address, tx, contract, err = bind.DeployContract(&opts, artifact.ABI, bytecode, backend, args...)
+	if err != nil {
+		err = fmt.Errorf("could not deploy %s: %w", artifact.ContractName, err)
+	}
+	return
+}
@@ -0,0 +1,123 @@
+package goethereumhelper
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/big"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestLoadArtifact(t *testing.T) {
+	tests := []struct {
+		path         string
+		sourceName   string
+		contractName string
+		linked       bool
+	}{
+		{"hardhat/contracts/Storer.sol/Storer.json", "contracts/Storer.sol", "Storer", false},
+		{"foundry/Storer.sol/Storer.json", "src/Storer.sol", "Storer", false},
+		{"foundry/Linked.sol/Linked.json", "src/Linked.sol", "Linked", true},
+	}
+	for _, test := range tests {
+		artifact, err := LoadArtifact(filepath.Join("testdata", "artifacts", test.path))
+		if err != nil {
+			t.Fatalf("%s: %v", test.path, err)
+		}
+		if artifact.SourceName != test.sourceName || artifact.ContractName != test.contractName {
+			t.Fatalf("%s: read %s:%s", test.path, artifact.SourceName, artifact.ContractName)
+		}
+		if len(artifact.ABI.Methods) != 1 || artifact.Bytecode == "" || artifact.DeployedBytecode == "" {
+			t.Fatalf("%s: incomplete artifact %+v", test.path, artifact)
+		}
+		if linked := len(artifact.LinkReferences) > 0 && len(artifact.DeployedLinkReferences) > 0; linked != test.linked {
+			t.Fatalf("%s: link references %v and %v", test.path, artifact.LinkReferences, artifact.DeployedLinkReferences)
+		}
+	}
+
+	for _, path := range []string{"hardhat/contracts/Storer.sol/Storer.dbg.json", "hardhat/build-info/7f3a.json"} {
+		if _, err := LoadArtifact(filepath.Join("testdata", "artifacts", path)); !errors.Is(err, ErrNotArtifact) {
+			t.Fatalf("%s: expected ErrNotArtifact, got %v", path, err)
+		}
+	}
+}
+
+func TestLoadArtifacts(t *testing.T) {
+	artifacts, err := LoadArtifacts(filepath.Join("testdata", "artifacts"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"contracts/Storer.sol:Storer", "src/Storer.sol:Storer", "src/Linked.sol:Linked", "Linked"} {
+		if artifacts[key] == nil {
+			t.Fatalf("artifact %s not loaded", key)
+		}
+	}
+	// Two contracts are named Storer, so neither is keyed by its name alone
+	if artifacts["Storer"] != nil {
+		t.Fatal("ambiguous contract name used as key")
+	}
+	if len(artifacts) != 4 {
+		t.Fatalf("loaded %d artifacts", len(artifacts))
+	}
+}
+
+func TestDeployArtifact(t *testing.T) {
+	chain, err := NewTestChain()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Close()
+	ctx := context.Background()
+	artifacts, err := LoadArtifacts(filepath.Join("testdata", "artifacts"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	storerAddress, _, storer, err := DeployArtifact(ctx, chain, chain.Accounts[0], artifacts["contracts/Storer.sol:Storer"], big.NewInt(42))
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain.Commit()
+	var values []interface{}
+	if err := storer.Call(&bind.CallOpts{Context: ctx}, &values, "value"); err != nil {
+		t.Fatal(err)
+	}
+	if value := values[0].(*big.Int); value.Int64() != 42 {
+		t.Fatalf("value is %s, expected the constructor argument 42", value)
+	}
+
+	linked := artifacts["Linked"]
+	if _, _, _, err := DeployArtifact(ctx, chain, chain.Accounts[0], linked); err == nil || !strings.Contains(err.Error(), "src/MathLib.sol:MathLib") {
+		t.Fatalf("expected the missing library error, got %v", err)
+	}
+	// The Storer stands in for the library, only its address is linked
+	linked.Libraries = map[string]common.Address{"MathLib": storerAddress}
+	linkedAddress, _, contract, err := DeployArtifact(ctx, chain, chain.Accounts[0], linked)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain.Commit()
+	values = nil
+	if err := contract.Call(&bind.CallOpts{Context: ctx}, &values, "lib"); err != nil {
+		t.Fatal(err)
+	}
+	if lib := values[0].(common.Address); lib != storerAddress {
+		t.Fatalf("linked library is %s, expected %s", lib.Hex(), storerAddress.Hex())
+	}
+	code, err := chain.CodeAt(ctx, linkedAddress, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deployed, err := linked.LinkedDeployedBytecode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(code, deployed) {
+		t.Fatalf("deployed code %x differs from the linked runtime bytecode %x", code, deployed)
+	}
+}
@@ -0,0 +1,311 @@
+// Package chaintest has assertions for contract tests running on the simulated backend, like the
+// TestChain of goethereumhelper or the backend of GetMockBlockchain.
+//
+// Example:
+//
+//	chain, _ := goethereumhelper.NewTestChain()
+//	// ... deploy token ...
+//	tx, err := token.Transfer(chain.Accounts[0], to, big.NewInt(10))
+//	if err != nil {
+//		t.Fatal(err)
+//	}
+//	receipt := chaintest.Mined(t, chain.Backend(), tx)
+//	chaintest.ExpectEmitted(t, receipt, tokenABI, "Transfer", map[string]interface{}{"to": to, "value": 10})
+//	chaintest.ExpectRevert(t, func() error {
+//		_, err := token.Transfer(chain.Accounts[1], to, big.NewInt(1e18))
+//		return err
+//	}, "ERC20: transfer amount exceeds balance")
+package chaintest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	goethereumhelper "github.com/jeffprestes/goethereumhelper"
+)
+
+var (
+	errorSelector = crypto.Keccak256([]byte("Error(string)"))[:4]
+	panicSelector = crypto.Keccak256([]byte("Panic(uint256)"))[:4]
+)
+
+// Mined commits the pending block and returns the receipt of tx, failing the test when tx was not mined
+func Mined(t testing.TB, backend *backends.SimulatedBackend, tx *types.Transaction) *types.Receipt {
+	t.Helper()
+	backend.Commit()
+	receipt, err := backend.TransactionReceipt(context.Background(), tx.Hash())
+	if err != nil {
+		t.Fatalf("transaction %s was not mined: %v", tx.Hash().Hex(), err)
+	}
+	return receipt
+}
+
+// ExpectSuccess checks the transaction of receipt succeeded
+func ExpectSuccess(t testing.TB, receipt *types.Receipt) {
+	t.Helper()
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		t.Errorf("transaction %s failed, expected success", receipt.TxHash.Hex())
+	}
+}
+
+// ExpectFailure checks the transaction of receipt failed, which happens when it is sent with a gas limit
+// set so that the reverting transaction is mined instead of failing the gas estimation
+func ExpectFailure(t testing.TB, receipt *types.Receipt) {
+	t.Helper()
+	if receipt.Status != types.ReceiptStatusFailed {
+		t.Errorf("transaction %s succeeded, expected failure", receipt.TxHash.Hex())
+	}
+}
+
+// RevertData returns the data returned by a reverted call or gas estimation, as found in the errors of
+// the simulated backend and of RPC clients
+func RevertData(err error) (data []byte, ok bool) {
+	var dataError interface{ ErrorData() interface{} }
+	if !errors.As(err, &dataError) {
+		return nil, false
+	}
+	switch errorData := dataError.ErrorData().(type) {
+	case string:
+		data, errDecode := hexutil.Decode(errorData)
+		return data, errDecode == nil
+	case []byte:
+		return errorData, true
+	}
+	return nil, false
+}
+
+// ExpectRevert runs fn, which must return the error of a contract call or transaction, and checks it
+// reverted with reason. An empty reason accepts any revert.
+func ExpectRevert(t testing.TB, fn func() error, reason string) {
+	t.Helper()
+	err := fn()
+	if err == nil {
+		t.Errorf("expected revert %q, got no error", reason)
+		return
+	}
+	data, ok := RevertData(err)
+	if !ok {
+		// Some nodes only return the reason in the message
+		if !strings.Contains(err.Error(), "revert") || !strings.Contains(err.Error(), reason) {
+			t.Errorf("expected revert %q, got error: %v", reason, err)
+		}
+		return
+	}
+	if reason == "" {
+		return
+	}
+	got, errUnpack := abi.UnpackRevert(data)
+	if errUnpack != nil {
+		t.Errorf("expected revert %q, got revert data %s", reason, hexutil.Encode(data))
+		return
+	}
+	if got != reason {
+		t.Errorf("expected revert %q, got revert %q", reason, got)
+	}
+}
+
+// ExpectPanic runs fn and checks it reverted with a Solidity panic code, like 0x11 for arithmetic overflow
+// or 0x32 for an out-of-bounds array access
+func ExpectPanic(t testing.TB, fn func() error, code uint64) {
+	t.Helper()
+	err := fn()
+	if err == nil {
+		t.Errorf("expected panic 0x%x, got no error", code)
+		return
+	}
+	data, ok := RevertData(err)
+	if !ok {
+		t.Errorf("expected panic 0x%x, got error: %v", code, err)
+		return
+	}
+	if len(data) != 36 || !bytes.Equal(data[:4], panicSelector) {
+		t.Errorf("expected panic 0x%x, got revert %s", code, describeRevert(abi.ABI{}, data))
+		return
+	}
+	if got := new(big.Int).SetBytes(data[4:]); !got.IsUint64() || got.Uint64() != code {
+		t.Errorf("expected panic 0x%x, got panic 0x%x", code, got)
+	}
+}
+
+// ExpectCustomError runs fn and checks it reverted with the custom error name of contractABI.
+// When args are given they are compared with the error arguments, in order.
+func ExpectCustomError(t testing.TB, fn func() error, contractABI abi.ABI, name string, args ...interface{}) {
+	t.Helper()
+	customError, found := contractABI.Errors[name]
+	if !found {
+		t.Fatalf("error %s is not in the ABI", name)
+	}
+	err := fn()
+	if err == nil {
+		t.Errorf("expected error %s, got no error", customError.Sig)
+		return
+	}
+	data, ok := RevertData(err)
+	if !ok || len(data) < 4 {
+		t.Errorf("expected error %s, got error: %v", customError.Sig, err)
+		return
+	}
+	if !bytes.Equal(data[:4], customError.ID[:4]) {
+		t.Errorf("expected error %s, got revert %s", customError.Sig, describeRevert(contractABI, data))
+		return
+	}
+	if len(args) == 0 {
+		return
+	}
+	values, errUnpack := customError.Inputs.Unpack(data[4:])
+	if errUnpack != nil {
+		t.Errorf("could not decode error %s: %v", customError.Sig, errUnpack)
+		return
+	}
+	if len(values) != len(args) {
+		t.Errorf("error %s has %d arguments, expected %d", customError.Sig, len(values), len(args))
+		return
+	}
+	for i, arg := range args {
+		if !sameABIValue(values[i], arg) {
+			t.Errorf("error %s argument %s is %v, expected %v", customError.Sig, customError.Inputs[i].Name, values[i], arg)
+		}
+	}
+}
+
+// describeRevert returns a readable form of revert data for failure messages
+func describeRevert(contractABI abi.ABI, data []byte) string {
+	if len(data) < 4 {
+		return hexutil.Encode(data)
+	}
+	if bytes.Equal(data[:4], errorSelector) {
+		if reason, err := abi.UnpackRevert(data); err == nil {
+			return fmt.Sprintf("%q", reason)
+		}
+	}
+	if bytes.Equal(data[:4], panicSelector) && len(data) == 36 {
+		return fmt.Sprintf("panic 0x%x", new(big.Int).SetBytes(data[4:]))
+	}
+	for _, customError := range contractABI.Errors {
+		if bytes.Equal(data[:4], customError.ID[:4]) {
+			return customError.Sig
+		}
+	}
+	return hexutil.Encode(data)
+}
+
+// ExpectEmitted checks receipt has at least one event of contractABI whose arguments include args.
+// Arguments are compared by value, so numbers may be given as int or *big.Int and addresses as
+// common.Address. Indexed arguments of dynamic types are compared with their topic hash.
+func ExpectEmitted(t testing.TB, receipt *types.Receipt, contractABI abi.ABI, event string, args map[string]interface{}) {
+	t.Helper()
+	abiEvent, found := contractABI.Events[event]
+	if !found {
+		t.Fatalf("event %s is not in the ABI", event)
+	}
+	emitted := decodeEvents(receipt, abiEvent)
+	for _, decoded := range emitted {
+		if matchArgs(decoded, args) {
+			return
+		}
+	}
+	if len(emitted) == 0 {
+		t.Errorf("event %s was not emitted by transaction %s", abiEvent.Sig, receipt.TxHash.Hex())
+		return
+	}
+	t.Errorf("event %s was emitted by transaction %s with arguments %v, expected %v", abiEvent.Sig, receipt.TxHash.Hex(), emitted, args)
+}
+
+// ExpectNotEmitted checks receipt has no event of contractABI named event
+func ExpectNotEmitted(t testing.TB, receipt *types.Receipt, contractABI abi.ABI, event string) {
+	t.Helper()
+	abiEvent, found := contractABI.Events[event]
+	if !found {
+		t.Fatalf("event %s is not in the ABI", event)
+	}
+	if emitted := decodeEvents(receipt, abiEvent); len(emitted) > 0 {
+		t.Errorf("event %s was emitted %d times by transaction %s", abiEvent.Sig, len(emitted), receipt.TxHash.Hex())
+	}
+}
+
+// decodeEvents returns the arguments of the logs of receipt matching abiEvent
+func decodeEvents(receipt *types.Receipt, abiEvent abi.Event) (events []map[string]interface{}) {
+	var indexed abi.Arguments
+	for _, input := range abiEvent.Inputs {
+		if input.Indexed {
+			indexed = append(indexed, input)
+		}
+	}
+	for _, log := range receipt.Logs {
+		if len(log.Topics) != len(indexed)+1 || log.Topics[0] != abiEvent.ID {
+			continue
+		}
+		args := make(map[string]interface{})
+		if err := abiEvent.Inputs.UnpackIntoMap(args, log.Data); err != nil {
+			continue
+		}
+		if err := abi.ParseTopicsIntoMap(args, indexed, log.Topics[1:]); err != nil {
+			continue
+		}
+		events = append(events, args)
+	}
+	return
+}
+
+func matchArgs(decoded, expected map[string]interface{}) bool {
+	for name, value := range expected {
+		got, found := decoded[name]
+		if !found || !sameABIValue(got, value) {
+			return false
+		}
+	}
+	return true
+}
+
+// sameABIValue compares a decoded ABI value with an expected one after normalizing both
+func sameABIValue(got, expected interface{}) bool {
+	return reflect.DeepEqual(goethereumhelper.NormalizeABIValue(got), goethereumhelper.NormalizeABIValue(expected))
+}
+
+// ExpectBalanceChange runs fn and checks the balance of address changed by delta wei, which is negative
+// for decreases. Gas paid by address is part of the change. fn must mine the transactions it sends.
+func ExpectBalanceChange(t testing.TB, backend ethereum.ChainStateReader, address common.Address, delta *big.Int, fn func()) {
+	t.Helper()
+	before, err := backend.BalanceAt(context.Background(), address, nil)
+	if err != nil {
+		t.Fatalf("could not read balance of %s: %v", address.Hex(), err)
+	}
+	fn()
+	after, err := backend.BalanceAt(context.Background(), address, nil)
+	if err != nil {
+		t.Fatalf("could not read balance of %s: %v", address.Hex(), err)
+	}
+	if change := new(big.Int).Sub(after, before); change.Cmp(delta) != 0 {
+		t.Errorf("balance of %s changed by %s wei, expected %s", address.Hex(), change, delta)
+	}
+}
+
+// ExpectGasUsedBelow checks the transaction of receipt used at most limit gas
+func ExpectGasUsedBelow(t testing.TB, receipt *types.Receipt, limit uint64) {
+	t.Helper()
+	if receipt.GasUsed > limit {
+		t.Errorf("transaction %s used %d gas, expected at most %d", receipt.TxHash.Hex(), receipt.GasUsed, limit)
+	}
+}
+
+// ExpectGasUsedBetween checks the transaction of receipt used between min and max gas, inclusive, to catch
+// gas regressions and improvements alike
+func ExpectGasUsedBetween(t testing.TB, receipt *types.Receipt, min, max uint64) {
+	t.Helper()
+	if receipt.GasUsed < min || receipt.GasUsed > max {
+		t.Errorf("transaction %s used %d gas, expected between %d and %d", receipt.TxHash.Hex(), receipt.GasUsed, min, max)
+	}
+}
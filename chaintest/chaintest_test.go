@@ -0,0 +1,103 @@
+package chaintest
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	goethereumhelper "github.com/jeffprestes/goethereumhelper"
+	"github.com/jeffprestes/goethereumhelper/testcontracts"
+)
+
+// recorder is a testing.TB collecting the failures of an assertion instead of failing the test
+type recorder struct {
+	testing.TB
+	failures []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.failures = append(r.failures, fmt.Sprintf(format, args...))
+}
+
+func (r *recorder) Fatalf(format string, args ...interface{}) {
+	r.failures = append(r.failures, fmt.Sprintf(format, args...))
+}
+
+func TestAssertionsOnTestChain(t *testing.T) {
+	chain, err := goethereumhelper.NewTestChain()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Close()
+	ctx := context.Background()
+	owner, holder := chain.Accounts[0], chain.Account(1)
+	_, token, err := testcontracts.DeploySimulatedERC20(ctx, chain.Backend(), owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokenABI, err := abi.JSON(strings.NewReader(testcontracts.ERC20MetaData.ABI))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tx, err := token.Mint(owner, owner.From, big.NewInt(100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ExpectSuccess(t, Mined(t, chain.Backend(), tx))
+
+	tx, err = token.Transfer(owner, holder, big.NewInt(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	receipt := Mined(t, chain.Backend(), tx)
+	ExpectSuccess(t, receipt)
+	ExpectEmitted(t, receipt, tokenABI, "Transfer", map[string]interface{}{"from": owner.From, "to": holder, "value": 10})
+	ExpectNotEmitted(t, receipt, tokenABI, "Approval")
+	ExpectGasUsedBetween(t, receipt, 21000, 100000)
+
+	ExpectRevert(t, func() error {
+		_, err := token.Transfer(chain.Accounts[1], owner.From, big.NewInt(1000))
+		return err
+	}, "ERC20: transfer amount exceeds balance")
+
+	value := big.NewInt(12345)
+	ExpectBalanceChange(t, chain, holder, value, func() {
+		nonce, err := chain.PendingNonceAt(ctx, owner.From)
+		if err != nil {
+			t.Fatal(err)
+		}
+		head, err := chain.HeaderByNumber(ctx, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		signedTx, err := owner.Signer(owner.From, types.NewTx(&types.DynamicFeeTx{
+			ChainID: chain.Config().ChainID, Nonce: nonce, Gas: 21000, GasFeeCap: new(big.Int).Mul(head.BaseFee, big.NewInt(2)), GasTipCap: big.NewInt(1), To: &holder, Value: value,
+		}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := chain.SendTransaction(ctx, signedTx); err != nil {
+			t.Fatal(err)
+		}
+		ExpectSuccess(t, Mined(t, chain.Backend(), signedTx))
+	})
+
+	// The assertions report the mismatches
+	failing := &recorder{TB: t}
+	ExpectEmitted(failing, receipt, tokenABI, "Transfer", map[string]interface{}{"to": common.HexToAddress("0x01")})
+	ExpectNotEmitted(failing, receipt, tokenABI, "Transfer")
+	ExpectRevert(failing, func() error { return nil }, "")
+	ExpectFailure(failing, receipt)
+	ExpectGasUsedBelow(failing, receipt, 21000)
+	if len(failing.failures) != 5 {
+		t.Fatalf("expected 5 failures, got %q", failing.failures)
+	}
+}
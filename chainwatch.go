@@ -0,0 +1,226 @@
+package goethereumhelper
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// DefaultPollInterval is the polling interval used by the watchers when the endpoint does not support subscriptions
+const DefaultPollInterval = 4 * time.Second
+
+// MaxHeadBackfill is the maximum number of skipped headers WatchHeads fetches before delivering a new head
+const MaxHeadBackfill = 256
+
+// WatchHeads delivers new block headers to heads. Over websocket or IPC endpoints it uses eth_subscribe("newHeads");
+// over HTTP, where notifications are not supported, it polls the latest header every pollInterval (DefaultPollInterval when zero).
+// Whenever the chain advances more than one block between two notifications the skipped headers are fetched
+// and delivered in order before the new head, so consumers see every block number. They are fetched following
+// the parent hashes of the new head, so they belong to its chain even when a reorg happened meanwhile.
+// After a long disconnect only the MaxHeadBackfill headers preceding the new head are delivered.
+// The watcher stops when ctx is cancelled or the returned subscription is unsubscribed; errors are reported
+// through the subscription Err channel.
+func WatchHeads(ctx context.Context, client ethereum.ChainReader, heads chan<- *types.Header, pollInterval time.Duration) (sub ethereum.Subscription, err error) {
+	if pollInterval <= 0 {
+		pollInterval = DefaultPollInterval
+	}
+	newHeads := make(chan *types.Header)
+	headSub, err := client.SubscribeNewHead(ctx, newHeads)
+	polling := errors.Is(err, rpc.ErrNotificationsUnsupported)
+	if err != nil && !polling {
+		log.Printf("[WatchHeads] Failure subscribing to new heads: %+v", err)
+		return
+	}
+	if polling {
+		headSub, err = nil, nil
+	}
+
+	sub = event.NewSubscription(func(quit <-chan struct{}) error {
+		var headErr <-chan error
+		if headSub != nil {
+			defer headSub.Unsubscribe()
+			headErr = headSub.Err()
+		}
+		var ticker *time.Ticker
+		var tick <-chan time.Time
+		if polling {
+			ticker = time.NewTicker(pollInterval)
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+
+		var last *types.Header
+		deliver := func(header *types.Header) error {
+			if last != nil && header.Number.Uint64() > last.Number.Uint64()+1 {
+				// Walk back from the new head, so the skipped headers chain to it
+				skipped := header.Number.Uint64() - last.Number.Uint64() - 1
+				if skipped > MaxHeadBackfill {
+					log.Printf("[WatchHeads] %d blocks skipped, delivering the last %d", skipped, MaxHeadBackfill)
+					skipped = MaxHeadBackfill
+				}
+				skippedHeaders := make([]*types.Header, skipped)
+				parentHash := header.ParentHash
+				for i := len(skippedHeaders) - 1; i >= 0; i-- {
+					parent, err := client.HeaderByHash(ctx, parentHash)
+					if err != nil {
+						return err
+					}
+					skippedHeaders[i] = parent
+					parentHash = parent.ParentHash
+				}
+				for _, skipped := range skippedHeaders {
+					select {
+					case heads <- skipped:
+					case <-ctx.Done():
+						return ctx.Err()
+					case <-quit:
+						return nil
+					}
+				}
+			}
+			last = header
+			select {
+			case heads <- header:
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-quit:
+				return nil
+			}
+			return nil
+		}
+
+		poll := func() error {
+			header, err := client.HeaderByNumber(ctx, nil)
+			if err != nil {
+				return err
+			}
+			if last != nil && header.Hash() == last.Hash() {
+				return nil
+			}
+			return deliver(header)
+		}
+		// Deliver the current head right away instead of a poll interval later
+		if polling {
+			if err := poll(); err != nil {
+				return err
+			}
+		}
+
+		for {
+			select {
+			case header := <-newHeads:
+				if err := deliver(header); err != nil {
+					return err
+				}
+			case <-tick:
+				if err := poll(); err != nil {
+					return err
+				}
+			case err := <-headErr:
+				return err
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-quit:
+				return nil
+			}
+		}
+	})
+	return
+}
+
+// WatchPendingTransactions delivers the hashes of transactions entering the node transaction pool to hashes.
+// Over websocket or IPC endpoints it uses eth_subscribe("newPendingTransactions"); over HTTP it installs a
+// pending transaction filter and polls it every pollInterval (DefaultPollInterval when zero).
+// The watcher has the same lifecycle as WatchHeads.
+func WatchPendingTransactions(ctx context.Context, client *rpc.Client, hashes chan<- common.Hash, pollInterval time.Duration) (sub ethereum.Subscription, err error) {
+	if pollInterval <= 0 {
+		pollInterval = DefaultPollInterval
+	}
+	pending := make(chan common.Hash)
+	pendingSub, err := client.EthSubscribe(ctx, pending, "newPendingTransactions")
+	if err == nil {
+		sub = event.NewSubscription(func(quit <-chan struct{}) error {
+			defer pendingSub.Unsubscribe()
+			for {
+				select {
+				case hash := <-pending:
+					select {
+					case hashes <- hash:
+					case <-ctx.Done():
+						return ctx.Err()
+					case <-quit:
+						return nil
+					}
+				case err := <-pendingSub.Err():
+					return err
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-quit:
+					return nil
+				}
+			}
+		})
+		return
+	}
+	if !errors.Is(err, rpc.ErrNotificationsUnsupported) {
+		log.Printf("[WatchPendingTransactions] Failure subscribing to pending transactions: %+v", err)
+		return
+	}
+
+	var filterID string
+	err = client.CallContext(ctx, &filterID, "eth_newPendingTransactionFilter")
+	if err != nil {
+		log.Printf("[WatchPendingTransactions] Failure installing pending transaction filter: %+v", err)
+		return
+	}
+	sub = event.NewSubscription(func(quit <-chan struct{}) error {
+		defer func() {
+			var uninstalled bool
+			if err := client.Call(&uninstalled, "eth_uninstallFilter", filterID); err != nil {
+				log.Printf("[WatchPendingTransactions] Failure uninstalling filter %s: %+v", filterID, err)
+			}
+		}()
+		poll := func() error {
+			var changes []common.Hash
+			if err := client.CallContext(ctx, &changes, "eth_getFilterChanges", filterID); err != nil {
+				return err
+			}
+			for _, hash := range changes {
+				select {
+				case hashes <- hash:
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-quit:
+					return nil
+				}
+			}
+			return nil
+		}
+		// Deliver the transactions entering the pool since the filter was installed right away, like WatchHeads
+		if err := poll(); err != nil {
+			return err
+		}
+		ticker := time.NewTicker(pollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := poll(); err != nil {
+					return err
+				}
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-quit:
+				return nil
+			}
+		}
+	})
+	return
+}
@@ -0,0 +1,163 @@
+package goethereumhelper
+
+import (
+	"context"
+	"math/big"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// pollingClient is a TestChain behind an endpoint without notifications, like HTTP
+type pollingClient struct {
+	*TestChain
+}
+
+func (c pollingClient) SubscribeNewHead(ctx context.Context, ch chan<- *types.Header) (ethereum.Subscription, error) {
+	return nil, rpc.ErrNotificationsUnsupported
+}
+
+// pausedClient is a pollingClient whose head polls wait while mu is held
+type pausedClient struct {
+	pollingClient
+	mu *sync.Mutex
+}
+
+func (c pausedClient) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.pollingClient.HeaderByNumber(ctx, number)
+}
+
+func TestWatchHeadsPolling(t *testing.T) {
+	chain, err := NewTestChain()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Close()
+	chain.MineBlocks(3)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	heads := make(chan *types.Header)
+	sub, err := WatchHeads(ctx, pollingClient{chain}, heads, 500*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Unsubscribe()
+	// The current head is delivered before the first poll interval elapses
+	last := waitHead(t, heads, 250*time.Millisecond)
+	if last.Number.Uint64() != 3 {
+		t.Fatalf("first head is block %d, expected 3", last.Number.Uint64())
+	}
+
+	chain.MineBlocks(4)
+	for number := uint64(4); number <= 7; number++ {
+		header := waitHead(t, heads, 5*time.Second)
+		if header.Number.Uint64() != number || header.ParentHash != last.Hash() {
+			t.Fatalf("got block %d with parent %s, expected block %d with parent %s", header.Number.Uint64(), header.ParentHash.Hex(), number, last.Hash().Hex())
+		}
+		last = header
+	}
+}
+
+func waitHead(t *testing.T, heads chan *types.Header, timeout time.Duration) (header *types.Header) {
+	t.Helper()
+	select {
+	case header = <-heads:
+	case <-time.After(timeout):
+		t.Fatalf("no head delivered within %s", timeout)
+	}
+	return
+}
+
+func TestWatchHeadsBackfillLimit(t *testing.T) {
+	chain, err := NewTestChain()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	heads := make(chan *types.Header)
+	var paused sync.Mutex
+	sub, err := WatchHeads(ctx, pausedClient{pollingClient{chain}, &paused}, heads, 100*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Unsubscribe()
+	if first := waitHead(t, heads, 5*time.Second); first.Number.Uint64() != 0 {
+		t.Fatalf("first head is block %d, expected 0", first.Number.Uint64())
+	}
+
+	// A disconnect long enough to skip more blocks than the backfill limit
+	paused.Lock()
+	head := chain.MineBlocks(MaxHeadBackfill + 50)
+	paused.Unlock()
+	last := waitHead(t, heads, 5*time.Second)
+	if expected := uint64(50); last.Number.Uint64() != expected {
+		t.Fatalf("first backfilled header is block %d, expected %d", last.Number.Uint64(), expected)
+	}
+	for last.Hash() != head {
+		header := waitHead(t, heads, 5*time.Second)
+		if header.ParentHash != last.Hash() {
+			t.Fatalf("block %d does not follow block %d", header.Number.Uint64(), last.Number.Uint64())
+		}
+		last = header
+	}
+}
+
+func TestWatchPendingTransactionsPolling(t *testing.T) {
+	chain, err := NewTestChain()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Close()
+	devnet, err := NewDevnet(chain, DevnetConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer devnet.Close()
+	server := httptest.NewServer(devnet.Handler())
+	defer server.Close()
+	client, err := rpc.DialHTTP(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hashes := make(chan common.Hash)
+	sub, err := WatchPendingTransactions(ctx, client, hashes, 100*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Unsubscribe()
+
+	tx, err := chain.Accounts[0].Signer(chain.Account(0), types.NewTransaction(0, chain.Account(1), big.NewInt(1), 21000, big.NewInt(2e9), nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ethclient.NewClient(client).SendTransaction(ctx, tx); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case hash := <-hashes:
+		if hash != tx.Hash() {
+			t.Fatalf("got transaction %s, expected %s", hash.Hex(), tx.Hash().Hex())
+		}
+	case err := <-sub.Err():
+		t.Fatal(err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no pending transaction delivered")
+	}
+}
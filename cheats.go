@@ -0,0 +1,313 @@
+package goethereumhelper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/consensus/misc"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// ErrNotImpersonated is returned when sending a transaction from an address not impersonated
+var ErrNotImpersonated = errors.New("address is not impersonated")
+
+// The cheat methods change the chain state directly, like the anvil_* and hardhat_* methods of those
+// development nodes. The simulated backend has no way to edit the state of a block, so every change is
+// written in a new empty block on top of the chain, after mining the pending transactions.
+//
+// Example, testing a contract against a whale account:
+//
+//	chain, _ := NewTestChain()
+//	whale := common.HexToAddress("0x...")
+//	_ = chain.SetBalance(whale, big.NewInt(params.Ether))
+//	auth := chain.Impersonate(whale)
+//	// ... call the contract bindings with auth and chain as backend ...
+//	chain.StopImpersonating(whale)
+
+// SetBalance sets the balance of address to balance wei
+func (c *TestChain) SetBalance(address common.Address, balance *big.Int) error {
+	return c.writeStateBlock(func(statedb *state.StateDB) {
+		c.touched.add(address)
+		statedb.SetBalance(address, balance)
+	})
+}
+
+// SetCode replaces the code of address, keeping its storage
+func (c *TestChain) SetCode(address common.Address, code []byte) error {
+	return c.writeStateBlock(func(statedb *state.StateDB) {
+		c.touched.add(address)
+		statedb.SetCode(address, code)
+	})
+}
+
+// SetStorageAt writes value in the storage slot of address
+func (c *TestChain) SetStorageAt(address common.Address, slot, value common.Hash) error {
+	return c.writeStateBlock(func(statedb *state.StateDB) {
+		c.touched.add(address, slot)
+		statedb.SetState(address, slot, value)
+	})
+}
+
+// SetNonce sets the nonce of address
+func (c *TestChain) SetNonce(address common.Address, nonce uint64) error {
+	return c.writeStateBlock(func(statedb *state.StateDB) {
+		c.touched.add(address)
+		statedb.SetNonce(address, nonce)
+	})
+}
+
+// Impersonate allows sending transactions from address without its private key. The returned transactor
+// only works with the TestChain as backend, not with the embedded simulated backend, and each transaction
+// sent with it is mined right away in its own block.
+func (c *TestChain) Impersonate(address common.Address) *bind.TransactOpts {
+	c.mu.Lock()
+	c.impersonated[address] = true
+	c.mu.Unlock()
+	return &bind.TransactOpts{
+		From:    address,
+		Signer:  c.impersonationSigner,
+		Context: context.Background(),
+	}
+}
+
+// StopImpersonating stops accepting transactions from address sent by Impersonate transactors
+func (c *TestChain) StopImpersonating(address common.Address) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.impersonated, address)
+}
+
+// Mine mines blocks blocks, interval apart from each other. With interval zero the blocks get the default
+// spacing of the simulated backend. The first block includes the pending transactions, which also keep the
+// default spacing because the backend cannot change the time of a block already holding transactions.
+func (c *TestChain) Mine(blocks int, interval time.Duration) (head common.Hash, err error) {
+	if interval == 0 {
+		return c.MineBlocks(blocks), nil
+	}
+	if interval < time.Second {
+		return head, fmt.Errorf("interval %s is below one second", interval)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i := 0; i < blocks; i++ {
+		// Simulated blocks are mined 10 seconds after their parent plus the adjustment
+		errAdjust := c.SimulatedBackend.AdjustTime(interval - 10*time.Second)
+		if errAdjust != nil && i > 0 {
+			return head, errAdjust
+		}
+		head = c.SimulatedBackend.Commit()
+	}
+	return
+}
+
+// SendTransaction sends tx to the pending block. Transactions made by Impersonate transactors are
+// executed as sent by the impersonated address and mined right away.
+func (c *TestChain) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	c.mu.Lock()
+	from, impersonated := c.impersonatedTxs[tx.Hash()]
+	c.mu.Unlock()
+	if !impersonated {
+		return c.SimulatedBackend.SendTransaction(ctx, tx)
+	}
+	return c.writeBlock(func(header *types.Header, statedb *state.StateDB) (txs types.Transactions, receipts types.Receipts, err error) {
+		receipt, err := c.applyImpersonated(header, statedb, from, tx)
+		if err != nil {
+			return
+		}
+		return types.Transactions{tx}, types.Receipts{receipt}, nil
+	})
+}
+
+// TransactionReceipt returns the receipt of a mined transaction, fixing the contract address of
+// contracts deployed by impersonated addresses
+func (c *TestChain) TransactionReceipt(ctx context.Context, hash common.Hash) (receipt *types.Receipt, err error) {
+	receipt, err = c.SimulatedBackend.TransactionReceipt(ctx, hash)
+	if err != nil {
+		return
+	}
+	c.mu.Lock()
+	from, impersonated := c.impersonatedTxs[hash]
+	c.mu.Unlock()
+	if impersonated {
+		tx, _, errTx := c.SimulatedBackend.TransactionByHash(ctx, hash)
+		if errTx != nil {
+			return nil, errTx
+		}
+		if tx.To() == nil {
+			receipt.ContractAddress = crypto.CreateAddress(from, tx.Nonce())
+		}
+	}
+	return
+}
+
+// Sender returns the address that sent tx, including transactions sent from impersonated addresses
+func (c *TestChain) Sender(tx *types.Transaction) (common.Address, error) {
+	c.mu.Lock()
+	from, impersonated := c.impersonatedTxs[tx.Hash()]
+	c.mu.Unlock()
+	if impersonated {
+		return from, nil
+	}
+	return types.Sender(types.LatestSignerForChainID(c.config.ChainID), tx)
+}
+
+// isImpersonated tells whether tx was made by an Impersonate transactor
+func (c *TestChain) isImpersonated(tx *types.Transaction) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, impersonated := c.impersonatedTxs[tx.Hash()]
+	return impersonated
+}
+
+// impersonationSigner registers tx as sent by address instead of signing it. The tx gets a fake signature
+// derived from address, so the same transaction sent by two impersonated addresses has two hashes.
+func (c *TestChain) impersonationSigner(address common.Address, tx *types.Transaction) (signedTx *types.Transaction, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.impersonated[address] {
+		return nil, fmt.Errorf("%w: %s", ErrNotImpersonated, address.Hex())
+	}
+	signature := make([]byte, crypto.SignatureLength)
+	copy(signature, crypto.Keccak256(address.Bytes(), tx.Hash().Bytes()))
+	signature[63] = 1
+	signedTx, err = tx.WithSignature(types.LatestSignerForChainID(c.config.ChainID), signature)
+	if err != nil {
+		return nil, fmt.Errorf("could not mark the transaction as impersonated: %w", err)
+	}
+	c.impersonatedTxs[signedTx.Hash()] = address
+	return
+}
+
+// applyImpersonated executes tx as sent by from on statedb, like core.ApplyTransaction does for signed ones.
+// Account checks are skipped, so contracts can be impersonated as well, but the nonce must still match.
+func (c *TestChain) applyImpersonated(header *types.Header, statedb *state.StateDB, from common.Address, tx *types.Transaction) (receipt *types.Receipt, err error) {
+	if nonce := statedb.GetNonce(from); tx.Nonce() != nonce {
+		return nil, fmt.Errorf("invalid transaction nonce: got %d, want %d", tx.Nonce(), nonce)
+	}
+	msg := impersonatedMessage(from, tx, header.BaseFee)
+	statedb.SetTxContext(tx.Hash(), 0)
+	blockContext := core.NewEVMBlockContext(header, c.SimulatedBackend.Blockchain(), nil)
+	evm := vm.NewEVM(blockContext, core.NewEVMTxContext(msg), statedb, c.config, vm.Config{})
+	result, err := core.ApplyMessage(evm, msg, new(core.GasPool).AddGas(header.GasLimit))
+	if err != nil {
+		return nil, fmt.Errorf("invalid transaction: %w", err)
+	}
+	statedb.Finalise(true)
+	header.GasUsed = result.UsedGas
+
+	receipt = &types.Receipt{
+		Type:              tx.Type(),
+		CumulativeGasUsed: result.UsedGas,
+		Status:            types.ReceiptStatusSuccessful,
+		TxHash:            tx.Hash(),
+		GasUsed:           result.UsedGas,
+		EffectiveGasPrice: msg.GasPrice,
+	}
+	if result.Failed() {
+		receipt.Status = types.ReceiptStatusFailed
+	}
+	if tx.To() == nil {
+		receipt.ContractAddress = crypto.CreateAddress(from, tx.Nonce())
+	}
+	receipt.Logs = statedb.GetLogs(tx.Hash(), header.Number.Uint64(), common.Hash{})
+	receipt.Bloom = types.CreateBloom(types.Receipts{receipt})
+	return
+}
+
+// impersonatedMessage returns the message executing tx as sent by from
+func impersonatedMessage(from common.Address, tx *types.Transaction, baseFee *big.Int) (msg *core.Message) {
+	msg = &core.Message{
+		To:                tx.To(),
+		From:              from,
+		Nonce:             tx.Nonce(),
+		Value:             tx.Value(),
+		GasLimit:          tx.Gas(),
+		GasPrice:          new(big.Int).Set(tx.GasPrice()),
+		GasFeeCap:         new(big.Int).Set(tx.GasFeeCap()),
+		GasTipCap:         new(big.Int).Set(tx.GasTipCap()),
+		Data:              tx.Data(),
+		AccessList:        tx.AccessList(),
+		SkipAccountChecks: true,
+	}
+	if baseFee != nil {
+		msg.GasPrice = math.BigMin(new(big.Int).Add(msg.GasTipCap, baseFee), msg.GasFeeCap)
+	}
+	return
+}
+
+// writeStateBlock writes a block without transactions whose state is the latest one changed by modify
+func (c *TestChain) writeStateBlock(modify func(statedb *state.StateDB)) error {
+	return c.writeBlock(func(header *types.Header, statedb *state.StateDB) (types.Transactions, types.Receipts, error) {
+		modify(statedb)
+		return nil, nil, nil
+	})
+}
+
+// writeBlock builds a block on top of the chain with the state and transactions produced by build and
+// makes it the new head. The pending transactions are mined first, as they would be lost otherwise.
+func (c *TestChain) writeBlock(build func(header *types.Header, statedb *state.StateDB) (types.Transactions, types.Receipts, error)) (err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// AdjustTime only fails when the pending block holds transactions
+	if c.SimulatedBackend.AdjustTime(0) != nil {
+		c.SimulatedBackend.Commit()
+	}
+
+	blockchain := c.SimulatedBackend.Blockchain()
+	parent := blockchain.CurrentBlock()
+	statedb, err := blockchain.StateAt(parent.Root)
+	if err != nil {
+		return fmt.Errorf("could not load the state of block %d: %w", parent.Number.Uint64(), err)
+	}
+	header := &types.Header{
+		ParentHash: parent.Hash(),
+		Number:     new(big.Int).Add(parent.Number, common.Big1),
+		GasLimit:   core.CalcGasLimit(parent.GasLimit, parent.GasLimit),
+		Time:       parent.Time + 1,
+	}
+	header.Difficulty = ethash.CalcDifficulty(c.config, header.Time, parent)
+	if c.config.IsLondon(header.Number) {
+		header.BaseFee = misc.CalcBaseFee(c.config, parent)
+	}
+
+	txs, receipts, err := build(header, statedb)
+	if err != nil {
+		return
+	}
+	header.Root = statedb.IntermediateRoot(c.config.IsEIP158(header.Number))
+	block := types.NewBlock(header, txs, nil, receipts, trie.NewStackTrie(nil))
+
+	var logs []*types.Log
+	for i, receipt := range receipts {
+		receipt.BlockHash = block.Hash()
+		receipt.BlockNumber = block.Number()
+		receipt.TransactionIndex = uint(i)
+		for _, log := range receipt.Logs {
+			log.BlockHash = block.Hash()
+			logs = append(logs, log)
+		}
+	}
+	_, err = blockchain.WriteBlockAndSetHead(block, receipts, logs, statedb, true)
+	if err != nil {
+		return fmt.Errorf("could not write block %d: %w", block.NumberU64(), err)
+	}
+	// The simulated backend builds the next blocks reading the state straight from the database
+	err = statedb.Database().TrieDB().Commit(block.Root(), false)
+	if err != nil {
+		return
+	}
+	c.SimulatedBackend.Rollback()
+	return
+}
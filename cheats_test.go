@@ -0,0 +1,238 @@
+package goethereumhelper
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func TestCheatsSetState(t *testing.T) {
+	chain, err := NewTestChain()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Close()
+	ctx := context.Background()
+	address := common.HexToAddress("0x1234")
+	slot, value := common.HexToHash("0x01"), common.HexToHash("0xbeef")
+
+	// A pending transaction is mined before the state is changed, not lost
+	pending := sendTestTx(t, chain, 0, address, big.NewInt(1))
+	if err := chain.SetBalance(address, big.NewInt(params.Ether)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := chain.TransactionReceipt(ctx, pending.Hash()); err != nil {
+		t.Fatalf("pending transaction not mined: %v", err)
+	}
+	if err := chain.SetCode(address, []byte{0x60, 0x00}); err != nil {
+		t.Fatal(err)
+	}
+	if err := chain.SetStorageAt(address, slot, value); err != nil {
+		t.Fatal(err)
+	}
+	if err := chain.SetNonce(address, 7); err != nil {
+		t.Fatal(err)
+	}
+	// Changing the code keeps the storage
+	if err := chain.SetCode(address, []byte{0x60, 0x01}); err != nil {
+		t.Fatal(err)
+	}
+
+	balance, err := chain.BalanceAt(ctx, address, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balance.Cmp(big.NewInt(params.Ether)) != 0 {
+		t.Fatalf("balance is %s", balance)
+	}
+	code, err := chain.CodeAt(ctx, address, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if common.Bytes2Hex(code) != "6001" {
+		t.Fatalf("code is %x", code)
+	}
+	stored, err := chain.StorageAt(ctx, address, slot, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if common.BytesToHash(stored) != value {
+		t.Fatalf("storage is %x", stored)
+	}
+	nonce, err := chain.NonceAt(ctx, address, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nonce != 7 {
+		t.Fatalf("nonce is %d", nonce)
+	}
+	// Every change went in a block of its own
+	if head := chain.Blockchain().CurrentBlock().Number.Uint64(); head != 6 {
+		t.Fatalf("head is block %d, expected 6", head)
+	}
+}
+
+func TestCheatsImpersonate(t *testing.T) {
+	chain, err := NewTestChain()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Close()
+	ctx := context.Background()
+	first, second := common.HexToAddress("0xa1"), common.HexToAddress("0xa2")
+	recipient := common.HexToAddress("0xb1")
+	for _, whale := range []common.Address{first, second} {
+		if err := chain.SetBalance(whale, big.NewInt(params.Ether)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Both whales send the very same transaction
+	unsigned := types.NewTx(&types.DynamicFeeTx{
+		Nonce:     0,
+		GasTipCap: big.NewInt(params.GWei),
+		GasFeeCap: big.NewInt(100 * params.GWei),
+		Gas:       21000,
+		To:        &recipient,
+		Value:     big.NewInt(5),
+	})
+	var sent []*types.Transaction
+	for _, whale := range []common.Address{first, second} {
+		tx, err := chain.Impersonate(whale).Signer(whale, unsigned)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := chain.SendTransaction(ctx, tx); err != nil {
+			t.Fatal(err)
+		}
+		sent = append(sent, tx)
+	}
+	if sent[0].Hash() == sent[1].Hash() {
+		t.Fatal("transactions of two impersonated addresses have the same hash")
+	}
+	for i, whale := range []common.Address{first, second} {
+		sender, err := chain.Sender(sent[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sender != whale {
+			t.Fatalf("sender of transaction %d is %s, expected %s", i, sender.Hex(), whale.Hex())
+		}
+		receipt, err := chain.TransactionReceipt(ctx, sent[i].Hash())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if receipt.Status != types.ReceiptStatusSuccessful {
+			t.Fatalf("transaction %d failed", i)
+		}
+		nonce, err := chain.NonceAt(ctx, whale, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if nonce != 1 {
+			t.Fatalf("nonce of %s is %d", whale.Hex(), nonce)
+		}
+	}
+	balance, err := chain.BalanceAt(ctx, recipient, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balance.Int64() != 10 {
+		t.Fatalf("recipient balance is %s, expected 10", balance)
+	}
+
+	// Contracts deployed by an impersonated address get its address in the receipt
+	deploy, err := chain.Impersonate(first).Signer(first, types.NewTx(&types.DynamicFeeTx{
+		Nonce:     1,
+		GasTipCap: big.NewInt(params.GWei),
+		GasFeeCap: big.NewInt(100 * params.GWei),
+		Gas:       100000,
+		Data:      common.FromHex("60006000f3"),
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := chain.SendTransaction(ctx, deploy); err != nil {
+		t.Fatal(err)
+	}
+	receipt, err := chain.TransactionReceipt(ctx, deploy.Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := crypto.CreateAddress(first, 1); receipt.ContractAddress != expected {
+		t.Fatalf("contract address is %s, expected %s", receipt.ContractAddress.Hex(), expected.Hex())
+	}
+
+	chain.StopImpersonating(first)
+	unsigned = types.NewTx(&types.DynamicFeeTx{Nonce: 2, GasFeeCap: big.NewInt(100 * params.GWei), Gas: 21000, To: &recipient})
+	if _, err := chain.Impersonate(second).Signer(first, unsigned); !errors.Is(err, ErrNotImpersonated) {
+		t.Fatalf("expected ErrNotImpersonated, got %v", err)
+	}
+}
+
+func TestCheatsMine(t *testing.T) {
+	chain, err := NewTestChain()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Close()
+	ctx := context.Background()
+
+	if _, err := chain.Mine(1, 500*time.Millisecond); err == nil {
+		t.Fatal("expected an error mining with an interval below one second")
+	}
+	start, err := chain.HeaderByNumber(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	head, err := chain.Mine(3, 30*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	last, err := chain.HeaderByHash(ctx, head)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last.Number.Uint64() != start.Number.Uint64()+3 || last.Time != start.Time+90 {
+		t.Fatalf("mined up to block %d at %d, expected block %d at %d", last.Number, last.Time, start.Number.Uint64()+3, start.Time+90)
+	}
+
+	// The first block holds the pending transaction and keeps the default spacing
+	tx := sendTestTx(t, chain, 0, common.HexToAddress("0x01"), nil)
+	head, err = chain.Mine(2, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	receipt, err := chain.TransactionReceipt(ctx, tx.Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if receipt.BlockNumber.Uint64() != last.Number.Uint64()+1 {
+		t.Fatalf("pending transaction mined in block %d", receipt.BlockNumber)
+	}
+	withTx, err := chain.HeaderByNumber(ctx, receipt.BlockNumber)
+	if err != nil {
+		t.Fatal(err)
+	}
+	final, err := chain.HeaderByHash(ctx, head)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if withTx.Time != last.Time+10 || final.Time != withTx.Time+60 {
+		t.Fatalf("blocks mined at %d and %d after %d", withTx.Time, final.Time, last.Time)
+	}
+
+	if _, err := chain.Mine(2, 0); err != nil {
+		t.Fatal(err)
+	}
+	if number := chain.Blockchain().CurrentBlock().Number.Uint64(); number != final.Number.Uint64()+2 {
+		t.Fatalf("head is block %d", number)
+	}
+}
@@ -0,0 +1,180 @@
+package goethereumhelper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+// ClefSigner is a TxSigner for an account held by a separate signing process speaking the Clef external
+// API, so the keys stay out of the service. Clef may ask a human or a rule file to approve every request,
+// so the calls can take a while.
+//
+// Example:
+//
+//	signer, err := DialClefSigner(ctx, "/home/user/.clef/clef.ipc", common.HexToAddress("0x..."))
+//	txOpts := NewSignerTransactor(signer, chainID)
+//	tx, err := SendEtherUsingSigner(client, signer, to, value)
+type ClefSigner struct {
+	client  *rpc.Client
+	address common.Address
+}
+
+// clefSignTxResult is the result of account_signTransaction
+type clefSignTxResult struct {
+	Raw hexutil.Bytes      `json:"raw"`
+	Tx  *types.Transaction `json:"tx"`
+}
+
+// DialClefSigner connects to the signer at endpoint, an HTTP or WebSocket URL or the path of an IPC socket,
+// and signs with account. The first account listed by the signer is used when account is the zero address.
+func DialClefSigner(ctx context.Context, endpoint string, account common.Address) (signer *ClefSigner, err error) {
+	client, err := rpc.DialContext(ctx, endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to signer at %s: %w", endpoint, err)
+	}
+	signer, err = NewClefSigner(ctx, client, account)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	return
+}
+
+// NewClefSigner returns a signer for account using client connected to the signer. The first account listed
+// by the signer is used when account is the zero address.
+func NewClefSigner(ctx context.Context, client *rpc.Client, account common.Address) (signer *ClefSigner, err error) {
+	signer = &ClefSigner{client: client}
+	listed, err := signer.Accounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, address := range listed {
+		if account == (common.Address{}) || address == account {
+			signer.address = address
+			return
+		}
+	}
+	if account == (common.Address{}) {
+		return nil, errors.New("signer has no account")
+	}
+	return nil, fmt.Errorf("signer has no account %s", account.Hex())
+}
+
+// Accounts returns the accounts the signer allows to use, calling account_list
+func (s *ClefSigner) Accounts(ctx context.Context) (addresses []common.Address, err error) {
+	err = s.client.CallContext(ctx, &addresses, "account_list")
+	if err != nil {
+		err = fmt.Errorf("could not list signer accounts: %w", err)
+	}
+	return
+}
+
+// Address returns the address of the account signing
+func (s *ClefSigner) Address() common.Address {
+	return s.address
+}
+
+// mixedcaseAddress returns the address as the signer expects it. It must be a pointer to be encoded in JSON.
+func (s *ClefSigner) mixedcaseAddress() *common.MixedcaseAddress {
+	address := common.NewMixedcaseAddress(s.address)
+	return &address
+}
+
+// Close closes the connection to the signer
+func (s *ClefSigner) Close() {
+	s.client.Close()
+}
+
+// SignTx asks the signer to sign tx for chainID with account_signTransaction
+func (s *ClefSigner) SignTx(tx *types.Transaction, chainID *big.Int) (signedTx *types.Transaction, err error) {
+	return s.SignTxContext(context.Background(), tx, chainID)
+}
+
+// SignTxContext is like SignTx, giving up when ctx is done
+func (s *ClefSigner) SignTxContext(ctx context.Context, tx *types.Transaction, chainID *big.Int) (signedTx *types.Transaction, err error) {
+	data := hexutil.Bytes(tx.Data())
+	args := apitypes.SendTxArgs{
+		From:    common.NewMixedcaseAddress(s.address),
+		Gas:     hexutil.Uint64(tx.Gas()),
+		Value:   hexutil.Big(*tx.Value()),
+		Nonce:   hexutil.Uint64(tx.Nonce()),
+		Data:    &data,
+		ChainID: (*hexutil.Big)(chainID),
+	}
+	if tx.To() != nil {
+		to := common.NewMixedcaseAddress(*tx.To())
+		args.To = &to
+	}
+	switch tx.Type() {
+	case types.LegacyTxType:
+		args.GasPrice = (*hexutil.Big)(tx.GasPrice())
+	case types.AccessListTxType:
+		args.GasPrice = (*hexutil.Big)(tx.GasPrice())
+		accessList := tx.AccessList()
+		args.AccessList = &accessList
+	case types.DynamicFeeTxType:
+		args.MaxFeePerGas = (*hexutil.Big)(tx.GasFeeCap())
+		args.MaxPriorityFeePerGas = (*hexutil.Big)(tx.GasTipCap())
+		accessList := tx.AccessList()
+		args.AccessList = &accessList
+	default:
+		return nil, fmt.Errorf("unsupported transaction type %d", tx.Type())
+	}
+	var result clefSignTxResult
+	err = s.client.CallContext(ctx, &result, "account_signTransaction", &args)
+	if err != nil {
+		return nil, fmt.Errorf("signer refused transaction: %w", err)
+	}
+	if result.Tx == nil {
+		return nil, errors.New("signer returned no transaction")
+	}
+	// The signer could sign something else than requested, or with another account
+	txSigner := types.LatestSignerForChainID(chainID)
+	if txSigner.Hash(result.Tx) != txSigner.Hash(tx) {
+		return nil, errors.New("signer returned a different transaction")
+	}
+	sender, err := types.Sender(txSigner, result.Tx)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature from signer: %w", err)
+	}
+	if sender != s.address {
+		return nil, fmt.Errorf("signer signed with %s instead of %s", sender.Hex(), s.address.Hex())
+	}
+	return result.Tx, nil
+}
+
+// SignData asks the signer to sign data of mimeType with account_signData. The signer supports
+// accounts.MimetypeTextPlain for personal messages, accounts.MimetypeTypedData for EIP-712 typed data as
+// JSON and accounts.MimetypeDataWithValidator. The signature has V 27 or 28.
+func (s *ClefSigner) SignData(ctx context.Context, mimeType string, data []byte) (signature []byte, err error) {
+	var result hexutil.Bytes
+	err = s.client.CallContext(ctx, &result, "account_signData", mimeType, s.mixedcaseAddress(), hexutil.Bytes(data))
+	if err != nil {
+		return nil, fmt.Errorf("signer refused data: %w", err)
+	}
+	return EthereumSignature(result)
+}
+
+// SignPersonalMessage asks the signer to sign message like personal_sign
+func (s *ClefSigner) SignPersonalMessage(ctx context.Context, message []byte) (signature []byte, err error) {
+	return s.SignData(ctx, accounts.MimetypeTextPlain, message)
+}
+
+// SignTypedData asks the signer to sign EIP-712 typedData with account_signTypedData
+func (s *ClefSigner) SignTypedData(ctx context.Context, typedData apitypes.TypedData) (signature []byte, err error) {
+	var result hexutil.Bytes
+	err = s.client.CallContext(ctx, &result, "account_signTypedData", s.mixedcaseAddress(), typedData)
+	if err != nil {
+		return nil, fmt.Errorf("signer refused typed data: %w", err)
+	}
+	return EthereumSignature(result)
+}
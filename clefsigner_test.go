@@ -0,0 +1,162 @@
+package goethereumhelper
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+	"net"
+	"net/http/httptest"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+// standInSigner implements the account namespace of the Clef external API, approving every request
+type standInSigner struct {
+	key *ecdsa.PrivateKey
+}
+
+func (s *standInSigner) List() []common.Address {
+	return []common.Address{crypto.PubkeyToAddress(s.key.PublicKey)}
+}
+
+func (s *standInSigner) SignTransaction(args apitypes.SendTxArgs, methodSelector *string) (*clefSignTxResult, error) {
+	if args.From.Address() != crypto.PubkeyToAddress(s.key.PublicKey) {
+		return nil, errors.New("unknown account")
+	}
+	signedTx, err := types.SignTx(args.ToTransaction(), types.LatestSignerForChainID(args.ChainID.ToInt()), s.key)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := signedTx.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return &clefSignTxResult{Raw: raw, Tx: signedTx}, nil
+}
+
+// SignData signs only text/plain data, with V 0 or 1 to check the client converts it
+func (s *standInSigner) SignData(contentType string, address common.MixedcaseAddress, data hexutil.Bytes) (hexutil.Bytes, error) {
+	if contentType != accounts.MimetypeTextPlain {
+		return nil, errors.New("unsupported content type")
+	}
+	return crypto.Sign(accounts.TextHash(data), s.key)
+}
+
+func (s *standInSigner) SignTypedData(address common.MixedcaseAddress, typedData apitypes.TypedData) (hexutil.Bytes, error) {
+	return SignTypedData(s.key, typedData)
+}
+
+// startStandInSigner serves a standInSigner for key over HTTP, and IPC when supported, returning the endpoints
+func startStandInSigner(t *testing.T, key *ecdsa.PrivateKey) (endpoints []string) {
+	server := rpc.NewServer()
+	if err := server.RegisterName("account", &standInSigner{key: key}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.Stop)
+	httpServer := httptest.NewServer(server)
+	t.Cleanup(httpServer.Close)
+	endpoints = append(endpoints, httpServer.URL)
+	if runtime.GOOS != "windows" {
+		ipcPath := filepath.Join(t.TempDir(), "clef.ipc")
+		listener, err := net.Listen("unix", ipcPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		go server.ServeListener(listener)
+		t.Cleanup(func() { listener.Close() })
+		endpoints = append(endpoints, ipcPath)
+	}
+	return
+}
+
+func TestClefSigner(t *testing.T) {
+	chain, err := NewTestChain()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	chainID, err := chain.ChainID(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	to := chain.Account(2)
+
+	for _, endpoint := range startStandInSigner(t, chain.Keys[0]) {
+		if _, err := DialClefSigner(ctx, endpoint, chain.Account(1)); err == nil {
+			t.Fatalf("%s: expected an error for an account the signer does not have", endpoint)
+		}
+		signer, err := DialClefSigner(ctx, endpoint, common.Address{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if signer.Address() != chain.Account(0) {
+			t.Fatalf("%s: signer address is %s, expected %s", endpoint, signer.Address().Hex(), chain.Account(0).Hex())
+		}
+
+		head, err := chain.HeaderByNumber(ctx, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		nonce, err := chain.PendingNonceAt(ctx, signer.Address())
+		if err != nil {
+			t.Fatal(err)
+		}
+		gasPrice := new(big.Int).Mul(head.BaseFee, big.NewInt(2))
+		txOpts := NewSignerTransactor(signer, chainID)
+		dynamicTx, err := txOpts.Signer(txOpts.From, types.NewTx(&types.DynamicFeeTx{
+			ChainID: chainID, Nonce: nonce, Gas: 21000, GasFeeCap: gasPrice, GasTipCap: big.NewInt(1), To: &to, Value: big.NewInt(5),
+		}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		legacyTx, err := signer.SignTx(types.NewTx(&types.LegacyTx{
+			Nonce: nonce + 1, Gas: 21000, GasPrice: gasPrice, To: &to, Value: big.NewInt(5),
+		}), chainID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, signedTx := range []*types.Transaction{dynamicTx, legacyTx} {
+			if err := chain.SendTransaction(ctx, signedTx); err != nil {
+				t.Fatalf("%s: %v", endpoint, err)
+			}
+		}
+		chain.Commit()
+
+		signature, err := signer.SignPersonalMessage(ctx, []byte("hello"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if signature[64] != 27 && signature[64] != 28 {
+			t.Fatalf("%s: personal signature has V %d", endpoint, signature[64])
+		}
+		if valid, err := VerifyPersonalMessage([]byte("hello"), signature, signer.Address()); err != nil || !valid {
+			t.Fatalf("%s: personal signature not verified: %v", endpoint, err)
+		}
+		if _, err := signer.SignData(ctx, accounts.MimetypeClique, []byte("header")); err == nil {
+			t.Fatalf("%s: expected the refusal of the signer", endpoint)
+		}
+
+		typedData, err := ParseTypedData([]byte(mailTypedData))
+		if err != nil {
+			t.Fatal(err)
+		}
+		signature, err = signer.SignTypedData(ctx, typedData)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if valid, err := VerifyTypedData(typedData, signature, signer.Address()); err != nil || !valid {
+			t.Fatalf("%s: typed data signature not verified: %v", endpoint, err)
+		}
+		signer.Close()
+	}
+}
@@ -0,0 +1,91 @@
+// Command devnet serves an in-memory Ethereum chain over HTTP and websocket JSON-RPC, so ethers.js scripts,
+// Foundry cast or wallets can be pointed at the same simulated backend used by the Go tests.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	goethereumhelper "github.com/jeffprestes/goethereumhelper"
+)
+
+func main() {
+	host := flag.String("host", "127.0.0.1", "interface to listen on")
+	port := flag.Int("port", 8545, "port to listen on")
+	accounts := flag.Int("accounts", goethereumhelper.DefaultTestChainAccounts, "number of funded accounts")
+	balance := flag.Int64("balance", 1000, "balance of each account, in ether")
+	seed := flag.String("seed", "", "derive the account keys from this seed instead of generating random ones")
+	gasLimit := flag.Uint64("gas-limit", goethereumhelper.DefaultTestChainGasLimit, "block gas limit")
+	blockTime := flag.Duration("block-time", 0, "mine a block at this interval, e.g. 2s")
+	noMining := flag.Bool("no-mining", false, "do not mine a block for every transaction")
+	flag.Parse()
+
+	options := []goethereumhelper.TestChainOption{
+		goethereumhelper.WithAccounts(*accounts, new(big.Int).Mul(big.NewInt(*balance), big.NewInt(params.Ether))),
+		goethereumhelper.WithGasLimit(*gasLimit),
+	}
+	if *seed != "" {
+		options = append(options, goethereumhelper.WithDeterministicKeys(*seed))
+	}
+	chain, err := goethereumhelper.NewTestChain(options...)
+	if err != nil {
+		log.Fatalln("Could not create the chain:", err.Error())
+	}
+	defer chain.Close()
+
+	devnet, err := goethereumhelper.NewDevnet(chain, goethereumhelper.DevnetConfig{
+		Automine:  !*noMining,
+		BlockTime: *blockTime,
+	})
+	if err != nil {
+		log.Fatalln("Could not create the devnet:", err.Error())
+	}
+	devnet.Start()
+	defer devnet.Close()
+
+	printAccounts(chain, *balance)
+
+	address := net.JoinHostPort(*host, fmt.Sprint(*port))
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		log.Fatalln("Could not listen on", address, err.Error())
+	}
+	server := &http.Server{Handler: devnet.Handler(), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		errServe := server.Serve(listener)
+		if errServe != nil && errServe != http.ErrServerClosed {
+			log.Fatalln("Could not serve JSON-RPC:", errServe.Error())
+		}
+	}()
+
+	fmt.Printf("Chain ID\n==================\n%s\n\n", chain.Config().ChainID)
+	fmt.Printf("Listening on http://%s and ws://%s\n", address, address)
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+	<-interrupt
+	server.Close()
+}
+
+func printAccounts(chain *goethereumhelper.TestChain, balance int64) {
+	fmt.Printf("\nAvailable Accounts\n==================\n")
+	for i, account := range chain.Accounts {
+		fmt.Printf("(%d) %s (%d ETH)\n", i, account.From.Hex(), balance)
+	}
+	fmt.Printf("\nPrivate Keys\n==================\n")
+	for i, key := range chain.Keys {
+		fmt.Printf("(%d) %s\n", i, hexutil.Encode(crypto.FromECDSA(key)))
+	}
+	fmt.Println()
+}
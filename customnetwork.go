@@ -4,6 +4,7 @@ import (
 	"log"
 
 	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
 )
 
 /*
@@ -31,3 +32,17 @@ func GetCustomNetworkClientWebsocket(URL string) (client *ethclient.Client, err
 	}
 	return
 }
+
+/*
+GetCustomNetworkRPCClient connects and return a raw JSON-RPC client to user defined Ethereum network.
+It is needed by helpers calling methods not exposed by ethclient, like WatchPendingTransactions
+*/
+func GetCustomNetworkRPCClient(URL string) (client *rpc.Client, err error) {
+	err = nil
+	client, err = rpc.Dial(URL)
+	if err != nil {
+		log.Printf("There was a failure connecting to %s via RPC: %+v", URL, err)
+		return
+	}
+	return
+}
@@ -0,0 +1,149 @@
+package goethereumhelper
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// DevnetClientVersion is returned by web3_clientVersion
+const DevnetClientVersion = "goethereumhelper-devnet/v1"
+
+// DefaultDevnetFilterTimeout is how long a filter lives without being polled, like in go-ethereum
+const DefaultDevnetFilterTimeout = 5 * time.Minute
+
+// DevnetConfig defines how a Devnet mines blocks
+type DevnetConfig struct {
+	Automine      bool          // Mine a block for every transaction received
+	BlockTime     time.Duration // When greater than zero, mine a block at this interval
+	FilterTimeout time.Duration // Filters not polled for this long are uninstalled. DefaultDevnetFilterTimeout when zero
+}
+
+// Devnet serves a TestChain over HTTP and websocket JSON-RPC (eth_*, net_*, web3_*, evm_* and the
+// anvil_*/hardhat_* cheat methods), so non-Go tools like ethers.js, Foundry cast or wallets can use the
+// in-memory chain.
+type Devnet struct {
+	chain  *TestChain
+	config DevnetConfig
+	server *rpc.Server
+
+	mu         sync.Mutex    // Serializes sending and mining
+	timeOffset time.Duration // Time added by evm_increaseTime and evm_mine, guarded by mu
+	filters    *devnetFilters
+	txFeed     event.Feed // Hashes of the transactions received, for newPendingTransactions subscriptions
+	quit       chan struct{}
+	closeOnce  sync.Once
+	wg         sync.WaitGroup
+}
+
+// NewDevnet returns a Devnet serving chain. Call Start to begin interval mining and Close when done.
+func NewDevnet(chain *TestChain, config DevnetConfig) (devnet *Devnet, err error) {
+	if config.FilterTimeout <= 0 {
+		config.FilterTimeout = DefaultDevnetFilterTimeout
+	}
+	devnet = &Devnet{
+		chain:   chain,
+		config:  config,
+		server:  rpc.NewServer(),
+		filters: newDevnetFilters(config.FilterTimeout),
+		quit:    make(chan struct{}),
+	}
+	apis := map[string]interface{}{
+		"eth":  &devnetEthAPI{devnet: devnet},
+		"net":  &devnetNetAPI{devnet: devnet},
+		"web3": &devnetWeb3API{},
+		"evm":  &devnetEvmAPI{devnet: devnet},
+		// Foundry and Hardhat tools call the same cheat methods with their own prefix
+		"anvil":   &devnetCheatAPI{devnet: devnet},
+		"hardhat": &devnetCheatAPI{devnet: devnet},
+	}
+	for namespace, api := range apis {
+		err = devnet.server.RegisterName(namespace, api)
+		if err != nil {
+			err = fmt.Errorf("could not register %s API: %w", namespace, err)
+			return
+		}
+	}
+	return
+}
+
+// Start begins mining a block every BlockTime, when configured
+func (d *Devnet) Start() {
+	if d.config.BlockTime <= 0 {
+		return
+	}
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		ticker := time.NewTicker(d.config.BlockTime)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				d.Mine(1)
+			case <-d.quit:
+				return
+			}
+		}
+	}()
+}
+
+// Close stops interval mining and the RPC server. Calling it again has no effect.
+func (d *Devnet) Close() {
+	d.closeOnce.Do(func() {
+		close(d.quit)
+		d.wg.Wait()
+		d.server.Stop()
+	})
+}
+
+// Handler returns an http.Handler answering JSON-RPC over HTTP POST and upgrading websocket requests
+func (d *Devnet) Handler() http.Handler {
+	websocket := d.server.WebsocketHandler([]string{"*"})
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+			websocket.ServeHTTP(w, r)
+			return
+		}
+		// Browser wallets and dapps call the devnet from other origins
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		d.server.ServeHTTP(w, r)
+	})
+}
+
+// Mine mines n blocks, the first one including the pending transactions
+func (d *Devnet) Mine(n int) (head common.Hash) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.chain.MineBlocks(n)
+}
+
+// sendTransaction adds a signed transaction to the pending block, mining it when automine is on
+func (d *Devnet) sendTransaction(ctx context.Context, tx *types.Transaction) (err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	err = d.chain.SendTransaction(ctx, tx)
+	if err != nil {
+		return
+	}
+	d.filters.addPendingTransaction(tx.Hash())
+	d.txFeed.Send(tx.Hash())
+	// Impersonated transactions are mined as soon as they are sent
+	if d.config.Automine && !d.chain.isImpersonated(tx) {
+		d.chain.MineBlocks(1)
+	}
+	return
+}
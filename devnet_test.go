@@ -0,0 +1,323 @@
+package goethereumhelper
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// startDevnet serves a new chain with config and returns its URL, closed with the test
+func startDevnet(t *testing.T, config DevnetConfig) (chain *TestChain, devnet *Devnet, url string) {
+	t.Helper()
+	chain, err := NewTestChain()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { chain.Close() })
+	devnet, err = NewDevnet(chain, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	devnet.Start()
+	t.Cleanup(devnet.Close)
+	server := httptest.NewServer(devnet.Handler())
+	t.Cleanup(server.Close)
+	return chain, devnet, server.URL
+}
+
+func dialDevnet(t *testing.T, url string) (client *rpc.Client) {
+	t.Helper()
+	client, err := rpc.DialContext(context.Background(), url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(client.Close)
+	return
+}
+
+func TestDevnetHTTP(t *testing.T) {
+	chain, _, url := startDevnet(t, DevnetConfig{Automine: true})
+	client := dialDevnet(t, url)
+	eth := ethclient.NewClient(client)
+	ctx := context.Background()
+
+	chainID, err := eth.ChainID(ctx)
+	if err != nil || chainID.Int64() != 1337 {
+		t.Fatalf("eth_chainId returned %v, %v", chainID, err)
+	}
+	var version, clientVersion string
+	var hash hexutil.Bytes
+	var accounts []common.Address
+	calls := []struct {
+		result interface{}
+		method string
+		args   []interface{}
+	}{
+		{&version, "net_version", nil},
+		{&clientVersion, "web3_clientVersion", nil},
+		{&hash, "web3_sha3", []interface{}{hexutil.Bytes("devnet")}},
+		{&accounts, "eth_accounts", nil},
+	}
+	for _, call := range calls {
+		if err := client.Call(call.result, call.method, call.args...); err != nil {
+			t.Fatalf("%s: %v", call.method, err)
+		}
+	}
+	if version != "1337" || clientVersion != DevnetClientVersion || common.BytesToHash(hash) != crypto.Keccak256Hash([]byte("devnet")) {
+		t.Fatalf("net_version %s, web3_clientVersion %s, web3_sha3 %s", version, clientVersion, hash)
+	}
+	if len(accounts) != len(chain.Accounts) || accounts[0] != chain.Account(0) {
+		t.Fatalf("eth_accounts returned %v", accounts)
+	}
+
+	// eth_sendTransaction with a funded account is mined right away by automine
+	recipient := common.HexToAddress("0x1234")
+	var txHash common.Hash
+	err = client.Call(&txHash, "eth_sendTransaction", map[string]interface{}{
+		"from":  chain.Account(0),
+		"to":    recipient,
+		"value": (*hexutil.Big)(big.NewInt(1000)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	receipt, err := eth.TransactionReceipt(ctx, txHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful || receipt.BlockNumber.Uint64() != 1 {
+		t.Fatalf("unexpected receipt %+v", receipt)
+	}
+	if number, err := eth.BlockNumber(ctx); err != nil || number != 1 {
+		t.Fatalf("eth_blockNumber returned %d, %v", number, err)
+	}
+	if balance, err := eth.BalanceAt(ctx, recipient, nil); err != nil || balance.Int64() != 1000 {
+		t.Fatalf("eth_getBalance returned %v, %v", balance, err)
+	}
+	if nonce, err := eth.NonceAt(ctx, chain.Account(0), nil); err != nil || nonce != 1 {
+		t.Fatalf("eth_getTransactionCount returned %d, %v", nonce, err)
+	}
+	tx, pending, err := eth.TransactionByHash(ctx, txHash)
+	if err != nil || pending || tx.Hash() != txHash {
+		t.Fatalf("eth_getTransactionByHash returned %v, %v, %v", tx, pending, err)
+	}
+	block, err := eth.BlockByNumber(ctx, big.NewInt(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(block.Transactions()) != 1 || block.Transactions()[0].Hash() != txHash {
+		t.Fatalf("block 1 has transactions %v", block.Transactions())
+	}
+	if byHash, err := eth.BlockByHash(ctx, block.Hash()); err != nil || byHash.Hash() != block.Hash() {
+		t.Fatalf("eth_getBlockByHash returned %v, %v", byHash, err)
+	}
+
+	// Unknown blocks and transactions are null, not errors
+	if _, err := eth.BlockByHash(ctx, common.HexToHash("0x01")); !errors.Is(err, ethereum.NotFound) {
+		t.Fatalf("expected ethereum.NotFound for an unknown block hash, got %v", err)
+	}
+	if _, err := eth.BlockByNumber(ctx, big.NewInt(100)); !errors.Is(err, ethereum.NotFound) {
+		t.Fatalf("expected ethereum.NotFound for a future block, got %v", err)
+	}
+	if _, err := eth.TransactionReceipt(ctx, common.HexToHash("0x01")); !errors.Is(err, ethereum.NotFound) {
+		t.Fatalf("expected ethereum.NotFound for an unknown receipt, got %v", err)
+	}
+
+	// evm_increaseTime answers the total offset, like Hardhat
+	var total string
+	for _, seconds := range []hexutil.Uint64{60, 30} {
+		if err := client.Call(&total, "evm_increaseTime", seconds); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if total != "90" {
+		t.Fatalf("evm_increaseTime returned %s, expected 90", total)
+	}
+}
+
+func TestDevnetWebsocket(t *testing.T) {
+	chain, _, url := startDevnet(t, DevnetConfig{Automine: true})
+	eth := ethclient.NewClient(dialDevnet(t, "ws"+strings.TrimPrefix(url, "http")))
+	ctx := context.Background()
+
+	heads := make(chan *types.Header)
+	headSub, err := eth.SubscribeNewHead(ctx, heads)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer headSub.Unsubscribe()
+	emitter := common.HexToAddress("0xee")
+	if err := chain.SetCode(emitter, common.FromHex("60006000a000")); err != nil {
+		t.Fatal(err)
+	}
+	logs := make(chan types.Log)
+	logSub, err := eth.SubscribeFilterLogs(ctx, ethereum.FilterQuery{Addresses: []common.Address{emitter}}, logs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logSub.Unsubscribe()
+	// SetCode mined a block before the subscription, skip its head if delivered
+	start := chain.Blockchain().CurrentBlock().Number.Uint64()
+
+	tx, err := chain.Accounts[0].Signer(chain.Account(0), types.NewTransaction(0, emitter, new(big.Int), 100000, big.NewInt(2e9), nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := eth.SendTransaction(ctx, tx); err != nil {
+		t.Fatal(err)
+	}
+	for {
+		select {
+		case head := <-heads:
+			if head.Number.Uint64() <= start {
+				continue
+			}
+		case err := <-headSub.Err():
+			t.Fatal(err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("no head notified")
+		}
+		break
+	}
+	select {
+	case log := <-logs:
+		if log.TxHash != tx.Hash() {
+			t.Fatalf("log of transaction %s, expected %s", log.TxHash.Hex(), tx.Hash().Hex())
+		}
+	case err := <-logSub.Err():
+		t.Fatal(err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no log notified")
+	}
+}
+
+func TestDevnetIntervalMining(t *testing.T) {
+	chain, devnet, url := startDevnet(t, DevnetConfig{BlockTime: 20 * time.Millisecond})
+	eth := ethclient.NewClient(dialDevnet(t, url))
+	ctx := context.Background()
+
+	// Without automine the transaction waits for the next interval block
+	tx, err := chain.Accounts[0].Signer(chain.Account(0), types.NewTransaction(0, common.HexToAddress("0x1234"), big.NewInt(1), 21000, big.NewInt(2e9), nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := eth.SendTransaction(ctx, tx); err != nil {
+		t.Fatal(err)
+	}
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		number, err := eth.BlockNumber(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if number >= 3 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("only %d blocks mined", number)
+		}
+	}
+	if _, err := eth.TransactionReceipt(ctx, tx.Hash()); err != nil {
+		t.Fatalf("transaction not mined by interval mining: %v", err)
+	}
+	devnet.Close()
+	devnet.Close()
+}
+
+func TestDevnetFilters(t *testing.T) {
+	chain, devnet, url := startDevnet(t, DevnetConfig{Automine: true, FilterTimeout: time.Hour})
+	client := dialDevnet(t, url)
+	emitter := common.HexToAddress("0xee")
+	if err := chain.SetCode(emitter, common.FromHex("60006000a000")); err != nil {
+		t.Fatal(err)
+	}
+
+	var blockFilter, logFilter, pendingFilter string
+	if err := client.Call(&blockFilter, "eth_newBlockFilter"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Call(&logFilter, "eth_newFilter", map[string]interface{}{"address": emitter}); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Call(&pendingFilter, "eth_newPendingTransactionFilter"); err != nil {
+		t.Fatal(err)
+	}
+
+	tx, err := chain.Accounts[0].Signer(chain.Account(0), types.NewTransaction(0, emitter, new(big.Int), 100000, big.NewInt(2e9), nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ethclient.NewClient(client).SendTransaction(context.Background(), tx); err != nil {
+		t.Fatal(err)
+	}
+
+	var blocks, pending []common.Hash
+	var logs, filterLogs []types.Log
+	if err := client.Call(&blocks, "eth_getFilterChanges", blockFilter); err != nil {
+		t.Fatal(err)
+	}
+	if len(blocks) != 1 || blocks[0] != chain.Blockchain().CurrentBlock().Hash() {
+		t.Fatalf("block filter returned %v", blocks)
+	}
+	if err := client.Call(&pending, "eth_getFilterChanges", pendingFilter); err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 1 || pending[0] != tx.Hash() {
+		t.Fatalf("pending transaction filter returned %v", pending)
+	}
+	if err := client.Call(&logs, "eth_getFilterChanges", logFilter); err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) != 1 || logs[0].TxHash != tx.Hash() {
+		t.Fatalf("log filter returned %v", logs)
+	}
+	// Changes are only reported once, eth_getFilterLogs returns every log
+	if err := client.Call(&logs, "eth_getFilterChanges", logFilter); err != nil || len(logs) != 0 {
+		t.Fatalf("log filter returned %v, %v on the second poll", logs, err)
+	}
+	if err := client.Call(&filterLogs, "eth_getFilterLogs", logFilter); err != nil || len(filterLogs) != 1 {
+		t.Fatalf("eth_getFilterLogs returned %v, %v", filterLogs, err)
+	}
+
+	var uninstalled bool
+	if err := client.Call(&uninstalled, "eth_uninstallFilter", blockFilter); err != nil || !uninstalled {
+		t.Fatalf("eth_uninstallFilter returned %v, %v", uninstalled, err)
+	}
+	if err := client.Call(&blocks, "eth_getFilterChanges", blockFilter); err == nil {
+		t.Fatal("expected an error polling an uninstalled filter")
+	}
+
+	// Filters not polled within the timeout are uninstalled
+	devnet.filters.mu.Lock()
+	devnet.filters.timeout = 10 * time.Millisecond
+	devnet.filters.mu.Unlock()
+	var expiring string
+	if err := client.Call(&expiring, "eth_newBlockFilter"); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	// Installing another filter sweeps the expired ones
+	if err := client.Call(&blockFilter, "eth_newBlockFilter"); err != nil {
+		t.Fatal(err)
+	}
+	devnet.filters.mu.Lock()
+	_, found := devnet.filters.filters[rpc.ID(expiring)]
+	devnet.filters.mu.Unlock()
+	if found {
+		t.Fatal("expired filter not swept")
+	}
+	if err := client.Call(&blocks, "eth_getFilterChanges", expiring); err == nil {
+		t.Fatal("expected an error polling an expired filter")
+	}
+}
@@ -0,0 +1,955 @@
+package goethereumhelper
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/consensus/misc"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth/filters"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// devnetTxArgs are the transaction arguments of eth_call, eth_estimateGas and eth_sendTransaction
+type devnetTxArgs struct {
+	From                 *common.Address   `json:"from"`
+	To                   *common.Address   `json:"to"`
+	Gas                  *hexutil.Uint64   `json:"gas"`
+	GasPrice             *hexutil.Big      `json:"gasPrice"`
+	MaxFeePerGas         *hexutil.Big      `json:"maxFeePerGas"`
+	MaxPriorityFeePerGas *hexutil.Big      `json:"maxPriorityFeePerGas"`
+	Value                *hexutil.Big      `json:"value"`
+	Nonce                *hexutil.Uint64   `json:"nonce"`
+	Data                 *hexutil.Bytes    `json:"data"`
+	Input                *hexutil.Bytes    `json:"input"`
+	AccessList           *types.AccessList `json:"accessList"`
+}
+
+func (args *devnetTxArgs) data() []byte {
+	if args.Input != nil {
+		return *args.Input
+	}
+	if args.Data != nil {
+		return *args.Data
+	}
+	return nil
+}
+
+func (args *devnetTxArgs) callMsg() (msg ethereum.CallMsg) {
+	msg = ethereum.CallMsg{
+		To:        args.To,
+		Data:      args.data(),
+		GasPrice:  (*big.Int)(args.GasPrice),
+		GasFeeCap: (*big.Int)(args.MaxFeePerGas),
+		GasTipCap: (*big.Int)(args.MaxPriorityFeePerGas),
+		Value:     (*big.Int)(args.Value),
+	}
+	if args.From != nil {
+		msg.From = *args.From
+	}
+	if args.Gas != nil {
+		msg.Gas = uint64(*args.Gas)
+	}
+	if args.AccessList != nil {
+		msg.AccessList = *args.AccessList
+	}
+	return
+}
+
+// devnetFeeHistory is the result of eth_feeHistory
+type devnetFeeHistory struct {
+	OldestBlock  *hexutil.Big     `json:"oldestBlock"`
+	Reward       [][]*hexutil.Big `json:"reward,omitempty"`
+	BaseFee      []*hexutil.Big   `json:"baseFeePerGas,omitempty"`
+	GasUsedRatio []float64        `json:"gasUsedRatio"`
+}
+
+// devnetEthAPI implements the eth namespace
+type devnetEthAPI struct {
+	devnet *Devnet
+}
+
+// ChainId implements eth_chainId
+func (api *devnetEthAPI) ChainId() *hexutil.Big {
+	return (*hexutil.Big)(api.devnet.chain.Config().ChainID)
+}
+
+// BlockNumber implements eth_blockNumber
+func (api *devnetEthAPI) BlockNumber() hexutil.Uint64 {
+	return hexutil.Uint64(api.devnet.chain.Blockchain().CurrentBlock().Number.Uint64())
+}
+
+// Accounts implements eth_accounts, returning the funded accounts of the chain
+func (api *devnetEthAPI) Accounts() []common.Address {
+	addresses := make([]common.Address, len(api.devnet.chain.Accounts))
+	for i, account := range api.devnet.chain.Accounts {
+		addresses[i] = account.From
+	}
+	return addresses
+}
+
+// Syncing implements eth_syncing
+func (api *devnetEthAPI) Syncing() bool {
+	return false
+}
+
+// Mining implements eth_mining
+func (api *devnetEthAPI) Mining() bool {
+	return api.devnet.config.Automine || api.devnet.config.BlockTime > 0
+}
+
+// GasPrice implements eth_gasPrice
+func (api *devnetEthAPI) GasPrice(ctx context.Context) (*hexutil.Big, error) {
+	price, err := api.devnet.chain.SuggestGasPrice(ctx)
+	return (*hexutil.Big)(price), err
+}
+
+// MaxPriorityFeePerGas implements eth_maxPriorityFeePerGas
+func (api *devnetEthAPI) MaxPriorityFeePerGas(ctx context.Context) (*hexutil.Big, error) {
+	tip, err := api.devnet.chain.SuggestGasTipCap(ctx)
+	return (*hexutil.Big)(tip), err
+}
+
+// FeeHistory implements eth_feeHistory
+func (api *devnetEthAPI) FeeHistory(ctx context.Context, blockCount math.HexOrDecimal64, lastBlock rpc.BlockNumber, rewardPercentiles []float64) (history *devnetFeeHistory, err error) {
+	blockchain := api.devnet.chain.Blockchain()
+	last := blockchain.CurrentBlock().Number.Uint64()
+	if lastBlock >= 0 && uint64(lastBlock) < last {
+		last = uint64(lastBlock)
+	}
+	count := uint64(blockCount)
+	if count > last+1 {
+		count = last + 1
+	}
+	oldest := last + 1 - count
+	history = &devnetFeeHistory{OldestBlock: (*hexutil.Big)(new(big.Int).SetUint64(oldest))}
+	var header *types.Header
+	for number := oldest; number <= last; number++ {
+		block := blockchain.GetBlockByNumber(number)
+		if block == nil {
+			return nil, fmt.Errorf("block %d not found", number)
+		}
+		header = block.Header()
+		history.BaseFee = append(history.BaseFee, (*hexutil.Big)(header.BaseFee))
+		history.GasUsedRatio = append(history.GasUsedRatio, float64(header.GasUsed)/float64(header.GasLimit))
+		if len(rewardPercentiles) > 0 {
+			history.Reward = append(history.Reward, blockRewards(block, rewardPercentiles))
+		}
+	}
+	if header != nil {
+		history.BaseFee = append(history.BaseFee, (*hexutil.Big)(misc.CalcBaseFee(api.devnet.chain.Config(), header)))
+	}
+	return
+}
+
+// blockRewards returns the effective tips paid in block at the given percentiles
+func blockRewards(block *types.Block, percentiles []float64) (rewards []*hexutil.Big) {
+	tips := make([]*big.Int, 0, len(block.Transactions()))
+	for _, tx := range block.Transactions() {
+		tip, err := tx.EffectiveGasTip(block.BaseFee())
+		if err == nil {
+			tips = append(tips, tip)
+		}
+	}
+	sort.Slice(tips, func(i, j int) bool { return tips[i].Cmp(tips[j]) < 0 })
+	for _, percentile := range percentiles {
+		reward := new(big.Int)
+		if len(tips) > 0 {
+			index := int(percentile / 100 * float64(len(tips)-1))
+			if index >= len(tips) {
+				index = len(tips) - 1
+			}
+			reward = tips[index]
+		}
+		rewards = append(rewards, (*hexutil.Big)(reward))
+	}
+	return
+}
+
+// resolveBlock converts a block parameter into the block number used by the backend: nil means latest
+func (api *devnetEthAPI) resolveBlock(ctx context.Context, blockNrOrHash *rpc.BlockNumberOrHash) (number *big.Int, pending bool, err error) {
+	if blockNrOrHash == nil {
+		return
+	}
+	if hash, ok := blockNrOrHash.Hash(); ok {
+		header, errHeader := api.devnet.chain.HeaderByHash(ctx, hash)
+		if errHeader != nil {
+			err = fmt.Errorf("block %s not found", hash.Hex())
+			return
+		}
+		number = header.Number
+		return
+	}
+	blockNumber, _ := blockNrOrHash.Number()
+	switch {
+	case blockNumber == rpc.PendingBlockNumber:
+		pending = true
+	case blockNumber < 0:
+		// latest, safe and finalized are the same on the in-memory chain
+	default:
+		number = big.NewInt(blockNumber.Int64())
+	}
+	return
+}
+
+// GetBalance implements eth_getBalance
+func (api *devnetEthAPI) GetBalance(ctx context.Context, address common.Address, blockNrOrHash *rpc.BlockNumberOrHash) (*hexutil.Big, error) {
+	number, _, err := api.resolveBlock(ctx, blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+	balance, err := api.devnet.chain.BalanceAt(ctx, address, number)
+	return (*hexutil.Big)(balance), err
+}
+
+// GetTransactionCount implements eth_getTransactionCount
+func (api *devnetEthAPI) GetTransactionCount(ctx context.Context, address common.Address, blockNrOrHash *rpc.BlockNumberOrHash) (hexutil.Uint64, error) {
+	number, pending, err := api.resolveBlock(ctx, blockNrOrHash)
+	if err != nil {
+		return 0, err
+	}
+	var nonce uint64
+	if pending {
+		nonce, err = api.devnet.chain.PendingNonceAt(ctx, address)
+	} else {
+		nonce, err = api.devnet.chain.NonceAt(ctx, address, number)
+	}
+	return hexutil.Uint64(nonce), err
+}
+
+// GetCode implements eth_getCode
+func (api *devnetEthAPI) GetCode(ctx context.Context, address common.Address, blockNrOrHash *rpc.BlockNumberOrHash) (hexutil.Bytes, error) {
+	number, pending, err := api.resolveBlock(ctx, blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+	if pending {
+		return api.devnet.chain.PendingCodeAt(ctx, address)
+	}
+	return api.devnet.chain.CodeAt(ctx, address, number)
+}
+
+// GetStorageAt implements eth_getStorageAt
+func (api *devnetEthAPI) GetStorageAt(ctx context.Context, address common.Address, key string, blockNrOrHash *rpc.BlockNumberOrHash) (hexutil.Bytes, error) {
+	number, _, err := api.resolveBlock(ctx, blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+	value, err := api.devnet.chain.StorageAt(ctx, address, common.HexToHash(key), number)
+	if err != nil {
+		return nil, err
+	}
+	return common.LeftPadBytes(value, common.HashLength), nil
+}
+
+// Call implements eth_call
+func (api *devnetEthAPI) Call(ctx context.Context, args devnetTxArgs, blockNrOrHash *rpc.BlockNumberOrHash) (hexutil.Bytes, error) {
+	number, pending, err := api.resolveBlock(ctx, blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+	if pending {
+		return api.devnet.chain.PendingCallContract(ctx, args.callMsg())
+	}
+	return api.devnet.chain.CallContract(ctx, args.callMsg(), number)
+}
+
+// EstimateGas implements eth_estimateGas. Estimations always run on the pending state.
+func (api *devnetEthAPI) EstimateGas(ctx context.Context, args devnetTxArgs, blockNrOrHash *rpc.BlockNumberOrHash) (hexutil.Uint64, error) {
+	gas, err := api.devnet.chain.EstimateGas(ctx, args.callMsg())
+	return hexutil.Uint64(gas), err
+}
+
+// SendRawTransaction implements eth_sendRawTransaction
+func (api *devnetEthAPI) SendRawTransaction(ctx context.Context, input hexutil.Bytes) (hash common.Hash, err error) {
+	tx := new(types.Transaction)
+	err = tx.UnmarshalBinary(input)
+	if err != nil {
+		return
+	}
+	err = api.devnet.sendTransaction(ctx, tx)
+	return tx.Hash(), err
+}
+
+// SendTransaction implements eth_sendTransaction for the funded accounts of the chain
+func (api *devnetEthAPI) SendTransaction(ctx context.Context, args devnetTxArgs) (hash common.Hash, err error) {
+	if args.From == nil {
+		err = errors.New("missing from address")
+		return
+	}
+	tx, err := api.devnet.buildTransaction(ctx, args)
+	if err != nil {
+		return
+	}
+	signedTx, err := api.devnet.signTransaction(*args.From, tx)
+	if err != nil {
+		return
+	}
+	err = api.devnet.sendTransaction(ctx, signedTx)
+	return signedTx.Hash(), err
+}
+
+// GetTransactionByHash implements eth_getTransactionByHash
+func (api *devnetEthAPI) GetTransactionByHash(ctx context.Context, hash common.Hash) (map[string]interface{}, error) {
+	tx, pending, err := api.devnet.chain.TransactionByHash(ctx, hash)
+	if errors.Is(err, ethereum.NotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if pending {
+		return api.devnet.marshalTransaction(tx, nil, 0)
+	}
+	receipt, err := api.devnet.chain.TransactionReceipt(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	block, err := api.devnet.chain.BlockByHash(ctx, receipt.BlockHash)
+	if err != nil {
+		return nil, err
+	}
+	return api.devnet.marshalTransaction(tx, block, receipt.TransactionIndex)
+}
+
+// GetTransactionReceipt implements eth_getTransactionReceipt
+func (api *devnetEthAPI) GetTransactionReceipt(ctx context.Context, hash common.Hash) (map[string]interface{}, error) {
+	receipt, err := api.devnet.chain.TransactionReceipt(ctx, hash)
+	if errors.Is(err, ethereum.NotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	tx, _, err := api.devnet.chain.TransactionByHash(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	return api.devnet.marshalReceipt(receipt, tx)
+}
+
+// GetBlockByNumber implements eth_getBlockByNumber. Pending is answered with the latest block.
+func (api *devnetEthAPI) GetBlockByNumber(ctx context.Context, number rpc.BlockNumber, fullTx bool) (map[string]interface{}, error) {
+	var blockNumber *big.Int
+	if number >= 0 {
+		blockNumber = big.NewInt(number.Int64())
+	}
+	block, err := api.devnet.chain.BlockByNumber(ctx, blockNumber)
+	if err != nil && blockNumber != nil && blockNumber.Uint64() > api.devnet.chain.Blockchain().CurrentBlock().Number.Uint64() {
+		// The simulated backend has no ethereum.NotFound for blocks
+		err = ethereum.NotFound
+	}
+	if errors.Is(err, ethereum.NotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return api.devnet.marshalBlock(block, fullTx)
+}
+
+// GetBlockByHash implements eth_getBlockByHash
+func (api *devnetEthAPI) GetBlockByHash(ctx context.Context, hash common.Hash, fullTx bool) (map[string]interface{}, error) {
+	block, err := api.devnet.chain.BlockByHash(ctx, hash)
+	if err != nil && api.devnet.chain.Blockchain().GetHeaderByHash(hash) == nil {
+		err = ethereum.NotFound
+	}
+	if errors.Is(err, ethereum.NotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return api.devnet.marshalBlock(block, fullTx)
+}
+
+// GetLogs implements eth_getLogs
+func (api *devnetEthAPI) GetLogs(ctx context.Context, crit filters.FilterCriteria) ([]types.Log, error) {
+	logs, err := api.devnet.chain.FilterLogs(ctx, ethereum.FilterQuery(crit))
+	if logs == nil {
+		logs = []types.Log{}
+	}
+	return logs, err
+}
+
+// NewFilter implements eth_newFilter
+func (api *devnetEthAPI) NewFilter(crit filters.FilterCriteria) rpc.ID {
+	return api.devnet.filters.install(devnetLogFilter, ethereum.FilterQuery(crit), api.BlockNumber())
+}
+
+// NewBlockFilter implements eth_newBlockFilter
+func (api *devnetEthAPI) NewBlockFilter() rpc.ID {
+	return api.devnet.filters.install(devnetBlockFilter, ethereum.FilterQuery{}, api.BlockNumber())
+}
+
+// NewPendingTransactionFilter implements eth_newPendingTransactionFilter
+func (api *devnetEthAPI) NewPendingTransactionFilter() rpc.ID {
+	return api.devnet.filters.install(devnetPendingTxFilter, ethereum.FilterQuery{}, api.BlockNumber())
+}
+
+// UninstallFilter implements eth_uninstallFilter
+func (api *devnetEthAPI) UninstallFilter(id rpc.ID) bool {
+	return api.devnet.filters.uninstall(id)
+}
+
+// GetFilterChanges implements eth_getFilterChanges
+func (api *devnetEthAPI) GetFilterChanges(ctx context.Context, id rpc.ID) (interface{}, error) {
+	return api.devnet.filters.changes(ctx, api.devnet.chain, id)
+}
+
+// GetFilterLogs implements eth_getFilterLogs
+func (api *devnetEthAPI) GetFilterLogs(ctx context.Context, id rpc.ID) ([]types.Log, error) {
+	query, ok := api.devnet.filters.query(id)
+	if !ok {
+		return nil, errors.New("filter not found")
+	}
+	return api.GetLogs(ctx, filters.FilterCriteria(query))
+}
+
+// NewHeads implements eth_subscribe("newHeads")
+func (api *devnetEthAPI) NewHeads(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return nil, rpc.ErrNotificationsUnsupported
+	}
+	// The call context ends with the eth_subscribe request, the subscription lives until the client unsubscribes
+	heads := make(chan *types.Header)
+	sub, err := api.devnet.chain.SubscribeNewHead(context.Background(), heads)
+	if err != nil {
+		return nil, err
+	}
+	rpcSub := notifier.CreateSubscription()
+	go func() {
+		defer sub.Unsubscribe()
+		for {
+			select {
+			case head := <-heads:
+				notifier.Notify(rpcSub.ID, head)
+			case <-rpcSub.Err():
+				return
+			case <-sub.Err():
+				return
+			}
+		}
+	}()
+	return rpcSub, nil
+}
+
+// Logs implements eth_subscribe("logs")
+func (api *devnetEthAPI) Logs(ctx context.Context, crit filters.FilterCriteria) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return nil, rpc.ErrNotificationsUnsupported
+	}
+	logs := make(chan types.Log)
+	sub, err := api.devnet.chain.SubscribeFilterLogs(context.Background(), ethereum.FilterQuery(crit), logs)
+	if err != nil {
+		return nil, err
+	}
+	rpcSub := notifier.CreateSubscription()
+	go func() {
+		defer sub.Unsubscribe()
+		for {
+			select {
+			case log := <-logs:
+				notifier.Notify(rpcSub.ID, &log)
+			case <-rpcSub.Err():
+				return
+			case <-sub.Err():
+				return
+			}
+		}
+	}()
+	return rpcSub, nil
+}
+
+// NewPendingTransactions implements eth_subscribe("newPendingTransactions")
+func (api *devnetEthAPI) NewPendingTransactions(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return nil, rpc.ErrNotificationsUnsupported
+	}
+	hashes := make(chan common.Hash)
+	sub := api.devnet.txFeed.Subscribe(hashes)
+	rpcSub := notifier.CreateSubscription()
+	go func() {
+		defer sub.Unsubscribe()
+		for {
+			select {
+			case hash := <-hashes:
+				notifier.Notify(rpcSub.ID, hash)
+			case <-rpcSub.Err():
+				return
+			case <-sub.Err():
+				return
+			}
+		}
+	}()
+	return rpcSub, nil
+}
+
+// devnetNetAPI implements the net namespace
+type devnetNetAPI struct {
+	devnet *Devnet
+}
+
+// Version implements net_version
+func (api *devnetNetAPI) Version() string {
+	return api.devnet.chain.Config().ChainID.String()
+}
+
+// Listening implements net_listening
+func (api *devnetNetAPI) Listening() bool {
+	return true
+}
+
+// PeerCount implements net_peerCount
+func (api *devnetNetAPI) PeerCount() hexutil.Uint {
+	return 0
+}
+
+// devnetWeb3API implements the web3 namespace
+type devnetWeb3API struct{}
+
+// ClientVersion implements web3_clientVersion
+func (api *devnetWeb3API) ClientVersion() string {
+	return DevnetClientVersion
+}
+
+// Sha3 implements web3_sha3
+func (api *devnetWeb3API) Sha3(input hexutil.Bytes) hexutil.Bytes {
+	return crypto.Keccak256(input)
+}
+
+// devnetEvmAPI implements the evm namespace used by Hardhat and Ganache based tools
+type devnetEvmAPI struct {
+	devnet *Devnet
+}
+
+// Mine implements evm_mine. When timestamp is given the block is mined with that timestamp.
+func (api *devnetEvmAPI) Mine(timestamp *hexutil.Uint64) (string, error) {
+	if timestamp != nil {
+		head := api.devnet.chain.Blockchain().CurrentBlock()
+		if uint64(*timestamp) <= head.Time {
+			return "", fmt.Errorf("timestamp %d is not after the latest block timestamp %d", uint64(*timestamp), head.Time)
+		}
+		// Blocks of the simulated backend are mined 10 seconds after their parent
+		offset := time.Duration(int64(uint64(*timestamp))-int64(head.Time)-10) * time.Second
+		api.devnet.mu.Lock()
+		defer api.devnet.mu.Unlock()
+		err := api.devnet.chain.AdvanceTime(offset)
+		if err == nil {
+			api.devnet.timeOffset += offset
+		}
+		return "0x0", err
+	}
+	api.devnet.Mine(1)
+	return "0x0", nil
+}
+
+// IncreaseTime implements evm_increaseTime. Like Hardhat, it returns the total time offset in seconds as a
+// decimal string. Unlike Hardhat, the time jump is applied by mining a block.
+func (api *devnetEvmAPI) IncreaseTime(seconds math.HexOrDecimal64) (string, error) {
+	api.devnet.mu.Lock()
+	defer api.devnet.mu.Unlock()
+	increase := time.Duration(seconds) * time.Second
+	err := api.devnet.chain.AdvanceTime(increase)
+	if err != nil {
+		return "", err
+	}
+	api.devnet.timeOffset += increase
+	return strconv.FormatInt(int64(api.devnet.timeOffset/time.Second), 10), nil
+}
+
+// Snapshot implements evm_snapshot
+func (api *devnetEvmAPI) Snapshot() hexutil.Uint64 {
+	return hexutil.Uint64(api.devnet.chain.Snapshot())
+}
+
+// Revert implements evm_revert
+func (api *devnetEvmAPI) Revert(id hexutil.Uint64) bool {
+	api.devnet.mu.Lock()
+	defer api.devnet.mu.Unlock()
+	return api.devnet.chain.RevertToSnapshot(int(id)) == nil
+}
+
+// devnetCheatAPI implements the cheat methods of the anvil and hardhat namespaces
+type devnetCheatAPI struct {
+	devnet *Devnet
+}
+
+// SetBalance implements anvil_setBalance
+func (api *devnetCheatAPI) SetBalance(address common.Address, balance hexutil.Big) error {
+	api.devnet.mu.Lock()
+	defer api.devnet.mu.Unlock()
+	return api.devnet.chain.SetBalance(address, (*big.Int)(&balance))
+}
+
+// SetCode implements anvil_setCode
+func (api *devnetCheatAPI) SetCode(address common.Address, code hexutil.Bytes) error {
+	api.devnet.mu.Lock()
+	defer api.devnet.mu.Unlock()
+	return api.devnet.chain.SetCode(address, code)
+}
+
+// SetStorageAt implements anvil_setStorageAt
+func (api *devnetCheatAPI) SetStorageAt(address common.Address, slot string, value common.Hash) (bool, error) {
+	api.devnet.mu.Lock()
+	defer api.devnet.mu.Unlock()
+	err := api.devnet.chain.SetStorageAt(address, common.HexToHash(slot), value)
+	return err == nil, err
+}
+
+// SetNonce implements anvil_setNonce
+func (api *devnetCheatAPI) SetNonce(address common.Address, nonce hexutil.Uint64) error {
+	api.devnet.mu.Lock()
+	defer api.devnet.mu.Unlock()
+	return api.devnet.chain.SetNonce(address, uint64(nonce))
+}
+
+// ImpersonateAccount implements anvil_impersonateAccount, so eth_sendTransaction accepts address as sender
+func (api *devnetCheatAPI) ImpersonateAccount(address common.Address) {
+	api.devnet.chain.Impersonate(address)
+}
+
+// StopImpersonatingAccount implements anvil_stopImpersonatingAccount
+func (api *devnetCheatAPI) StopImpersonatingAccount(address common.Address) {
+	api.devnet.chain.StopImpersonating(address)
+}
+
+// Mine implements anvil_mine, mining blocks blocks (one by default) interval seconds apart
+func (api *devnetCheatAPI) Mine(blocks *hexutil.Uint64, interval *hexutil.Uint64) error {
+	count := 1
+	if blocks != nil {
+		count = int(*blocks)
+	}
+	var spacing time.Duration
+	if interval != nil {
+		spacing = time.Duration(*interval) * time.Second
+	}
+	api.devnet.mu.Lock()
+	defer api.devnet.mu.Unlock()
+	_, err := api.devnet.chain.Mine(count, spacing)
+	return err
+}
+
+// buildTransaction fills the missing fields of an eth_sendTransaction request
+func (d *Devnet) buildTransaction(ctx context.Context, args devnetTxArgs) (tx *types.Transaction, err error) {
+	var nonce uint64
+	if args.Nonce != nil {
+		nonce = uint64(*args.Nonce)
+	} else {
+		nonce, err = d.chain.PendingNonceAt(ctx, *args.From)
+		if err != nil {
+			return
+		}
+	}
+	var gas uint64
+	if args.Gas != nil {
+		gas = uint64(*args.Gas)
+	} else {
+		gas, err = d.chain.EstimateGas(ctx, args.callMsg())
+		if err != nil {
+			return
+		}
+	}
+	value := new(big.Int)
+	if args.Value != nil {
+		value = (*big.Int)(args.Value)
+	}
+
+	if args.GasPrice != nil && args.MaxFeePerGas == nil && args.MaxPriorityFeePerGas == nil {
+		tx = types.NewTx(&types.LegacyTx{
+			Nonce:    nonce,
+			GasPrice: (*big.Int)(args.GasPrice),
+			Gas:      gas,
+			To:       args.To,
+			Value:    value,
+			Data:     args.data(),
+		})
+		return
+	}
+
+	gasTip := (*big.Int)(args.MaxPriorityFeePerGas)
+	if gasTip == nil {
+		gasTip, err = d.chain.SuggestGasTipCap(ctx)
+		if err != nil {
+			return
+		}
+	}
+	maxGasFeeAccepted := (*big.Int)(args.MaxFeePerGas)
+	if maxGasFeeAccepted == nil {
+		head := d.chain.Blockchain().CurrentBlock()
+		baseFee := misc.CalcBaseFee(d.chain.Config(), head)
+		maxGasFeeAccepted = new(big.Int).Add(new(big.Int).Mul(baseFee, big.NewInt(2)), gasTip)
+	}
+	var accessList types.AccessList
+	if args.AccessList != nil {
+		accessList = *args.AccessList
+	}
+	tx = types.NewTx(&types.DynamicFeeTx{
+		ChainID:    d.chain.Config().ChainID,
+		Nonce:      nonce,
+		GasTipCap:  gasTip,
+		GasFeeCap:  maxGasFeeAccepted,
+		Gas:        gas,
+		To:         args.To,
+		Value:      value,
+		Data:       args.data(),
+		AccessList: accessList,
+	})
+	return
+}
+
+// signTransaction signs tx with the funded account from, or registers it when from is impersonated
+func (d *Devnet) signTransaction(from common.Address, tx *types.Transaction) (*types.Transaction, error) {
+	for _, account := range d.chain.Accounts {
+		if account.From == from {
+			return account.Signer(from, tx)
+		}
+	}
+	signedTx, err := d.chain.impersonationSigner(from, tx)
+	if err != nil {
+		return nil, fmt.Errorf("unknown account %s", from.Hex())
+	}
+	return signedTx, nil
+}
+
+// toJSONMap converts a value with its own JSON encoding into a map, so fields can be added
+func toJSONMap(value interface{}) (fields map[string]interface{}, err error) {
+	content, err := json.Marshal(value)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(content, &fields)
+	return
+}
+
+// marshalTransaction returns the RPC representation of tx, mined in block at index or pending when block is nil
+func (d *Devnet) marshalTransaction(tx *types.Transaction, block *types.Block, index uint) (fields map[string]interface{}, err error) {
+	fields, err = toJSONMap(tx)
+	if err != nil {
+		return
+	}
+	from, err := d.chain.Sender(tx)
+	if err != nil {
+		return
+	}
+	fields["from"] = from
+	fields["blockHash"] = nil
+	fields["blockNumber"] = nil
+	fields["transactionIndex"] = nil
+	if block != nil {
+		fields["blockHash"] = block.Hash()
+		fields["blockNumber"] = (*hexutil.Big)(block.Number())
+		fields["transactionIndex"] = hexutil.Uint64(index)
+		if tx.Type() == types.DynamicFeeTxType && block.BaseFee() != nil {
+			tip, _ := tx.EffectiveGasTip(block.BaseFee())
+			fields["gasPrice"] = (*hexutil.Big)(new(big.Int).Add(tip, block.BaseFee()))
+		}
+	}
+	return
+}
+
+// marshalReceipt returns the RPC representation of the receipt of tx
+func (d *Devnet) marshalReceipt(receipt *types.Receipt, tx *types.Transaction) (fields map[string]interface{}, err error) {
+	fields, err = toJSONMap(receipt)
+	if err != nil {
+		return
+	}
+	from, err := d.chain.Sender(tx)
+	if err != nil {
+		return
+	}
+	fields["from"] = from
+	fields["to"] = tx.To()
+	fields["type"] = hexutil.Uint(tx.Type())
+	if len(receipt.PostState) == 0 {
+		delete(fields, "root")
+	}
+	if tx.To() != nil {
+		fields["contractAddress"] = nil
+	}
+	if receipt.Logs == nil {
+		fields["logs"] = []*types.Log{}
+	}
+	return
+}
+
+// marshalBlock returns the RPC representation of block
+func (d *Devnet) marshalBlock(block *types.Block, fullTx bool) (fields map[string]interface{}, err error) {
+	fields, err = toJSONMap(block.Header())
+	if err != nil {
+		return
+	}
+	if block.Header().WithdrawalsHash == nil {
+		delete(fields, "withdrawalsRoot")
+	}
+	fields["size"] = hexutil.Uint64(block.Size())
+	fields["totalDifficulty"] = (*hexutil.Big)(d.chain.Blockchain().GetTd(block.Hash(), block.NumberU64()))
+	fields["uncles"] = []common.Hash{}
+	transactions := make([]interface{}, len(block.Transactions()))
+	for i, tx := range block.Transactions() {
+		if !fullTx {
+			transactions[i] = tx.Hash()
+			continue
+		}
+		transactions[i], err = d.marshalTransaction(tx, block, uint(i))
+		if err != nil {
+			return
+		}
+	}
+	fields["transactions"] = transactions
+	return
+}
+
+// Kinds of filters installed with eth_new*Filter
+const (
+	devnetLogFilter = iota
+	devnetBlockFilter
+	devnetPendingTxFilter
+)
+
+// devnetFilter is a filter polled with eth_getFilterChanges
+type devnetFilter struct {
+	kind      int
+	query     ethereum.FilterQuery
+	lastBlock uint64        // Last block already reported
+	hashes    []common.Hash // Pending transactions not reported yet
+	deadline  time.Time     // Uninstalled when not polled until then
+}
+
+type devnetFilters struct {
+	timeout time.Duration
+
+	mu      sync.Mutex
+	filters map[rpc.ID]*devnetFilter
+}
+
+func newDevnetFilters(timeout time.Duration) *devnetFilters {
+	return &devnetFilters{timeout: timeout, filters: make(map[rpc.ID]*devnetFilter)}
+}
+
+func (f *devnetFilters) install(kind int, query ethereum.FilterQuery, head hexutil.Uint64) rpc.ID {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.expireLocked()
+	id := rpc.NewID()
+	f.filters[id] = &devnetFilter{kind: kind, query: query, lastBlock: uint64(head), deadline: time.Now().Add(f.timeout)}
+	return id
+}
+
+// expireLocked uninstalls the filters not polled within the timeout, as tools often never uninstall them.
+// Expired filters are swept when filters are installed or transactions recorded, the only times they grow.
+func (f *devnetFilters) expireLocked() {
+	now := time.Now()
+	for id, filter := range f.filters {
+		if now.After(filter.deadline) {
+			delete(f.filters, id)
+		}
+	}
+}
+
+func (f *devnetFilters) uninstall(id rpc.ID) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, found := f.filters[id]
+	delete(f.filters, id)
+	return found
+}
+
+func (f *devnetFilters) query(id rpc.ID) (query ethereum.FilterQuery, ok bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	filter, ok := f.filters[id]
+	if ok && time.Now().After(filter.deadline) {
+		delete(f.filters, id)
+		ok = false
+	}
+	if ok {
+		query = filter.query
+	}
+	return
+}
+
+// addPendingTransaction records hash in every pending transaction filter
+func (f *devnetFilters) addPendingTransaction(hash common.Hash) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.expireLocked()
+	for _, filter := range f.filters {
+		if filter.kind == devnetPendingTxFilter {
+			filter.hashes = append(filter.hashes, hash)
+		}
+	}
+}
+
+// changes returns what happened since the filter was last polled
+func (f *devnetFilters) changes(ctx context.Context, chain *TestChain, id rpc.ID) (result interface{}, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	filter, ok := f.filters[id]
+	if !ok || time.Now().After(filter.deadline) {
+		delete(f.filters, id)
+		return nil, errors.New("filter not found")
+	}
+	filter.deadline = time.Now().Add(f.timeout)
+	head := chain.Blockchain().CurrentBlock().Number.Uint64()
+	if filter.lastBlock > head {
+		// The chain was reverted to a snapshot
+		filter.lastBlock = head
+	}
+
+	switch filter.kind {
+	case devnetPendingTxFilter:
+		hashes := filter.hashes
+		if hashes == nil {
+			hashes = []common.Hash{}
+		}
+		filter.hashes = nil
+		return hashes, nil
+	case devnetBlockFilter:
+		hashes := []common.Hash{}
+		for number := filter.lastBlock + 1; number <= head; number++ {
+			hashes = append(hashes, chain.Blockchain().GetCanonicalHash(number))
+		}
+		filter.lastBlock = head
+		return hashes, nil
+	}
+
+	logs := []types.Log{}
+	if head > filter.lastBlock {
+		query := filter.query
+		from := filter.lastBlock + 1
+		if query.FromBlock != nil && query.FromBlock.Sign() >= 0 && query.FromBlock.Uint64() > from {
+			from = query.FromBlock.Uint64()
+		}
+		to := head
+		if query.ToBlock != nil && query.ToBlock.Sign() >= 0 && query.ToBlock.Uint64() < to {
+			to = query.ToBlock.Uint64()
+		}
+		if from <= to {
+			query.FromBlock = new(big.Int).SetUint64(from)
+			query.ToBlock = new(big.Int).SetUint64(to)
+			found, errLogs := chain.FilterLogs(ctx, query)
+			if errLogs != nil {
+				return nil, errLogs
+			}
+			logs = append(logs, found...)
+		}
+		filter.lastBlock = head
+	}
+	return logs, nil
+}
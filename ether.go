@@ -15,21 +15,27 @@ import (
 
 // SendEtherUsingKeystoreWallet an example that shows how to send ether using an account from KeystoreWallet to another using Go (Golang)
 func SendEtherUsingKeystoreWallet(client *ethclient.Client, sender KeystoreWallet, to common.Address, value int64) (signedTx *types.Transaction, err error) {
+	return SendEtherUsingSigner(client, &sender, to, value)
+}
+
+// SendEtherUsingSigner sends ether from the account of sender, like a ClefSigner holding the key in another
+// process, to another
+func SendEtherUsingSigner(client *ethclient.Client, sender TxSigner, to common.Address, value int64) (signedTx *types.Transaction, err error) {
 	chainID, err := client.ChainID(context.Background())
 	if err != nil {
-		log.Println("SendEtherUsingKeystoreWallet - Error getting chainID ", err)
+		log.Println("SendEtherUsingSigner - Error getting chainID ", err)
 		return
 	}
 
 	latestEthBlockHeader, err := client.HeaderByNumber(context.Background(), nil)
 	if err != nil {
-		log.Println("SendEtherUsingKeystoreWallet - Error gettin the latest Eth Block Header ", err)
+		log.Println("SendEtherUsingSigner - Error gettin the latest Eth Block Header ", err)
 		return
 	}
 
-	nonce, err := client.PendingNonceAt(context.Background(), sender.Account.Address)
+	nonce, err := client.PendingNonceAt(context.Background(), sender.Address())
 	if err != nil {
-		log.Println("SendEtherUsingKeystoreWallet - Error getting nonce ", err)
+		log.Println("SendEtherUsingSigner - Error getting nonce ", err)
 		return
 	}
 
@@ -37,7 +43,7 @@ func SendEtherUsingKeystoreWallet(client *ethclient.Client, sender KeystoreWalle
 	// Use new EIP-1559
 	gasTip, err := client.SuggestGasTipCap(context.Background())
 	if err != nil {
-		log.Println("SendEtherUsingKeystoreWallet - Error getting Blockchain suggested gasTip ", err)
+		log.Println("SendEtherUsingSigner - Error getting Blockchain suggested gasTip ", err)
 		return
 	}
 
@@ -59,15 +65,15 @@ func SendEtherUsingKeystoreWallet(client *ethclient.Client, sender KeystoreWalle
 	}
 	tx := types.NewTx(&eip1559Tx)
 
-	signedTx, err = sender.Wallet.SignTx(sender.Account, tx, chainID)
+	signedTx, err = sender.SignTx(tx, chainID)
 	if err != nil {
-		log.Println("SendEtherUsingKeystoreWallet - Error Signing Transaction ", err)
+		log.Println("SendEtherUsingSigner - Error Signing Transaction ", err)
 		return
 	}
 
 	err = client.SendTransaction(context.Background(), signedTx)
 	if err != nil {
-		log.Println("SendEtherUsingKeystoreWallet - Error sending Transaction ", err)
+		log.Println("SendEtherUsingSigner - Error sending Transaction ", err)
 		return
 	}
 	return
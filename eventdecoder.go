@@ -0,0 +1,275 @@
+package goethereumhelper
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// ErrEventNotDecoded is returned when unpacking an event that did not match any known ABI event
+var ErrEventNotDecoded = errors.New("event was not decoded by any registered ABI")
+
+// DecodedEvent represents an Ethereum log decoded against a contract ABI.
+// When no registered ABI matches the log, Decoded is false and only Raw is filled.
+type DecodedEvent struct {
+	Name      string                 // Event name as declared in the ABI
+	Signature string                 // Canonical event signature, ex: Transfer(address,address,uint256)
+	Anonymous bool                   // True when the event was declared as anonymous
+	Decoded   bool                   // False when the log was passed through raw
+	Address   common.Address         // Contract that emitted the log
+	Args      map[string]interface{} // Indexed and non-indexed arguments by their ABI name
+	Raw       types.Log              // Original log as received from the network
+
+	event *abi.Event
+}
+
+// Unpack copies the event arguments into out, which must be a pointer to a struct.
+// Fields are matched by the `abi:"name"` tag or, when absent, by the camel case version of the argument name.
+// Indexed dynamic arguments (string, bytes, arrays) are delivered as their keccak256 topic hash.
+func (e DecodedEvent) Unpack(out interface{}) (err error) {
+	if !e.Decoded {
+		return ErrEventNotDecoded
+	}
+	dst := reflect.ValueOf(out)
+	if dst.Kind() != reflect.Ptr || dst.IsNil() || dst.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("cannot unpack event %s into %T: a non-nil pointer to a struct is required", e.Name, out)
+	}
+	dst = dst.Elem()
+	for _, input := range e.event.Inputs {
+		field, ok := eventStructField(dst, input.Name)
+		if !ok {
+			continue
+		}
+		value := reflect.ValueOf(e.Args[input.Name])
+		switch {
+		case !value.IsValid():
+			continue
+		case value.Type().AssignableTo(field.Type()):
+			field.Set(value)
+		case value.Type().ConvertibleTo(field.Type()):
+			field.Set(value.Convert(field.Type()))
+		default:
+			return fmt.Errorf("cannot unpack argument %s of event %s: %s is not assignable to field of type %s", input.Name, e.Name, value.Type(), field.Type())
+		}
+	}
+	return
+}
+
+// NormalizedArgs returns the event arguments converted to JSON and database friendly values:
+// integers become decimal strings, addresses, hashes and byte values become 0x prefixed hex strings
+// and tuples become maps keyed by field name.
+func (e DecodedEvent) NormalizedArgs() (args map[string]interface{}) {
+	if e.Args == nil {
+		return
+	}
+	args = make(map[string]interface{}, len(e.Args))
+	for name, value := range e.Args {
+		args[name] = NormalizeABIValue(value)
+	}
+	return
+}
+
+// NormalizeABIValue converts an ABI decoded value the same way NormalizedArgs does
+func NormalizeABIValue(value interface{}) interface{} {
+	return normalizeABIValue(reflect.ValueOf(value))
+}
+
+func normalizeABIValue(value reflect.Value) interface{} {
+	if !value.IsValid() {
+		return nil
+	}
+	switch v := value.Interface().(type) {
+	case *big.Int:
+		if v == nil {
+			return nil
+		}
+		return v.String()
+	case common.Address:
+		return v.Hex()
+	case common.Hash:
+		return v.Hex()
+	case []byte:
+		return hexutil.Encode(v)
+	case string, bool:
+		return v
+	}
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(value.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(value.Uint(), 10)
+	case reflect.Array:
+		if value.Type().Elem().Kind() == reflect.Uint8 {
+			raw := make([]byte, value.Len())
+			reflect.Copy(reflect.ValueOf(raw), value)
+			return hexutil.Encode(raw)
+		}
+		fallthrough
+	case reflect.Slice:
+		items := make([]interface{}, value.Len())
+		for i := range items {
+			items[i] = normalizeABIValue(value.Index(i))
+		}
+		return items
+	case reflect.Struct:
+		fields := make(map[string]interface{}, value.NumField())
+		for i := 0; i < value.NumField(); i++ {
+			field := value.Type().Field(i)
+			name := field.Name
+			if tag, ok := field.Tag.Lookup("json"); ok {
+				name = strings.Split(tag, ",")[0]
+			}
+			fields[name] = normalizeABIValue(value.Field(i))
+		}
+		return fields
+	case reflect.Ptr:
+		return normalizeABIValue(value.Elem())
+	}
+	return value.Interface()
+}
+
+// eventStructField finds the struct field that receives the argument called name
+func eventStructField(dst reflect.Value, name string) (field reflect.Value, ok bool) {
+	structType := dst.Type()
+	for i := 0; i < structType.NumField(); i++ {
+		if tag, found := structType.Field(i).Tag.Lookup("abi"); found && tag == name {
+			return dst.Field(i), dst.Field(i).CanSet()
+		}
+	}
+	field = dst.FieldByName(abi.ToCamelCase(name))
+	return field, field.IsValid() && field.CanSet()
+}
+
+// EventDecoder decodes logs using ABIs registered per contract address or for any address
+type EventDecoder struct {
+	mu        sync.RWMutex
+	contracts map[common.Address][]*abi.ABI
+	generic   []*abi.ABI
+}
+
+// NewEventDecoder returns an EventDecoder with no ABIs registered
+func NewEventDecoder() *EventDecoder {
+	return &EventDecoder{
+		contracts: make(map[common.Address][]*abi.ABI),
+	}
+}
+
+// AddContract registers the JSON ABI used to decode logs emitted by address
+func (d *EventDecoder) AddContract(address common.Address, abiJSON string) (err error) {
+	contractABI, err := abi.JSON(strings.NewReader(abiJSON))
+	if err != nil {
+		err = fmt.Errorf("could not parse ABI for contract %s: %w", address.Hex(), err)
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.contracts[address] = append(d.contracts[address], &contractABI)
+	return
+}
+
+// AddABI registers a JSON ABI used to decode logs emitted by any address, ex: ERC-20 Transfer events
+func (d *EventDecoder) AddABI(abiJSON string) (err error) {
+	contractABI, err := abi.JSON(strings.NewReader(abiJSON))
+	if err != nil {
+		err = fmt.Errorf("could not parse ABI: %w", err)
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.generic = append(d.generic, &contractABI)
+	return
+}
+
+// Decode decodes a log. ABIs registered for the log address are tried before the generic ones.
+// Logs not matching any registered event, or whose topics and data do not fit it, are returned with
+// Decoded set to false and a nil error.
+func (d *EventDecoder) Decode(log types.Log) (event DecodedEvent, err error) {
+	event = DecodedEvent{
+		Address: log.Address,
+		Raw:     log,
+	}
+
+	d.mu.RLock()
+	candidates := make([]*abi.ABI, 0, len(d.contracts[log.Address])+len(d.generic))
+	candidates = append(candidates, d.contracts[log.Address]...)
+	candidates = append(candidates, d.generic...)
+	d.mu.RUnlock()
+
+	// Regular events carry their signature hash as the first topic
+	if len(log.Topics) > 0 {
+		for _, contractABI := range candidates {
+			abiEvent, errEvent := contractABI.EventByID(log.Topics[0])
+			if errEvent != nil {
+				continue
+			}
+			// Events with the same signature can differ in their indexed arguments, like the ERC-20 and
+			// ERC-721 Transfer, so a log not fitting this one may fit another candidate
+			args, errArgs := decodeEventArgs(abiEvent, log.Topics[1:], log.Data)
+			if errArgs != nil {
+				continue
+			}
+			fillDecodedEvent(&event, abiEvent, args)
+			return
+		}
+	}
+
+	// Anonymous events have no signature topic, so the first one whose layout fits the log is used
+	for _, contractABI := range candidates {
+		for _, abiEvent := range contractABI.Events {
+			if !abiEvent.Anonymous || countIndexed(abiEvent.Inputs) != len(log.Topics) {
+				continue
+			}
+			abiEvent := abiEvent
+			args, errArgs := decodeEventArgs(&abiEvent, log.Topics, log.Data)
+			if errArgs != nil {
+				continue
+			}
+			fillDecodedEvent(&event, &abiEvent, args)
+			return
+		}
+	}
+	return
+}
+
+func fillDecodedEvent(event *DecodedEvent, abiEvent *abi.Event, args map[string]interface{}) {
+	event.Name = abiEvent.RawName
+	event.Signature = abiEvent.Sig
+	event.Anonymous = abiEvent.Anonymous
+	event.Decoded = true
+	event.Args = args
+	event.event = abiEvent
+}
+
+func decodeEventArgs(abiEvent *abi.Event, topics []common.Hash, data []byte) (args map[string]interface{}, err error) {
+	args = make(map[string]interface{})
+	err = abiEvent.Inputs.UnpackIntoMap(args, data)
+	if err != nil {
+		return
+	}
+	var indexed abi.Arguments
+	for _, input := range abiEvent.Inputs {
+		if input.Indexed {
+			indexed = append(indexed, input)
+		}
+	}
+	err = abi.ParseTopicsIntoMap(args, indexed, topics)
+	return
+}
+
+func countIndexed(inputs abi.Arguments) (count int) {
+	for _, input := range inputs {
+		if input.Indexed {
+			count++
+		}
+	}
+	return
+}
@@ -0,0 +1,217 @@
+package goethereumhelper
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const (
+	erc20TransferABI  = `[{"anonymous":false,"inputs":[{"indexed":true,"name":"from","type":"address"},{"indexed":true,"name":"to","type":"address"},{"indexed":false,"name":"value","type":"uint256"}],"name":"Transfer","type":"event"}]`
+	erc721TransferABI = `[{"anonymous":false,"inputs":[{"indexed":true,"name":"from","type":"address"},{"indexed":true,"name":"to","type":"address"},{"indexed":true,"name":"tokenId","type":"uint256"}],"name":"Transfer","type":"event"}]`
+)
+
+func TestEventDecoderSameSignature(t *testing.T) {
+	from := common.HexToAddress("0x01")
+	to := common.HexToAddress("0x02")
+	nftTransfer := types.Log{
+		Address: common.HexToAddress("0x721"),
+		Topics: []common.Hash{
+			crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)")),
+			common.BytesToHash(from.Bytes()),
+			common.BytesToHash(to.Bytes()),
+			common.BigToHash(big.NewInt(7)),
+		},
+	}
+
+	decoder := NewEventDecoder()
+	if err := decoder.AddABI(erc20TransferABI); err != nil {
+		t.Fatal(err)
+	}
+	event, err := decoder.Decode(nftTransfer)
+	if err != nil {
+		t.Fatalf("log not fitting the ERC-20 Transfer must be passed through: %v", err)
+	}
+	if event.Decoded || event.Raw.Address != nftTransfer.Address {
+		t.Fatalf("expected a raw event, got %+v", event)
+	}
+
+	if err := decoder.AddABI(erc721TransferABI); err != nil {
+		t.Fatal(err)
+	}
+	event, err = decoder.Decode(nftTransfer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !event.Decoded || event.Name != "Transfer" {
+		t.Fatalf("expected the ERC-721 Transfer, got %+v", event)
+	}
+	if tokenID, ok := event.Args["tokenId"].(*big.Int); !ok || tokenID.Int64() != 7 {
+		t.Fatalf("token ID is %v", event.Args["tokenId"])
+	}
+}
+
+const anonymousDepositABI = `[{"anonymous":true,"inputs":[{"indexed":true,"name":"account","type":"address"},{"indexed":false,"name":"amount","type":"uint256"},{"indexed":false,"name":"memo","type":"string"}],"name":"Deposit","type":"event"}]`
+
+func TestEventDecoderAnonymousUnpack(t *testing.T) {
+	account := common.HexToAddress("0xabc")
+	depositABI, err := abi.JSON(strings.NewReader(anonymousDepositABI))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := depositABI.Events["Deposit"].Inputs.NonIndexed().Pack(big.NewInt(42), "hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	deposit := types.Log{
+		Address: common.HexToAddress("0xdead"),
+		Topics:  []common.Hash{common.BytesToHash(account.Bytes())},
+		Data:    data,
+	}
+
+	decoder := NewEventDecoder()
+	if err := decoder.AddContract(deposit.Address, anonymousDepositABI); err != nil {
+		t.Fatal(err)
+	}
+	event, err := decoder.Decode(deposit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !event.Decoded || !event.Anonymous || event.Name != "Deposit" {
+		t.Fatalf("expected the anonymous Deposit, got %+v", event)
+	}
+
+	var out struct {
+		Who    common.Address `abi:"account"`
+		Amount *big.Int
+		Note   string `abi:"memo"`
+	}
+	if err := event.Unpack(&out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Who != account || out.Amount.Int64() != 42 || out.Note != "hello" {
+		t.Fatalf("unpacked %+v", out)
+	}
+	if err := event.Unpack(out); err == nil {
+		t.Fatal("expected an error unpacking into a non-pointer")
+	}
+	var wrong struct {
+		Amount string
+	}
+	if err := event.Unpack(&wrong); err == nil {
+		t.Fatal("expected an error unpacking into a field of the wrong type")
+	}
+
+	// Another contract emitting the same layout is not decoded, the ABI was registered for one address
+	deposit.Address = common.HexToAddress("0xbeef")
+	event, err = decoder.Decode(deposit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.Decoded {
+		t.Fatalf("expected a raw event, got %+v", event)
+	}
+	if err := event.Unpack(&out); !errors.Is(err, ErrEventNotDecoded) {
+		t.Fatalf("expected ErrEventNotDecoded, got %v", err)
+	}
+}
+
+func TestWatchDecodedLogs(t *testing.T) {
+	chain, err := NewTestChain()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// token emits Transfer(0x01, 0x02, 5), other emits a log without topics
+	token, other := common.HexToAddress("0x20"), common.HexToAddress("0x30")
+	signature := crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)")).Hex()[2:]
+	if err := chain.SetCode(token, common.FromHex("6005600052600260017f"+signature+"60206000a300")); err != nil {
+		t.Fatal(err)
+	}
+	if err := chain.SetCode(other, common.FromHex("60006000a000")); err != nil {
+		t.Fatal(err)
+	}
+
+	decoder := NewEventDecoder()
+	if err := decoder.AddABI(erc20TransferABI); err != nil {
+		t.Fatal(err)
+	}
+	events := make(chan DecodedEvent)
+	sub, err := WatchDecodedLogs(ctx, chain, ethereum.FilterQuery{Addresses: []common.Address{token, other}}, decoder, events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Unsubscribe()
+
+	sendTestTx(t, chain, 0, token, nil)
+	sendTestTx(t, chain, 0, other, nil)
+	chain.Commit()
+
+	for _, expected := range []common.Address{token, other} {
+		var event DecodedEvent
+		select {
+		case event = <-events:
+		case err := <-sub.Err():
+			t.Fatal(err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("no event delivered")
+		}
+		if event.Address != expected {
+			t.Fatalf("got an event from %s, expected %s", event.Address.Hex(), expected.Hex())
+		}
+		switch expected {
+		case token:
+			if !event.Decoded || event.Args["value"].(*big.Int).Int64() != 5 || event.Args["to"] != common.HexToAddress("0x02") {
+				t.Fatalf("expected a decoded Transfer, got %+v", event)
+			}
+		case other:
+			if event.Decoded || len(event.Raw.Topics) != 0 || event.Raw.TxHash == (common.Hash{}) {
+				t.Fatalf("expected the unknown log passed through raw, got %+v", event)
+			}
+		}
+	}
+
+	cancel()
+	select {
+	case err := <-sub.Err():
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected context.Canceled, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("watcher not stopped by the context")
+	}
+}
+
+// sendTestTx sends a transaction from the account index of chain to the address to, to be mined by the caller
+func sendTestTx(t *testing.T, chain *TestChain, index int, to common.Address, value *big.Int) *types.Transaction {
+	t.Helper()
+	ctx := context.Background()
+	from := chain.Account(index)
+	nonce, err := chain.PendingNonceAt(ctx, from)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value == nil {
+		value = new(big.Int)
+	}
+	tx, err := chain.Accounts[index].Signer(from, types.NewTransaction(nonce, to, value, 100000, big.NewInt(2e9), nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := chain.SendTransaction(ctx, tx); err != nil {
+		t.Fatal(err)
+	}
+	return tx
+}
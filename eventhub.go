@@ -0,0 +1,292 @@
+package goethereumhelper
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// ErrSlowConsumer is reported to subscribers disconnected by the DisconnectSlowConsumer policy
+var ErrSlowConsumer = errors.New("subscriber disconnected: log buffer is full")
+
+// SlowConsumerPolicy defines what an EventHub does when a subscriber buffer is full
+type SlowConsumerPolicy int
+
+const (
+	// DropOnSlowConsumer discards the logs that do not fit in the subscriber buffer
+	DropOnSlowConsumer SlowConsumerPolicy = iota
+	// BlockOnSlowConsumer waits for the subscriber, holding back every other subscriber of the same filter
+	BlockOnSlowConsumer
+	// DisconnectSlowConsumer unsubscribes the subscriber, reporting ErrSlowConsumer
+	DisconnectSlowConsumer
+)
+
+// DefaultHubBufferSize is the subscriber buffer size used when none is given
+const DefaultHubBufferSize = 128
+
+// EventHub shares one upstream log subscription per unique filter among any number of subscribers.
+// The upstream subscription is opened with the first subscriber and closed when the last one leaves.
+type EventHub struct {
+	client ethereum.LogFilterer
+
+	mu    sync.Mutex
+	feeds map[string]*hubFeed
+}
+
+// hubFeed is the upstream subscription of one filter and its subscribers
+type hubFeed struct {
+	key         string
+	upstream    ethereum.Subscription
+	logs        chan types.Log
+	subscribers map[*HubSubscription]struct{}
+}
+
+// HubSubscription is a subscriber of an EventHub. It implements ethereum.Subscription:
+// the Err channel is closed on Unsubscribe and receives the error that ended the subscription otherwise.
+type HubSubscription struct {
+	hub     *EventHub
+	feed    *hubFeed
+	policy  SlowConsumerPolicy
+	logs    chan types.Log
+	err     chan error
+	quit    chan struct{}
+	once    sync.Once
+	dropped uint64
+}
+
+// NewEventHub returns an EventHub reading logs from client
+func NewEventHub(client ethereum.LogFilterer) *EventHub {
+	return &EventHub{
+		client: client,
+		feeds:  make(map[string]*hubFeed),
+	}
+}
+
+// Subscribe registers a subscriber for the logs matching query, opening the upstream subscription when
+// this is the first subscriber of an equivalent filter. ctx bounds the request opening the upstream subscription.
+// bufferSize defaults to DefaultHubBufferSize when zero.
+func (h *EventHub) Subscribe(ctx context.Context, query ethereum.FilterQuery, bufferSize int, policy SlowConsumerPolicy) (sub *HubSubscription, err error) {
+	if bufferSize <= 0 {
+		bufferSize = DefaultHubBufferSize
+	}
+	key, err := filterQueryKey(query)
+	if err != nil {
+		return
+	}
+	sub = &HubSubscription{
+		hub:    h,
+		policy: policy,
+		logs:   make(chan types.Log, bufferSize),
+		err:    make(chan error, 1),
+		quit:   make(chan struct{}),
+	}
+
+	h.mu.Lock()
+	if feed, ok := h.feeds[key]; ok {
+		sub.feed = feed
+		feed.subscribers[sub] = struct{}{}
+		h.mu.Unlock()
+		return
+	}
+	h.mu.Unlock()
+
+	// Subscribing is a network call, keep the hub available to the other subscribers meanwhile
+	logs := make(chan types.Log)
+	upstream, err := h.client.SubscribeFilterLogs(ctx, query, logs)
+	if err != nil {
+		log.Printf("[EventHub] Failure subscribing to logs: %+v", err)
+		sub = nil
+		return
+	}
+
+	h.mu.Lock()
+	feed, ok := h.feeds[key]
+	if !ok {
+		feed = &hubFeed{
+			key:         key,
+			upstream:    upstream,
+			logs:        logs,
+			subscribers: make(map[*HubSubscription]struct{}),
+		}
+		h.feeds[key] = feed
+		go h.fanOut(feed)
+	}
+	sub.feed = feed
+	feed.subscribers[sub] = struct{}{}
+	h.mu.Unlock()
+	if ok {
+		// Another subscriber opened the same filter meanwhile, share its upstream subscription
+		upstream.Unsubscribe()
+	}
+	return
+}
+
+// Feeds returns how many upstream subscriptions are open
+func (h *EventHub) Feeds() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.feeds)
+}
+
+// fanOut copies every upstream log to the subscribers of feed
+func (h *EventHub) fanOut(feed *hubFeed) {
+	for {
+		select {
+		case infoLog := <-feed.logs:
+			h.mu.Lock()
+			subscribers := make([]*HubSubscription, 0, len(feed.subscribers))
+			for sub := range feed.subscribers {
+				subscribers = append(subscribers, sub)
+			}
+			h.mu.Unlock()
+			for _, sub := range subscribers {
+				sub.deliver(infoLog)
+			}
+		case err, ok := <-feed.upstream.Err():
+			// The channel is closed when the last subscriber tears the feed down
+			if !ok {
+				return
+			}
+			log.Printf("[EventHub] Upstream subscription failed: %+v", err)
+			h.mu.Lock()
+			if h.feeds[feed.key] == feed {
+				delete(h.feeds, feed.key)
+			}
+			subscribers := feed.subscribers
+			feed.subscribers = make(map[*HubSubscription]struct{})
+			h.mu.Unlock()
+			for sub := range subscribers {
+				sub.close(err)
+			}
+			return
+		}
+	}
+}
+
+// deliver hands a log to the subscriber according to its slow consumer policy
+func (s *HubSubscription) deliver(infoLog types.Log) {
+	select {
+	case <-s.quit:
+		return
+	default:
+	}
+	switch s.policy {
+	case BlockOnSlowConsumer:
+		select {
+		case s.logs <- infoLog:
+		case <-s.quit:
+		}
+	case DisconnectSlowConsumer:
+		select {
+		case s.logs <- infoLog:
+		default:
+			s.close(ErrSlowConsumer)
+			if s.hub.remove(s) {
+				// Unsubscribing waits for the upstream to stop sending, which needs the fan-out to keep reading
+				go s.feed.upstream.Unsubscribe()
+			}
+		}
+	default:
+		select {
+		case s.logs <- infoLog:
+		default:
+			atomic.AddUint64(&s.dropped, 1)
+		}
+	}
+}
+
+// Logs returns the channel receiving the logs of the subscription
+func (s *HubSubscription) Logs() <-chan types.Log {
+	return s.logs
+}
+
+// Err implements ethereum.Subscription
+func (s *HubSubscription) Err() <-chan error {
+	return s.err
+}
+
+// Dropped returns how many logs were discarded by the DropOnSlowConsumer policy
+func (s *HubSubscription) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+// Unsubscribe implements ethereum.Subscription. The upstream subscription is closed when no subscriber is left.
+func (s *HubSubscription) Unsubscribe() {
+	// Close first, so the fan-out does not stay blocked delivering to this subscriber
+	s.close(nil)
+	if s.hub.remove(s) {
+		s.feed.upstream.Unsubscribe()
+	}
+}
+
+func (s *HubSubscription) close(err error) {
+	s.once.Do(func() {
+		if err != nil {
+			s.err <- err
+		}
+		close(s.quit)
+		close(s.err)
+	})
+}
+
+// remove detaches a subscriber from its feed. When it was the last one the feed is removed from the hub
+// and teardown is true: the caller must unsubscribe the upstream, outside the hub lock.
+func (h *EventHub) remove(sub *HubSubscription) (teardown bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	feed := sub.feed
+	if _, ok := feed.subscribers[sub]; !ok {
+		return
+	}
+	delete(feed.subscribers, sub)
+	if len(feed.subscribers) == 0 && h.feeds[feed.key] == feed {
+		delete(h.feeds, feed.key)
+		teardown = true
+	}
+	return
+}
+
+// filterQueryKey returns the same key for filters matching the same logs
+func filterQueryKey(query ethereum.FilterQuery) (key string, err error) {
+	addresses := make([]string, len(query.Addresses))
+	for i, address := range query.Addresses {
+		addresses[i] = address.Hex()
+	}
+	sort.Strings(addresses)
+	topics := make([][]string, len(query.Topics))
+	for i, position := range query.Topics {
+		topics[i] = make([]string, len(position))
+		for j, topic := range position {
+			topics[i][j] = topic.Hex()
+		}
+		sort.Strings(topics[i])
+	}
+	normalized := struct {
+		BlockHash *common.Hash
+		FromBlock string
+		ToBlock   string
+		Addresses []string
+		Topics    [][]string
+	}{
+		BlockHash: query.BlockHash,
+		Addresses: addresses,
+		Topics:    topics,
+	}
+	if query.FromBlock != nil {
+		normalized.FromBlock = query.FromBlock.String()
+	}
+	if query.ToBlock != nil {
+		normalized.ToBlock = query.ToBlock.String()
+	}
+	content, err := json.Marshal(normalized)
+	key = string(content)
+	return
+}
@@ -0,0 +1,249 @@
+package goethereumhelper
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+)
+
+// hubUpstream is a log source counting the subscriptions opened and closed by an EventHub
+type hubUpstream struct {
+	feed event.Feed
+
+	mu     sync.Mutex
+	opened int
+	closed int
+	hang   chan struct{} // When not nil, SubscribeFilterLogs waits for it to be closed
+}
+
+func (u *hubUpstream) FilterLogs(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error) {
+	return nil, nil
+}
+
+func (u *hubUpstream) SubscribeFilterLogs(ctx context.Context, query ethereum.FilterQuery, ch chan<- types.Log) (ethereum.Subscription, error) {
+	u.mu.Lock()
+	hang := u.hang
+	u.mu.Unlock()
+	if hang != nil {
+		select {
+		case <-hang:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	u.mu.Lock()
+	u.opened++
+	u.mu.Unlock()
+	inner := u.feed.Subscribe(ch)
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		defer func() {
+			inner.Unsubscribe()
+			u.mu.Lock()
+			u.closed++
+			u.mu.Unlock()
+		}()
+		select {
+		case <-quit:
+			return nil
+		case err := <-inner.Err():
+			return err
+		}
+	}), nil
+}
+
+func (u *hubUpstream) counts() (opened, closed int) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.opened, u.closed
+}
+
+func waitHubCondition(t *testing.T, what string, condition func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); !condition(); time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for %s", what)
+		}
+	}
+}
+
+func TestEventHubTeardown(t *testing.T) {
+	upstream := &hubUpstream{}
+	hub := NewEventHub(upstream)
+	ctx := context.Background()
+	first, second := common.HexToAddress("0x01"), common.HexToAddress("0x02")
+
+	subA, err := hub.Subscribe(ctx, ethereum.FilterQuery{Addresses: []common.Address{first, second}}, 0, DropOnSlowConsumer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The same filter with the addresses in another order shares the upstream subscription
+	subB, err := hub.Subscribe(ctx, ethereum.FilterQuery{Addresses: []common.Address{second, first}}, 0, DropOnSlowConsumer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opened, _ := upstream.counts(); opened != 1 || hub.Feeds() != 1 {
+		t.Fatalf("%d upstream subscriptions and %d feeds, expected 1", opened, hub.Feeds())
+	}
+
+	upstream.feed.Send(types.Log{Address: first, Index: 1})
+	for _, sub := range []*HubSubscription{subA, subB} {
+		select {
+		case received := <-sub.Logs():
+			if received.Index != 1 {
+				t.Fatalf("received log %d", received.Index)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("log not delivered")
+		}
+	}
+
+	subA.Unsubscribe()
+	if _, closed := upstream.counts(); closed != 0 || hub.Feeds() != 1 {
+		t.Fatalf("upstream closed with a subscriber left")
+	}
+	if _, ok := <-subA.Err(); ok {
+		t.Fatal("Err not closed by Unsubscribe")
+	}
+	subB.Unsubscribe()
+	waitHubCondition(t, "the upstream teardown", func() bool {
+		_, closed := upstream.counts()
+		return closed == 1
+	})
+	if hub.Feeds() != 0 {
+		t.Fatalf("%d feeds left", hub.Feeds())
+	}
+	subB.Unsubscribe()
+}
+
+func TestEventHubSlowConsumerPolicies(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("drop", func(t *testing.T) {
+		upstream := &hubUpstream{}
+		sub, err := NewEventHub(upstream).Subscribe(ctx, ethereum.FilterQuery{}, 1, DropOnSlowConsumer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer sub.Unsubscribe()
+		for i := uint(0); i < 3; i++ {
+			upstream.feed.Send(types.Log{Index: i})
+		}
+		waitHubCondition(t, "the dropped logs", func() bool { return sub.Dropped() == 2 })
+		if received := <-sub.Logs(); received.Index != 0 {
+			t.Fatalf("kept log %d, expected the first one", received.Index)
+		}
+	})
+
+	t.Run("block", func(t *testing.T) {
+		upstream := &hubUpstream{}
+		sub, err := NewEventHub(upstream).Subscribe(ctx, ethereum.FilterQuery{}, 1, BlockOnSlowConsumer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer sub.Unsubscribe()
+		go func() {
+			for i := uint(0); i < 3; i++ {
+				upstream.feed.Send(types.Log{Index: i})
+			}
+		}()
+		for i := uint(0); i < 3; i++ {
+			time.Sleep(10 * time.Millisecond)
+			select {
+			case received := <-sub.Logs():
+				if received.Index != i {
+					t.Fatalf("received log %d, expected %d", received.Index, i)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("log %d not delivered", i)
+			}
+		}
+		if sub.Dropped() != 0 {
+			t.Fatalf("%d logs dropped", sub.Dropped())
+		}
+	})
+
+	t.Run("disconnect", func(t *testing.T) {
+		upstream := &hubUpstream{}
+		hub := NewEventHub(upstream)
+		sub, err := hub.Subscribe(ctx, ethereum.FilterQuery{}, 1, DisconnectSlowConsumer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := uint(0); i < 3; i++ {
+			upstream.feed.Send(types.Log{Index: i})
+		}
+		select {
+		case err := <-sub.Err():
+			if !errors.Is(err, ErrSlowConsumer) {
+				t.Fatalf("expected ErrSlowConsumer, got %v", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("slow subscriber not disconnected")
+		}
+		// It was the only subscriber, so the upstream is torn down
+		waitHubCondition(t, "the upstream teardown", func() bool {
+			_, closed := upstream.counts()
+			return closed == 1
+		})
+		if hub.Feeds() != 0 {
+			t.Fatalf("%d feeds left", hub.Feeds())
+		}
+		sub.Unsubscribe()
+	})
+}
+
+func TestEventHubSubscribeDoesNotBlockHub(t *testing.T) {
+	upstream := &hubUpstream{}
+	hub := NewEventHub(upstream)
+	shared := ethereum.FilterQuery{Addresses: []common.Address{common.HexToAddress("0x01")}}
+	first, err := hub.Subscribe(context.Background(), shared, 0, DropOnSlowConsumer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer first.Unsubscribe()
+
+	// The node hangs on new subscriptions
+	upstream.mu.Lock()
+	upstream.hang = make(chan struct{})
+	upstream.mu.Unlock()
+	ctx, cancel := context.WithCancel(context.Background())
+	hung := make(chan error)
+	go func() {
+		_, err := hub.Subscribe(ctx, ethereum.FilterQuery{Addresses: []common.Address{common.HexToAddress("0x02")}}, 0, DropOnSlowConsumer)
+		hung <- err
+	}()
+
+	// Subscribers of an open filter are served meanwhile
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		second, err := hub.Subscribe(context.Background(), shared, 0, DropOnSlowConsumer)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		second.Unsubscribe()
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("hub blocked by a hung subscription")
+	}
+
+	cancel()
+	select {
+	case err := <-hung:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected context.Canceled, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("hung subscription not cancelled")
+	}
+}
@@ -0,0 +1,360 @@
+package goethereumhelper
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+// LoadGenesis reads a geth genesis.json file
+func LoadGenesis(path string) (genesis *core.Genesis, err error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	genesis = new(core.Genesis)
+	err = json.Unmarshal(content, genesis)
+	if err != nil {
+		genesis = nil
+		err = fmt.Errorf("invalid genesis file %s: %w", path, err)
+	}
+	return
+}
+
+// WithGenesis initializes the chain from a geth genesis: its alloc, gas limit and chain config.
+// The config goes through the same checks as WithChainConfig, so set genesis.Config to nil to load the
+// state of a network running other rules. The simulated backend builds the genesis block itself, so the
+// header fields of the genesis, like timestamp, difficulty and coinbase, are not applied. A genesis with
+// extraData, which holds the signers of Clique networks, is rejected.
+func WithGenesis(genesis *core.Genesis) TestChainOption {
+	return func(settings *testChainSettings) error {
+		if genesis == nil {
+			return errors.New("genesis is nil")
+		}
+		if len(genesis.ExtraData) > 0 {
+			return errors.New("genesis extraData is not supported by the simulated backend, remove it to load the genesis")
+		}
+		if genesis.Config != nil {
+			settings.config = genesis.Config
+		}
+		if genesis.GasLimit > 0 {
+			settings.gasLimit = genesis.GasLimit
+		}
+		for address, account := range genesis.Alloc {
+			settings.alloc[address] = account
+		}
+		return nil
+	}
+}
+
+// LoadStateDump reads a state dump written by `geth dump` or TestChain.DumpState, either as a single JSON
+// object or one account per line (`geth dump --iterative`), and returns it as a genesis alloc ready
+// for WithGenesisAlloc
+func LoadStateDump(path string) (alloc core.GenesisAlloc, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	return ReadStateDump(file)
+}
+
+// ReadStateDump reads a state dump from r, see LoadStateDump
+func ReadStateDump(r io.Reader) (alloc core.GenesisAlloc, err error) {
+	alloc = make(core.GenesisAlloc)
+	decoder := json.NewDecoder(r)
+	for {
+		var raw json.RawMessage
+		err = decoder.Decode(&raw)
+		if errors.Is(err, io.EOF) {
+			return alloc, nil
+		}
+		var entry map[string]json.RawMessage
+		if err == nil {
+			err = json.Unmarshal(raw, &entry)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid state dump: %w", err)
+		}
+		switch {
+		case entry["accounts"] != nil:
+			var accounts map[common.Address]state.DumpAccount
+			err = json.Unmarshal(entry["accounts"], &accounts)
+			if err != nil {
+				return nil, fmt.Errorf("invalid state dump accounts: %w", err)
+			}
+			for address, account := range accounts {
+				alloc[address], err = dumpAccountToGenesis(account)
+				if err != nil {
+					return nil, fmt.Errorf("invalid state dump account %s: %w", address.Hex(), err)
+				}
+			}
+		case entry["balance"] != nil:
+			// One account per line, the last line only holds the state root
+			var account state.DumpAccount
+			err = json.Unmarshal(raw, &account)
+			if err != nil {
+				return nil, fmt.Errorf("invalid state dump account: %w", err)
+			}
+			if account.Address == nil {
+				// geth dumps the hashed key when it has no preimage of the address
+				return nil, fmt.Errorf("state dump account with key %s has no address", account.SecureKey)
+			}
+			alloc[*account.Address], err = dumpAccountToGenesis(account)
+			if err != nil {
+				return nil, fmt.Errorf("invalid state dump account %s: %w", account.Address.Hex(), err)
+			}
+		}
+	}
+}
+
+func dumpAccountToGenesis(account state.DumpAccount) (genesisAccount core.GenesisAccount, err error) {
+	balance, ok := new(big.Int).SetString(account.Balance, 10)
+	if !ok {
+		err = fmt.Errorf("invalid balance %q", account.Balance)
+		return
+	}
+	genesisAccount = core.GenesisAccount{
+		Balance: balance,
+		Nonce:   account.Nonce,
+		Code:    account.Code,
+	}
+	if len(account.Storage) > 0 {
+		genesisAccount.Storage = make(map[common.Hash]common.Hash, len(account.Storage))
+		for slot, value := range account.Storage {
+			genesisAccount.Storage[slot] = common.HexToHash(value)
+		}
+	}
+	return
+}
+
+// DumpState returns the state of the latest block in the `geth dump` format, which LoadStateDump reads.
+//
+// The simulated backend does not keep the preimages of the hashed state keys, so the accounts and storage
+// slots are found by replaying the chain: genesis alloc, transaction senders and receivers, contracts
+// created, slots written and the targets of the cheat methods. The result is checked against the state
+// root of the block and an error is returned if anything could not be recovered.
+func (c *TestChain) DumpState() (dump *state.Dump, err error) {
+	alloc, root, err := c.exportAlloc()
+	if err != nil {
+		return
+	}
+	dump = &state.Dump{
+		Root:     fmt.Sprintf("%x", root),
+		Accounts: make(map[common.Address]state.DumpAccount, len(alloc)),
+	}
+	statedb, err := c.SimulatedBackend.Blockchain().StateAt(root)
+	if err != nil {
+		return nil, err
+	}
+	for address, account := range alloc {
+		storageTrie, errTrie := statedb.StorageTrie(address)
+		if errTrie != nil {
+			return nil, errTrie
+		}
+		dumpAccount := state.DumpAccount{
+			Balance:  account.Balance.String(),
+			Nonce:    account.Nonce,
+			Root:     storageTrie.Hash().Bytes(),
+			CodeHash: statedb.GetCodeHash(address).Bytes(),
+			Code:     account.Code,
+		}
+		if len(account.Storage) > 0 {
+			dumpAccount.Storage = make(map[common.Hash]string, len(account.Storage))
+			for slot, value := range account.Storage {
+				dumpAccount.Storage[slot] = common.Bytes2Hex(common.TrimLeftZeroes(value[:]))
+			}
+		}
+		dump.Accounts[address] = dumpAccount
+	}
+	return
+}
+
+// ExportGenesis returns a genesis holding the state of the latest block, with the chain config and gas limit
+func (c *TestChain) ExportGenesis() (genesis *core.Genesis, err error) {
+	alloc, _, err := c.exportAlloc()
+	if err != nil {
+		return
+	}
+	head := c.SimulatedBackend.Blockchain().CurrentBlock()
+	genesis = &core.Genesis{
+		Config:     copyChainConfig(c.config),
+		GasLimit:   head.GasLimit,
+		Difficulty: new(big.Int).Set(c.SimulatedBackend.Blockchain().Genesis().Difficulty()),
+		Alloc:      alloc,
+	}
+	return
+}
+
+// exportAlloc returns the state of the latest block as a genesis alloc, together with its state root
+func (c *TestChain) exportAlloc() (alloc core.GenesisAlloc, root common.Hash, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	blockchain := c.SimulatedBackend.Blockchain()
+	head := blockchain.CurrentBlock()
+	root = head.Root
+	err = c.traceStateKeys(head.Hash(), head.Number.Uint64())
+	if err != nil {
+		return
+	}
+	statedb, err := blockchain.StateAt(root)
+	if err != nil {
+		return
+	}
+	alloc = make(core.GenesisAlloc)
+	for address, slots := range c.touched {
+		if !statedb.Exist(address) {
+			continue
+		}
+		account := core.GenesisAccount{
+			Balance: statedb.GetBalance(address),
+			Nonce:   statedb.GetNonce(address),
+			Code:    statedb.GetCode(address),
+		}
+		for slot := range slots {
+			value := statedb.GetState(address, slot)
+			if value == (common.Hash{}) {
+				continue
+			}
+			if account.Storage == nil {
+				account.Storage = make(map[common.Hash]common.Hash)
+			}
+			account.Storage[slot] = value
+		}
+		alloc[address] = account
+	}
+	exported := (&core.Genesis{Alloc: alloc}).ToBlock().Root()
+	if exported != root {
+		err = fmt.Errorf("could not recover every account and storage slot of block %d: exported state root %s differs from %s",
+			head.Number.Uint64(), exported.Hex(), root.Hex())
+	}
+	return
+}
+
+// stateKeys are the accounts and storage slots known to exist in the chain state
+type stateKeys map[common.Address]map[common.Hash]bool
+
+func (keys stateKeys) add(address common.Address, slots ...common.Hash) {
+	if keys[address] == nil {
+		keys[address] = make(map[common.Hash]bool)
+	}
+	for _, slot := range slots {
+		keys[address][slot] = true
+	}
+}
+
+func (keys stateKeys) addAlloc(alloc core.GenesisAlloc) {
+	for address, account := range alloc {
+		keys.add(address)
+		for slot := range account.Storage {
+			keys.add(address, slot)
+		}
+	}
+}
+
+// traceStateKeys replays the blocks from head back to the first one already traced, collecting the
+// accounts and slots they touched
+func (c *TestChain) traceStateKeys(hash common.Hash, number uint64) (err error) {
+	blockchain := c.SimulatedBackend.Blockchain()
+	var blocks []*types.Block
+	for number > 0 && !c.tracedBlocks[hash] {
+		block := blockchain.GetBlock(hash, number)
+		if block == nil {
+			return fmt.Errorf("block %d %s not found", number, hash.Hex())
+		}
+		blocks = append(blocks, block)
+		hash, number = block.ParentHash(), number-1
+	}
+	for i := len(blocks) - 1; i >= 0; i-- {
+		err = c.traceBlock(blocks[i])
+		if err != nil {
+			return
+		}
+		c.tracedBlocks[blocks[i].Hash()] = true
+	}
+	return
+}
+
+// traceBlock executes the transactions of block on the state of its parent with a tracer recording
+// the accounts and slots touched
+func (c *TestChain) traceBlock(block *types.Block) (err error) {
+	c.touched.add(block.Coinbase())
+	if len(block.Transactions()) == 0 {
+		return
+	}
+	blockchain := c.SimulatedBackend.Blockchain()
+	parent := blockchain.GetHeader(block.ParentHash(), block.NumberU64()-1)
+	if parent == nil {
+		return fmt.Errorf("parent of block %d not found", block.NumberU64())
+	}
+	statedb, err := blockchain.StateAt(parent.Root)
+	if err != nil {
+		return fmt.Errorf("could not load the state of block %d: %w", parent.Number.Uint64(), err)
+	}
+	header := block.Header()
+	tracer := &stateKeysTracer{keys: c.touched}
+	blockContext := core.NewEVMBlockContext(header, blockchain, nil)
+	gasPool := new(core.GasPool).AddGas(header.GasLimit)
+	for i, tx := range block.Transactions() {
+		var msg *core.Message
+		if from, impersonated := c.impersonatedTxs[tx.Hash()]; impersonated {
+			msg = impersonatedMessage(from, tx, header.BaseFee)
+		} else {
+			msg, err = core.TransactionToMessage(tx, types.MakeSigner(c.config, header.Number), header.BaseFee)
+			if err != nil {
+				return
+			}
+		}
+		statedb.SetTxContext(tx.Hash(), i)
+		evm := vm.NewEVM(blockContext, core.NewEVMTxContext(msg), statedb, c.config, vm.Config{Debug: true, Tracer: tracer})
+		_, err = core.ApplyMessage(evm, msg, gasPool)
+		if err != nil {
+			return fmt.Errorf("could not replay transaction %s: %w", tx.Hash().Hex(), err)
+		}
+		statedb.Finalise(true)
+	}
+	return
+}
+
+// stateKeysTracer records the accounts and storage slots touched by a transaction
+type stateKeysTracer struct {
+	keys stateKeys
+}
+
+func (t *stateKeysTracer) CaptureTxStart(gasLimit uint64) {}
+
+func (t *stateKeysTracer) CaptureTxEnd(restGas uint64) {}
+
+func (t *stateKeysTracer) CaptureStart(env *vm.EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) {
+	t.keys.add(from)
+	t.keys.add(to)
+}
+
+func (t *stateKeysTracer) CaptureEnd(output []byte, gasUsed uint64, err error) {}
+
+func (t *stateKeysTracer) CaptureEnter(typ vm.OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) {
+	t.keys.add(to)
+}
+
+func (t *stateKeysTracer) CaptureExit(output []byte, gasUsed uint64, err error) {}
+
+func (t *stateKeysTracer) CaptureState(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, rData []byte, depth int, err error) {
+	switch op {
+	case vm.SSTORE:
+		t.keys.add(scope.Contract.Address(), common.Hash(scope.Stack.Back(0).Bytes32()))
+	case vm.SELFDESTRUCT:
+		t.keys.add(common.Address(scope.Stack.Back(0).Bytes20()))
+	}
+}
+
+func (t *stateKeysTracer) CaptureFault(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, depth int, err error) {
+}
@@ -0,0 +1,75 @@
+package goethereumhelper
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+)
+
+// A private network genesis as written by hand: no difficulty bomb delays and the Clique engine
+const cliqueGenesis = `{
+	"config": {
+		"chainId": 1337,
+		"homesteadBlock": 0,
+		"eip150Block": 0,
+		"eip155Block": 0,
+		"eip158Block": 0,
+		"byzantiumBlock": 0,
+		"constantinopleBlock": 0,
+		"petersburgBlock": 0,
+		"istanbulBlock": 0,
+		"berlinBlock": 0,
+		"londonBlock": 0,
+		"clique": {"period": 5, "epoch": 30000}
+	},
+	"difficulty": "0x1",
+	"gasLimit": "0x1c9c380",
+	"alloc": {
+		"0x0000000000000000000000000000000000000abc": {"balance": "0x2a"}
+	}
+}`
+
+func TestWithGenesis(t *testing.T) {
+	var genesis core.Genesis
+	if err := json.Unmarshal([]byte(cliqueGenesis), &genesis); err != nil {
+		t.Fatal(err)
+	}
+	chain, err := NewTestChain(WithGenesis(&genesis))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Close()
+	balance, err := chain.BalanceAt(context.Background(), common.HexToAddress("0xabc"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balance.Int64() != 42 {
+		t.Fatalf("alloc not applied, balance is %s", balance)
+	}
+
+	tests := []struct {
+		name   string
+		change func(genesis *core.Genesis)
+	}{
+		{"extraData", func(genesis *core.Genesis) { genesis.ExtraData = make([]byte, 97) }},
+		{"chain ID", func(genesis *core.Genesis) { genesis.Config.ChainID = big.NewInt(5) }},
+		{"fork block", func(genesis *core.Genesis) { genesis.Config.LondonBlock = big.NewInt(10) }},
+		{"Shanghai", func(genesis *core.Genesis) { shanghai := uint64(0); genesis.Config.ShanghaiTime = &shanghai }},
+		{"merge", func(genesis *core.Genesis) { genesis.Config.TerminalTotalDifficulty = big.NewInt(0) }},
+	}
+	for _, test := range tests {
+		var genesis core.Genesis
+		if err := json.Unmarshal([]byte(cliqueGenesis), &genesis); err != nil {
+			t.Fatal(err)
+		}
+		test.change(&genesis)
+		if chain, err := NewTestChain(WithGenesis(&genesis)); err == nil {
+			chain.Close()
+			t.Fatalf("%s: expected an error", test.name)
+		}
+	}
+}
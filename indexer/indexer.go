@@ -0,0 +1,427 @@
+/*
+Package indexer persists blocks, transactions and decoded logs of configured contracts into a SQLite database,
+following the chain head with goethereumhelper.WatchHeads and rolling back the rows of orphaned blocks on reorgs.
+*/
+package indexer
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"time"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/jeffprestes/goethereumhelper"
+
+	// Pure Go SQLite driver, registered as "sqlite"
+	_ "modernc.org/sqlite"
+)
+
+// Client is the subset of ethclient.Client (or the simulated backend) used by the Indexer
+type Client interface {
+	ethereum.ChainReader
+	ethereum.LogFilterer
+}
+
+// Contract is a contract whose logs are indexed
+type Contract struct {
+	Address common.Address
+	ABI     string // JSON ABI used to decode the logs. Logs of unknown events are stored undecoded
+}
+
+// Indexer stores the logs of the configured contracts in SQLite
+type Indexer struct {
+	StartBlock   uint64        // First block indexed when the database is empty. Zero starts at the first head received
+	PollInterval time.Duration // Used by WatchHeads when the client does not support subscriptions
+
+	db        *sql.DB
+	client    Client
+	decoder   *goethereumhelper.EventDecoder
+	addresses []common.Address
+}
+
+const schema = `
+CREATE TABLE IF NOT EXISTS blocks (
+	number      INTEGER PRIMARY KEY,
+	hash        TEXT NOT NULL,
+	parent_hash TEXT NOT NULL,
+	timestamp   INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS transactions (
+	hash         TEXT PRIMARY KEY,
+	block_number INTEGER NOT NULL,
+	tx_index     INTEGER NOT NULL,
+	sender       TEXT NOT NULL,
+	recipient    TEXT,
+	value        TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS logs (
+	id           INTEGER PRIMARY KEY AUTOINCREMENT,
+	block_number INTEGER NOT NULL,
+	block_hash   TEXT NOT NULL,
+	tx_hash      TEXT NOT NULL,
+	log_index    INTEGER NOT NULL,
+	address      TEXT NOT NULL,
+	event        TEXT,
+	signature    TEXT,
+	topics       TEXT NOT NULL,
+	data         TEXT NOT NULL,
+	args         TEXT,
+	UNIQUE (block_hash, log_index)
+);
+CREATE TABLE IF NOT EXISTS log_args (
+	log_id  INTEGER NOT NULL,
+	name    TEXT NOT NULL,
+	value   TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS transactions_block ON transactions (block_number);
+CREATE INDEX IF NOT EXISTS logs_block ON logs (block_number);
+CREATE INDEX IF NOT EXISTS logs_event ON logs (event, block_number);
+CREATE INDEX IF NOT EXISTS log_args_value ON log_args (name, value);
+CREATE INDEX IF NOT EXISTS log_args_log ON log_args (log_id);
+`
+
+// New opens (or creates) the SQLite database at path and returns an Indexer for contracts.
+// Use ":memory:" as path for a transient database.
+func New(path string, client Client, contracts ...Contract) (indexer *Indexer, err error) {
+	decoder := goethereumhelper.NewEventDecoder()
+	addresses := make([]common.Address, 0, len(contracts))
+	for _, contract := range contracts {
+		if contract.ABI != "" {
+			err = decoder.AddContract(contract.Address, contract.ABI)
+			if err != nil {
+				return
+			}
+		}
+		addresses = append(addresses, contract.Address)
+	}
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		err = fmt.Errorf("could not open SQLite database %s: %w", path, err)
+		return
+	}
+	// SQLite supports a single writer; one connection also keeps ":memory:" databases alive
+	db.SetMaxOpenConns(1)
+	_, err = db.Exec(schema)
+	if err != nil {
+		db.Close()
+		err = fmt.Errorf("could not create indexer schema: %w", err)
+		return
+	}
+
+	indexer = &Indexer{
+		db:        db,
+		client:    client,
+		decoder:   decoder,
+		addresses: addresses,
+	}
+	return
+}
+
+// Close closes the database
+func (ix *Indexer) Close() error {
+	return ix.db.Close()
+}
+
+// DB returns the underlying database for queries not covered by the helpers
+func (ix *Indexer) DB() *sql.DB {
+	return ix.db
+}
+
+// Run follows the chain head, indexing every new block until ctx is cancelled or the head watcher fails
+func (ix *Indexer) Run(ctx context.Context) (err error) {
+	heads := make(chan *types.Header)
+	sub, err := goethereumhelper.WatchHeads(ctx, ix.client, heads, ix.PollInterval)
+	if err != nil {
+		return
+	}
+	defer sub.Unsubscribe()
+
+	latest, err := ix.client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return
+	}
+	err = ix.Sync(ctx, latest)
+	if err != nil {
+		return
+	}
+	for {
+		select {
+		case header := <-heads:
+			err = ix.Sync(ctx, header)
+			if err != nil {
+				log.Printf("[Indexer] Failure indexing block %d: %+v", header.Number.Uint64(), err)
+				return
+			}
+		case err = <-sub.Err():
+			return
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Sync brings the database to head: blocks no longer canonical are rolled back and every block
+// between the last one kept and head is indexed
+func (ix *Indexer) Sync(ctx context.Context, head *types.Header) (err error) {
+	target := head.Number.Uint64()
+	next := ix.StartBlock
+	if next == 0 {
+		next = target
+	}
+
+	last, found, err := ix.LastBlock(ctx)
+	if err != nil {
+		return
+	}
+	if found {
+		keep, errAncestor := ix.commonAncestor(ctx, head, last)
+		if errAncestor != nil {
+			err = errAncestor
+			return
+		}
+		if keep < last {
+			log.Printf("[Indexer] Reorg detected: rolling back blocks after %d", keep)
+			err = ix.Rollback(ctx, keep)
+			if err != nil {
+				return
+			}
+		}
+		next = keep + 1
+	}
+
+	for number := next; number <= target; number++ {
+		header := head
+		if number != target {
+			header, err = ix.client.HeaderByNumber(ctx, new(big.Int).SetUint64(number))
+			if err != nil {
+				return
+			}
+		}
+		err = ix.IndexBlock(ctx, header)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// commonAncestor returns the highest indexed block that still belongs to the chain of head
+func (ix *Indexer) commonAncestor(ctx context.Context, head *types.Header, last uint64) (ancestor uint64, err error) {
+	number := last
+	if number > head.Number.Uint64() {
+		number = head.Number.Uint64()
+	}
+	for {
+		stored, found, errStored := ix.blockHash(ctx, number)
+		if errStored != nil {
+			err = errStored
+			return
+		}
+		if !found {
+			// Below the first indexed block: nothing left to compare
+			if number == 0 {
+				return 0, nil
+			}
+			return number, nil
+		}
+		canonical := head.Hash()
+		if number != head.Number.Uint64() {
+			header, errHeader := ix.client.HeaderByNumber(ctx, new(big.Int).SetUint64(number))
+			if errHeader != nil {
+				err = errHeader
+				return
+			}
+			canonical = header.Hash()
+		}
+		if stored == canonical {
+			return number, nil
+		}
+		if number == 0 {
+			return 0, errors.New("indexed genesis block does not match the chain genesis")
+		}
+		number--
+	}
+}
+
+// IndexBlock stores a block and the logs emitted in it by the configured contracts
+func (ix *Indexer) IndexBlock(ctx context.Context, header *types.Header) (err error) {
+	blockHash := header.Hash()
+	logs, err := ix.client.FilterLogs(ctx, ethereum.FilterQuery{
+		BlockHash: &blockHash,
+		Addresses: ix.addresses,
+	})
+	if err != nil {
+		return
+	}
+
+	dbTx, err := ix.db.BeginTx(ctx, nil)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			dbTx.Rollback()
+			return
+		}
+		err = dbTx.Commit()
+	}()
+
+	_, err = dbTx.ExecContext(ctx, `INSERT OR REPLACE INTO blocks (number, hash, parent_hash, timestamp) VALUES (?, ?, ?, ?)`,
+		header.Number.Uint64(), blockHash.Hex(), header.ParentHash.Hex(), header.Time)
+	if err != nil {
+		return
+	}
+
+	stored := make(map[common.Hash]bool)
+	for _, infoLog := range logs {
+		if !stored[infoLog.TxHash] {
+			err = ix.insertTransaction(ctx, dbTx, blockHash, infoLog)
+			if err != nil {
+				return
+			}
+			stored[infoLog.TxHash] = true
+		}
+		err = ix.insertLog(ctx, dbTx, infoLog)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (ix *Indexer) insertTransaction(ctx context.Context, dbTx *sql.Tx, blockHash common.Hash, infoLog types.Log) (err error) {
+	tx, err := ix.client.TransactionInBlock(ctx, blockHash, infoLog.TxIndex)
+	if err != nil {
+		return
+	}
+	sender, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx)
+	if err != nil {
+		return
+	}
+	var recipient *string
+	if tx.To() != nil {
+		to := tx.To().Hex()
+		recipient = &to
+	}
+	_, err = dbTx.ExecContext(ctx, `INSERT OR REPLACE INTO transactions (hash, block_number, tx_index, sender, recipient, value) VALUES (?, ?, ?, ?, ?, ?)`,
+		tx.Hash().Hex(), infoLog.BlockNumber, infoLog.TxIndex, sender.Hex(), recipient, tx.Value().String())
+	return
+}
+
+func (ix *Indexer) insertLog(ctx context.Context, dbTx *sql.Tx, infoLog types.Log) (err error) {
+	event, err := ix.decoder.Decode(infoLog)
+	if err != nil {
+		return
+	}
+	topics, err := json.Marshal(infoLog.Topics)
+	if err != nil {
+		return
+	}
+	var name, signature, args *string
+	normalized := event.NormalizedArgs()
+	if event.Decoded {
+		name, signature = &event.Name, &event.Signature
+		content, errArgs := json.Marshal(normalized)
+		if errArgs != nil {
+			err = errArgs
+			return
+		}
+		encoded := string(content)
+		args = &encoded
+	}
+	// Indexing a block again leaves its logs untouched
+	result, err := dbTx.ExecContext(ctx, `INSERT OR IGNORE INTO logs (block_number, block_hash, tx_hash, log_index, address, event, signature, topics, data, args) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		infoLog.BlockNumber, infoLog.BlockHash.Hex(), infoLog.TxHash.Hex(), infoLog.Index, infoLog.Address.Hex(),
+		name, signature, string(topics), common.Bytes2Hex(infoLog.Data), args)
+	if err != nil {
+		return
+	}
+	inserted, err := result.RowsAffected()
+	if err != nil || inserted == 0 {
+		return
+	}
+	logID, err := result.LastInsertId()
+	if err != nil {
+		return
+	}
+	for argName, value := range normalized {
+		_, err = dbTx.ExecContext(ctx, `INSERT INTO log_args (log_id, name, value) VALUES (?, ?, ?)`, logID, argName, argValue(value))
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Rollback deletes every row belonging to blocks after number
+func (ix *Indexer) Rollback(ctx context.Context, number uint64) (err error) {
+	dbTx, err := ix.db.BeginTx(ctx, nil)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			dbTx.Rollback()
+			return
+		}
+		err = dbTx.Commit()
+	}()
+	statements := []string{
+		`DELETE FROM log_args WHERE log_id IN (SELECT id FROM logs WHERE block_number > ?)`,
+		`DELETE FROM logs WHERE block_number > ?`,
+		`DELETE FROM transactions WHERE block_number > ?`,
+		`DELETE FROM blocks WHERE number > ?`,
+	}
+	for _, statement := range statements {
+		_, err = dbTx.ExecContext(ctx, statement, number)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// LastBlock returns the highest indexed block number
+func (ix *Indexer) LastBlock(ctx context.Context) (number uint64, found bool, err error) {
+	var last sql.NullInt64
+	err = ix.db.QueryRowContext(ctx, `SELECT MAX(number) FROM blocks`).Scan(&last)
+	if err != nil || !last.Valid {
+		return
+	}
+	return uint64(last.Int64), true, nil
+}
+
+func (ix *Indexer) blockHash(ctx context.Context, number uint64) (hash common.Hash, found bool, err error) {
+	var hex string
+	err = ix.db.QueryRowContext(ctx, `SELECT hash FROM blocks WHERE number = ?`, number).Scan(&hex)
+	if errors.Is(err, sql.ErrNoRows) {
+		return hash, false, nil
+	}
+	if err != nil {
+		return
+	}
+	return common.HexToHash(hex), true, nil
+}
+
+// argValue returns the text stored in log_args for a normalized argument
+func argValue(value interface{}) string {
+	if text, ok := value.(string); ok {
+		return text
+	}
+	if flag, ok := value.(bool); ok {
+		if flag {
+			return "true"
+		}
+		return "false"
+	}
+	content, _ := json.Marshal(value)
+	return string(content)
+}
@@ -0,0 +1,176 @@
+package indexer
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/jeffprestes/goethereumhelper"
+)
+
+const pingEventABI = `[{"anonymous":false,"inputs":[{"indexed":true,"name":"who","type":"address"}],"name":"Ping","type":"event"}]`
+
+func TestIndexBlockTwice(t *testing.T) {
+	chain, err := goethereumhelper.NewTestChain()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Close()
+	ctx := context.Background()
+
+	deployer := chain.Accounts[0]
+	address := deployPinger(t, chain)
+	ping(t, chain, address)
+	ping(t, chain, address)
+	chain.Commit()
+
+	ix, err := New(":memory:", chain, Contract{Address: address, ABI: pingEventABI})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ix.Close()
+	head, err := chain.HeaderByNumber(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := ix.IndexBlock(ctx, head); err != nil {
+			t.Fatal(err)
+		}
+	}
+	events, err := ix.EventsByArg(ctx, "Ping", "who", deployer.From)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("found %d events, expected 2", len(events))
+	}
+	var args int
+	if err := ix.DB().QueryRowContext(ctx, `SELECT COUNT(*) FROM log_args`).Scan(&args); err != nil {
+		t.Fatal(err)
+	}
+	if args != 2 {
+		t.Fatalf("found %d log arguments, expected 2", args)
+	}
+}
+
+func TestSyncRollsBackReorgedBlocks(t *testing.T) {
+	chain, err := goethereumhelper.NewTestChain()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Close()
+	ctx := context.Background()
+	address := deployPinger(t, chain)
+	// Blocks 2 and 3 have one Ping each
+	pings := make([]common.Hash, 2)
+	for i := range pings {
+		pings[i] = ping(t, chain, address)
+		chain.Commit()
+	}
+
+	ix, err := New(":memory:", chain, Contract{Address: address, ABI: pingEventABI})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ix.Close()
+	ix.StartBlock = 1
+	syncHead := func() {
+		t.Helper()
+		head, err := chain.HeaderByNumber(ctx, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ix.Sync(ctx, head); err != nil {
+			t.Fatal(err)
+		}
+	}
+	syncHead()
+	assertRows(t, ix, "blocks", 3)
+	assertRows(t, ix, "transactions", 2)
+	assertRows(t, ix, "logs", 2)
+	assertRows(t, ix, "log_args", 2)
+
+	// Replace blocks 2 and 3 by a branch of three blocks with one Ping in its last block
+	var reincluded common.Hash
+	err = chain.Reorg(2, func() (common.Hash, error) {
+		chain.MineBlocks(2)
+		reincluded = ping(t, chain, address)
+		return chain.MineBlocks(1), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	syncHead()
+	assertRows(t, ix, "blocks", 4)
+	assertRows(t, ix, "transactions", 1)
+	assertRows(t, ix, "logs", 1)
+	assertRows(t, ix, "log_args", 1)
+	for _, orphaned := range pings {
+		var count int
+		if err := ix.DB().QueryRowContext(ctx, `SELECT COUNT(*) FROM logs WHERE tx_hash = ?`, orphaned.Hex()).Scan(&count); err != nil {
+			t.Fatal(err)
+		}
+		if count != 0 {
+			t.Fatalf("log of the orphaned transaction %s still indexed", orphaned.Hex())
+		}
+	}
+	head, err := chain.HeaderByNumber(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var blockHash, txHash string
+	if err := ix.DB().QueryRowContext(ctx, `SELECT block_hash, tx_hash FROM logs`).Scan(&blockHash, &txHash); err != nil {
+		t.Fatal(err)
+	}
+	if blockHash != head.Hash().Hex() || txHash != reincluded.Hex() {
+		t.Fatalf("indexed log of block %s and transaction %s, expected block %s and transaction %s", blockHash, txHash, head.Hash().Hex(), reincluded.Hex())
+	}
+}
+
+// deployPinger deploys a contract emitting Ping(msg.sender) on every call
+func deployPinger(t *testing.T, chain *goethereumhelper.TestChain) (address common.Address) {
+	t.Helper()
+	signature := crypto.Keccak256Hash([]byte("Ping(address)")).Hex()[2:]
+	code := common.FromHex("6028600c60003960286000f3" + "337f" + signature + "60006000a200")
+	address, _, _, err := bind.DeployContract(chain.Accounts[0], emptyABI(t), code, chain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain.Commit()
+	return
+}
+
+// ping calls the contract deployed by deployPinger, leaving the transaction pending
+func ping(t *testing.T, chain *goethereumhelper.TestChain, address common.Address) common.Hash {
+	t.Helper()
+	tx, err := bind.NewBoundContract(address, emptyABI(t), chain, chain, chain).RawTransact(chain.Accounts[0], nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tx.Hash()
+}
+
+func emptyABI(t *testing.T) abi.ABI {
+	t.Helper()
+	parsed, err := abi.JSON(strings.NewReader("[]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsed
+}
+
+func assertRows(t *testing.T, ix *Indexer, table string, expected int) {
+	t.Helper()
+	var count int
+	if err := ix.DB().QueryRow(`SELECT COUNT(*) FROM ` + table).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != expected {
+		t.Fatalf("%d rows in %s, expected %d", count, table, expected)
+	}
+}
@@ -0,0 +1,120 @@
+package indexer
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/jeffprestes/goethereumhelper"
+)
+
+// Event is a log stored by the Indexer
+type Event struct {
+	ID          int64
+	BlockNumber uint64
+	BlockHash   common.Hash
+	TxHash      common.Hash
+	LogIndex    uint
+	Address     common.Address
+	Name        string                 // Empty when the log was not decoded
+	Signature   string                 // Empty when the log was not decoded
+	Args        map[string]interface{} // Arguments normalized by goethereumhelper.DecodedEvent.NormalizedArgs
+	Topics      []common.Hash
+	Data        []byte
+}
+
+// EventFilter selects stored events. Zero value fields do not restrict the results.
+type EventFilter struct {
+	Name      string
+	Address   *common.Address
+	FromBlock *uint64
+	ToBlock   *uint64
+	Args      map[string]interface{} // Argument values, normalized with goethereumhelper.NormalizeABIValue before matching
+}
+
+// Events returns the stored events matching filter ordered by block and log index
+func (ix *Indexer) Events(ctx context.Context, filter EventFilter) (events []Event, err error) {
+	var conditions []string
+	var params []interface{}
+	if filter.Name != "" {
+		conditions = append(conditions, "event = ?")
+		params = append(params, filter.Name)
+	}
+	if filter.Address != nil {
+		conditions = append(conditions, "address = ?")
+		params = append(params, filter.Address.Hex())
+	}
+	if filter.FromBlock != nil {
+		conditions = append(conditions, "block_number >= ?")
+		params = append(params, *filter.FromBlock)
+	}
+	if filter.ToBlock != nil {
+		conditions = append(conditions, "block_number <= ?")
+		params = append(params, *filter.ToBlock)
+	}
+	for name, value := range filter.Args {
+		conditions = append(conditions, "id IN (SELECT log_id FROM log_args WHERE name = ? AND value = ?)")
+		params = append(params, name, argValue(goethereumhelper.NormalizeABIValue(value)))
+	}
+
+	query := `SELECT id, block_number, block_hash, tx_hash, log_index, address, event, signature, topics, data, args FROM logs`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY block_number, log_index"
+
+	rows, err := ix.db.QueryContext(ctx, query, params...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var event Event
+		var blockHash, txHash, address, topics, data string
+		var name, signature, args *string
+		err = rows.Scan(&event.ID, &event.BlockNumber, &blockHash, &txHash, &event.LogIndex, &address, &name, &signature, &topics, &data, &args)
+		if err != nil {
+			return
+		}
+		event.BlockHash = common.HexToHash(blockHash)
+		event.TxHash = common.HexToHash(txHash)
+		event.Address = common.HexToAddress(address)
+		event.Data = common.Hex2Bytes(data)
+		if name != nil {
+			event.Name = *name
+		}
+		if signature != nil {
+			event.Signature = *signature
+		}
+		err = json.Unmarshal([]byte(topics), &event.Topics)
+		if err != nil {
+			return
+		}
+		if args != nil {
+			err = json.Unmarshal([]byte(*args), &event.Args)
+			if err != nil {
+				return
+			}
+		}
+		events = append(events, event)
+	}
+	err = rows.Err()
+	return
+}
+
+// EventsByName returns the stored events called name, ex: Transfer
+func (ix *Indexer) EventsByName(ctx context.Context, name string) ([]Event, error) {
+	return ix.Events(ctx, EventFilter{Name: name})
+}
+
+// EventsInRange returns the stored events emitted between fromBlock and toBlock, both inclusive
+func (ix *Indexer) EventsInRange(ctx context.Context, fromBlock, toBlock uint64) ([]Event, error) {
+	return ix.Events(ctx, EventFilter{FromBlock: &fromBlock, ToBlock: &toBlock})
+}
+
+// EventsByArg returns the stored events called name whose argument arg equals value,
+// ex: EventsByArg(ctx, "Transfer", "to", common.HexToAddress("0x..."))
+func (ix *Indexer) EventsByArg(ctx context.Context, name, arg string, value interface{}) ([]Event, error) {
+	return ix.Events(ctx, EventFilter{Name: name, Args: map[string]interface{}{arg: value}})
+}
@@ -0,0 +1,125 @@
+package goethereumhelper
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// ScryptParams are the scrypt cost parameters used to encrypt the keys of a keystore. Higher values make
+// brute forcing a passphrase slower, and unlocking the account too.
+type ScryptParams struct {
+	N int
+	P int
+}
+
+var (
+	// StandardScrypt are the parameters used by geth, taking about a second to unlock an account
+	StandardScrypt = ScryptParams{N: keystore.StandardScryptN, P: keystore.StandardScryptP}
+	// LightScrypt are the parameters of geth --lightkdf, suitable for tests and constrained devices
+	LightScrypt = ScryptParams{N: keystore.LightScryptN, P: keystore.LightScryptP}
+)
+
+// OpenKeystore opens the keystore directory dir, creating it when it does not exist. New and imported keys
+// are encrypted with the scrypt params.
+func OpenKeystore(dir string, scrypt ScryptParams) *keystore.KeyStore {
+	return keystore.NewKeyStore(dir, scrypt.N, scrypt.P)
+}
+
+// CreateKeystoreAccount creates a new account in ks, its key encrypted with passphrase
+func CreateKeystoreAccount(ks *keystore.KeyStore, passphrase string) (account accounts.Account, err error) {
+	account, err = ks.NewAccount(passphrase)
+	if err != nil {
+		err = fmt.Errorf("could not create keystore account: %w", err)
+	}
+	return
+}
+
+// ImportPrivateKey stores privateKey, like the ones returned by NewAccount, in ks encrypted with passphrase.
+// It returns keystore.ErrAccountAlreadyExists when the account is already in ks.
+func ImportPrivateKey(ks *keystore.KeyStore, privateKey *ecdsa.PrivateKey, passphrase string) (account accounts.Account, err error) {
+	account, err = ks.ImportECDSA(privateKey, passphrase)
+	if err != nil {
+		err = fmt.Errorf("could not import account %s: %w", crypto.PubkeyToAddress(privateKey.PublicKey).Hex(), err)
+	}
+	return
+}
+
+// ImportPrivateKeyHex stores the hex private key in ks encrypted with passphrase
+func ImportPrivateKeyHex(ks *keystore.KeyStore, privateKeyHex, passphrase string) (account accounts.Account, err error) {
+	privateKey, err := crypto.HexToECDSA(strings.TrimPrefix(privateKeyHex, "0x"))
+	if err != nil {
+		err = fmt.Errorf("invalid private key: %w", err)
+		return
+	}
+	return ImportPrivateKey(ks, privateKey, passphrase)
+}
+
+// ImportKeystoreJSON stores the encrypted V3 JSON key, decrypted with passphrase, in ks encrypted with newPassphrase
+func ImportKeystoreJSON(ks *keystore.KeyStore, keyJSON []byte, passphrase, newPassphrase string) (account accounts.Account, err error) {
+	account, err = ks.Import(keyJSON, passphrase, newPassphrase)
+	if err != nil {
+		err = fmt.Errorf("could not import keystore JSON: %w", err)
+	}
+	return
+}
+
+// ExportKeystoreJSON returns the key of address as encrypted V3 JSON, decrypting it with passphrase and
+// encrypting it again with newPassphrase using the scrypt params of ks
+func ExportKeystoreJSON(ks *keystore.KeyStore, address common.Address, passphrase, newPassphrase string) (keyJSON []byte, err error) {
+	account, err := findKeystoreAccount(ks, address)
+	if err != nil {
+		return
+	}
+	keyJSON, err = ks.Export(account, passphrase, newPassphrase)
+	if err != nil {
+		err = fmt.Errorf("could not export account %s: %w", address.Hex(), err)
+	}
+	return
+}
+
+// ChangeKeystorePassphrase encrypts again the key of address, replacing passphrase by newPassphrase
+func ChangeKeystorePassphrase(ks *keystore.KeyStore, address common.Address, passphrase, newPassphrase string) (err error) {
+	account, err := findKeystoreAccount(ks, address)
+	if err != nil {
+		return
+	}
+	err = ks.Update(account, passphrase, newPassphrase)
+	if err != nil {
+		err = fmt.Errorf("could not change passphrase of account %s: %w", address.Hex(), err)
+	}
+	return
+}
+
+// DeleteKeystoreAccount removes the key file of address from ks, after checking passphrase decrypts it
+func DeleteKeystoreAccount(ks *keystore.KeyStore, address common.Address, passphrase string) (err error) {
+	account, err := findKeystoreAccount(ks, address)
+	if err != nil {
+		return
+	}
+	err = ks.Delete(account, passphrase)
+	if err != nil {
+		err = fmt.Errorf("could not delete account %s: %w", address.Hex(), err)
+	}
+	return
+}
+
+// ListKeystoreAccounts returns the accounts of ks sorted by the URL of their key file, like
+// keystore:///home/user/.ethereum/keystore/UTC--2023-01-02T15-04-05.000000000Z--0123...
+func ListKeystoreAccounts(ks *keystore.KeyStore) []accounts.Account {
+	return ks.Accounts()
+}
+
+// findKeystoreAccount returns the account of address in ks, with the URL of its key file
+func findKeystoreAccount(ks *keystore.KeyStore, address common.Address) (account accounts.Account, err error) {
+	account, err = ks.Find(accounts.Account{Address: address})
+	if err != nil {
+		err = fmt.Errorf("account %s not found in keystore: %w", address.Hex(), err)
+	}
+	return
+}
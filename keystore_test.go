@@ -0,0 +1,248 @@
+package goethereumhelper
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestKeystore(t *testing.T) {
+	const passphrase = "secret"
+	unknown := common.HexToAddress("0x1234")
+	tests := []struct {
+		name     string
+		run      func(ks *keystore.KeyStore, account accounts.Account) error
+		err      error // Expected error, nil when run succeeds
+		accounts int   // Accounts left in the keystore
+	}{
+		{
+			name: "create",
+			run: func(ks *keystore.KeyStore, account accounts.Account) error {
+				created, err := CreateKeystoreAccount(ks, "other")
+				if err != nil {
+					return err
+				}
+				return ks.Unlock(created, "other")
+			},
+			accounts: 2,
+		},
+		{
+			name: "import key",
+			run: func(ks *keystore.KeyStore, account accounts.Account) error {
+				privateKey, address, err := NewAccount()
+				if err != nil {
+					return err
+				}
+				imported, err := ImportPrivateKey(ks, privateKey, "other")
+				if err != nil {
+					return err
+				}
+				if imported.Address != address {
+					return fmt.Errorf("imported %s, expected %s", imported.Address.Hex(), address.Hex())
+				}
+				return ks.Unlock(imported, "other")
+			},
+			accounts: 2,
+		},
+		{
+			name: "import hex key",
+			run: func(ks *keystore.KeyStore, account accounts.Account) error {
+				imported, err := ImportPrivateKeyHex(ks, "0x4c0883a69102937d6231471b5dbb6204fe5129617082792ae468d01a3f362318", "other")
+				if err != nil {
+					return err
+				}
+				if expected := common.HexToAddress("0x2c7536E3605D9C16a7a3D7b1898e529396a65c23"); imported.Address != expected {
+					return fmt.Errorf("imported %s, expected %s", imported.Address.Hex(), expected.Hex())
+				}
+				return nil
+			},
+			accounts: 2,
+		},
+		{
+			name: "import invalid hex key",
+			run: func(ks *keystore.KeyStore, account accounts.Account) error {
+				_, err := ImportPrivateKeyHex(ks, "0x1234", "other")
+				if err == nil {
+					return errors.New("invalid key imported")
+				}
+				return nil
+			},
+			accounts: 1,
+		},
+		{
+			name: "import existing key",
+			run: func(ks *keystore.KeyStore, account accounts.Account) error {
+				keyJSON, err := ExportKeystoreJSON(ks, account.Address, passphrase, passphrase)
+				if err != nil {
+					return err
+				}
+				key, err := keystore.DecryptKey(keyJSON, passphrase)
+				if err != nil {
+					return err
+				}
+				_, err = ImportPrivateKey(ks, key.PrivateKey, "other")
+				return err
+			},
+			err:      keystore.ErrAccountAlreadyExists,
+			accounts: 1,
+		},
+		{
+			name: "import JSON",
+			run: func(ks *keystore.KeyStore, account accounts.Account) error {
+				keyJSON, err := ExportKeystoreJSON(ks, account.Address, passphrase, "exported")
+				if err != nil {
+					return err
+				}
+				other := OpenKeystore(t.TempDir(), LightScrypt)
+				imported, err := ImportKeystoreJSON(other, keyJSON, "exported", "imported")
+				if err != nil {
+					return err
+				}
+				if imported.Address != account.Address {
+					return fmt.Errorf("imported %s, expected %s", imported.Address.Hex(), account.Address.Hex())
+				}
+				return other.Unlock(imported, "imported")
+			},
+			accounts: 1,
+		},
+		{
+			name: "import JSON with wrong passphrase",
+			run: func(ks *keystore.KeyStore, account accounts.Account) error {
+				keyJSON, err := ExportKeystoreJSON(ks, account.Address, passphrase, "exported")
+				if err != nil {
+					return err
+				}
+				_, err = ImportKeystoreJSON(OpenKeystore(t.TempDir(), LightScrypt), keyJSON, "wrong", "imported")
+				return err
+			},
+			err:      keystore.ErrDecrypt,
+			accounts: 1,
+		},
+		{
+			name: "export",
+			run: func(ks *keystore.KeyStore, account accounts.Account) error {
+				keyJSON, err := ExportKeystoreJSON(ks, account.Address, passphrase, "exported")
+				if err != nil {
+					return err
+				}
+				key, err := keystore.DecryptKey(keyJSON, "exported")
+				if err != nil {
+					return err
+				}
+				if key.Address != account.Address {
+					return fmt.Errorf("exported %s, expected %s", key.Address.Hex(), account.Address.Hex())
+				}
+				return nil
+			},
+			accounts: 1,
+		},
+		{
+			name: "export with wrong passphrase",
+			run: func(ks *keystore.KeyStore, account accounts.Account) error {
+				_, err := ExportKeystoreJSON(ks, account.Address, "wrong", "exported")
+				return err
+			},
+			err:      keystore.ErrDecrypt,
+			accounts: 1,
+		},
+		{
+			name: "export unknown account",
+			run: func(ks *keystore.KeyStore, account accounts.Account) error {
+				_, err := ExportKeystoreJSON(ks, unknown, passphrase, "exported")
+				return err
+			},
+			err:      keystore.ErrNoMatch,
+			accounts: 1,
+		},
+		{
+			name: "re-encrypt",
+			run: func(ks *keystore.KeyStore, account accounts.Account) error {
+				err := ChangeKeystorePassphrase(ks, account.Address, passphrase, "changed")
+				if err != nil {
+					return err
+				}
+				if ks.Unlock(account, passphrase) == nil {
+					return errors.New("previous passphrase still unlocks the account")
+				}
+				return ks.Unlock(account, "changed")
+			},
+			accounts: 1,
+		},
+		{
+			name: "re-encrypt with wrong passphrase",
+			run: func(ks *keystore.KeyStore, account accounts.Account) error {
+				return ChangeKeystorePassphrase(ks, account.Address, "wrong", "changed")
+			},
+			err:      keystore.ErrDecrypt,
+			accounts: 1,
+		},
+		{
+			name: "delete",
+			run: func(ks *keystore.KeyStore, account accounts.Account) error {
+				return DeleteKeystoreAccount(ks, account.Address, passphrase)
+			},
+			accounts: 0,
+		},
+		{
+			name: "delete with wrong passphrase",
+			run: func(ks *keystore.KeyStore, account accounts.Account) error {
+				return DeleteKeystoreAccount(ks, account.Address, "wrong")
+			},
+			err:      keystore.ErrDecrypt,
+			accounts: 1,
+		},
+		{
+			name: "delete unknown account",
+			run: func(ks *keystore.KeyStore, account accounts.Account) error {
+				return DeleteKeystoreAccount(ks, unknown, passphrase)
+			},
+			err:      keystore.ErrNoMatch,
+			accounts: 1,
+		},
+		{
+			name: "list",
+			run: func(ks *keystore.KeyStore, account accounts.Account) error {
+				for i := 0; i < 3; i++ {
+					if _, err := CreateKeystoreAccount(ks, passphrase); err != nil {
+						return err
+					}
+				}
+				listed := ListKeystoreAccounts(ks)
+				if !sort.SliceIsSorted(listed, func(i, j int) bool { return listed[i].URL.Cmp(listed[j].URL) < 0 }) {
+					return errors.New("accounts not sorted by URL")
+				}
+				for _, listedAccount := range listed {
+					if listedAccount.URL.Scheme != keystore.KeyStoreScheme {
+						return fmt.Errorf("account listed with URL %s", listedAccount.URL)
+					}
+				}
+				return nil
+			},
+			accounts: 4,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ks := OpenKeystore(t.TempDir(), LightScrypt)
+			account, err := CreateKeystoreAccount(ks, passphrase)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = test.run(ks, account)
+			if test.err == nil && err != nil {
+				t.Fatal(err)
+			}
+			if !errors.Is(err, test.err) {
+				t.Fatalf("expected %v, got %v", test.err, err)
+			}
+			if listed := len(ListKeystoreAccounts(ks)); listed != test.accounts {
+				t.Fatalf("%d accounts in the keystore, expected %d", listed, test.accounts)
+			}
+		})
+	}
+}
@@ -2,47 +2,217 @@ package goethereumhelper
 
 import (
 	"context"
+	"crypto/ecdsa"
 	"fmt"
 	"math/big"
+	"strings"
 
-	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
-
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 )
 
-func getMockAccountWithFunds(genesisAccount *bind.TransactOpts, backend *backends.SimulatedBackend) (newAccount *bind.TransactOpts, err error) {
-	key, _ := crypto.GenerateKey()
-	newAccount = bind.NewKeyedTransactor(key)
-	err = prepareNewTransction(genesisAccount, backend)
+// erc20FundingABI holds the ERC-20 functions used to give test tokens to an account
+const erc20FundingABI = `[
+	{"type":"function","name":"mint","stateMutability":"nonpayable","inputs":[{"name":"to","type":"address"},{"name":"amount","type":"uint256"}],"outputs":[]},
+	{"type":"function","name":"transfer","stateMutability":"nonpayable","inputs":[{"name":"to","type":"address"},{"name":"amount","type":"uint256"}],"outputs":[{"name":"","type":"bool"}]},
+	{"type":"function","name":"balanceOf","stateMutability":"view","inputs":[{"name":"account","type":"address"}],"outputs":[{"name":"","type":"uint256"}]}
+]`
+
+// TokenAmount is an amount of an ERC-20 token, in the token smallest unit
+type TokenAmount struct {
+	Token  common.Address
+	Amount *big.Int
+}
+
+// FundAccount sends amount wei from funder to account in an EIP-1559 transaction, mines it and
+// checks the account balance increased by amount
+func FundAccount(ctx context.Context, backend *backends.SimulatedBackend, funder *bind.TransactOpts, account common.Address, amount *big.Int) (err error) {
+	before, err := backend.BalanceAt(ctx, account, nil)
 	if err != nil {
-		fmt.Printf("Houve falha ao obter novo nonce para fazer a inclusao de saldo na conta de um novo 3rd-party: %+v", err)
 		return
 	}
-	tx := types.NewTransaction(uint64(genesisAccount.Nonce.Int64()), newAccount.From, big.NewInt(101000000000000), genesisAccount.GasLimit, genesisAccount.GasPrice, nil)
-	signedTx, err := genesisAccount.Signer(genesisAccount.From, tx)
+	_, err = sendSimulatedTransaction(ctx, backend, funder, account, amount, nil)
 	if err != nil {
-		fmt.Printf("Houve falha ao assinar a transação para enviar ether a nova conta: %+v", err)
+		err = fmt.Errorf("could not fund account %s: %w", account.Hex(), err)
 		return
 	}
-	backend.SendTransaction(context.Background(), signedTx)
-	backend.Commit()
+	after, err := backend.BalanceAt(ctx, account, nil)
+	if err != nil {
+		return
+	}
+	if funder.From != account && new(big.Int).Sub(after, before).Cmp(amount) != 0 {
+		err = fmt.Errorf("account %s balance is %s wei after being funded with %s wei from %s wei", account.Hex(), after, amount, before)
+	}
+	return
+}
+
+// FundAccountWithToken gives amount tokens to account. It calls mint(address,uint256) when funder is allowed
+// to mint, otherwise it transfers the tokens from funder, checking the account token balance afterwards.
+func FundAccountWithToken(ctx context.Context, backend *backends.SimulatedBackend, funder *bind.TransactOpts, token, account common.Address, amount *big.Int) (err error) {
+	tokenABI, err := abi.JSON(strings.NewReader(erc20FundingABI))
+	if err != nil {
+		return
+	}
+	before, err := tokenBalance(ctx, backend, tokenABI, token, account)
+	if err != nil {
+		return
+	}
+
+	data, err := tokenABI.Pack("mint", account, amount)
+	if err != nil {
+		return
+	}
+	_, errMint := backend.CallContract(ctx, ethereum.CallMsg{From: funder.From, To: &token, Data: data}, nil)
+	if errMint != nil {
+		data, err = tokenABI.Pack("transfer", account, amount)
+		if err != nil {
+			return
+		}
+	}
+	_, err = sendSimulatedTransaction(ctx, backend, funder, token, nil, data)
+	if err != nil {
+		err = fmt.Errorf("could not give tokens %s to account %s: %w", token.Hex(), account.Hex(), err)
+		return
+	}
+
+	after, err := tokenBalance(ctx, backend, tokenABI, token, account)
+	if err != nil {
+		return
+	}
+	if funder.From != account && new(big.Int).Sub(after, before).Cmp(amount) != 0 {
+		err = fmt.Errorf("account %s balance of token %s is %s after receiving %s from %s", account.Hex(), token.Hex(), after, amount, before)
+	}
 	return
 }
 
-func prepareNewTransction(auth *bind.TransactOpts, backend *backends.SimulatedBackend) (err error) {
-	err = nil
-	nonce, err := backend.PendingNonceAt(context.Background(), auth.From)
+// NewFundedAccount creates a new account holding amount wei and, optionally, ERC-20 tokens given by funder
+func NewFundedAccount(ctx context.Context, backend *backends.SimulatedBackend, funder *bind.TransactOpts, amount *big.Int, tokens ...TokenAmount) (key *ecdsa.PrivateKey, account *bind.TransactOpts, err error) {
+	key, err = crypto.GenerateKey()
 	if err != nil {
 		return
 	}
-	gasPrice, err := backend.SuggestGasPrice(context.Background())
+	chainID := backend.Blockchain().Config().ChainID
+	account, err = bind.NewKeyedTransactorWithChainID(key, chainID)
 	if err != nil {
 		return
 	}
-	auth.GasLimit = uint64(600000)
-	auth.GasPrice = gasPrice
-	auth.Nonce = big.NewInt(int64(nonce))
+	account.Context = context.Background()
+	err = FundAccount(ctx, backend, funder, account.From, amount)
+	if err != nil {
+		return
+	}
+	for _, token := range tokens {
+		err = FundAccountWithToken(ctx, backend, funder, token.Token, account.From, token.Amount)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// NewFundedAccount creates a new account funded by the first account of the chain
+func (c *TestChain) NewFundedAccount(amount *big.Int, tokens ...TokenAmount) (key *ecdsa.PrivateKey, account *bind.TransactOpts, err error) {
+	return NewFundedAccount(context.Background(), c.SimulatedBackend, c.Accounts[0], amount, tokens...)
+}
+
+func tokenBalance(ctx context.Context, backend *backends.SimulatedBackend, tokenABI abi.ABI, token, account common.Address) (balance *big.Int, err error) {
+	data, err := tokenABI.Pack("balanceOf", account)
+	if err != nil {
+		return
+	}
+	output, err := backend.CallContract(ctx, ethereum.CallMsg{To: &token, Data: data}, nil)
+	if err != nil {
+		err = fmt.Errorf("could not read balance of %s in token %s: %w", account.Hex(), token.Hex(), err)
+		return
+	}
+	values, err := tokenABI.Unpack("balanceOf", output)
+	if err != nil {
+		return
+	}
+	balance = values[0].(*big.Int)
+	return
+}
+
+// sendSimulatedTransaction signs an EIP-1559 transaction with from, or a legacy one when the chain has no
+// base fee, mines it and checks it succeeded
+func sendSimulatedTransaction(ctx context.Context, backend *backends.SimulatedBackend, from *bind.TransactOpts, to common.Address, value *big.Int, data []byte) (receipt *types.Receipt, err error) {
+	if value == nil {
+		value = new(big.Int)
+	}
+	nonce, err := backend.PendingNonceAt(ctx, from.From)
+	if err != nil {
+		return
+	}
+	head, err := backend.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return
+	}
+	gasLimit, err := backend.EstimateGas(ctx, ethereum.CallMsg{From: from.From, To: &to, Value: value, Data: data})
+	if err != nil {
+		return
+	}
+
+	var tx *types.Transaction
+	if head.BaseFee == nil {
+		gasPrice, errPrice := backend.SuggestGasPrice(ctx)
+		if errPrice != nil {
+			return nil, errPrice
+		}
+		tx = types.NewTx(&types.LegacyTx{
+			Nonce:    nonce,
+			GasPrice: gasPrice,
+			Gas:      gasLimit,
+			To:       &to,
+			Value:    value,
+			Data:     data,
+		})
+	} else {
+		gasTip, errTip := backend.SuggestGasTipCap(ctx)
+		if errTip != nil {
+			return nil, errTip
+		}
+		maxGasFeeAccepted := new(big.Int).Add(new(big.Int).Mul(head.BaseFee, big.NewInt(2)), gasTip)
+		tx = types.NewTx(&types.DynamicFeeTx{
+			ChainID:   backend.Blockchain().Config().ChainID,
+			Nonce:     nonce,
+			GasTipCap: gasTip,
+			GasFeeCap: maxGasFeeAccepted,
+			Gas:       gasLimit,
+			To:        &to,
+			Value:     value,
+			Data:      data,
+		})
+	}
+	// The simulated backend panics on transactions the sender cannot pay for
+	balance, err := backend.BalanceAt(ctx, from.From, nil)
+	if err != nil {
+		return
+	}
+	if cost := tx.Cost(); balance.Cmp(cost) < 0 {
+		err = fmt.Errorf("insufficient funds: %s has %s wei, the transaction costs up to %s wei", from.From.Hex(), balance, cost)
+		return
+	}
+	signedTx, err := from.Signer(from.From, tx)
+	if err != nil {
+		return
+	}
+	err = backend.SendTransaction(ctx, signedTx)
+	if err != nil {
+		return
+	}
+	backend.Commit()
+
+	receipt, err = backend.TransactionReceipt(ctx, signedTx.Hash())
+	if err != nil {
+		return
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		err = fmt.Errorf("transaction %s failed", signedTx.Hash().Hex())
+	}
 	return
 }
@@ -0,0 +1,79 @@
+package goethereumhelper_test
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/jeffprestes/goethereumhelper"
+	"github.com/jeffprestes/goethereumhelper/testcontracts"
+)
+
+func TestFundAccountWithToken(t *testing.T) {
+	chain, err := goethereumhelper.NewTestChain()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Close()
+	ctx := context.Background()
+	owner := chain.Accounts[0]
+	tokenAddress, token, err := testcontracts.DeploySimulatedERC20(ctx, chain.Backend(), owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The owner has the minter role: the tokens are minted
+	amount := big.NewInt(500)
+	_, holder, err := chain.NewFundedAccount(big.NewInt(params.Ether), goethereumhelper.TokenAmount{Token: tokenAddress, Amount: amount})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertBigInt(t, "holder ether balance", big.NewInt(params.Ether), func() (*big.Int, error) { return chain.BalanceAt(ctx, holder.From, nil) })
+	assertBigInt(t, "holder token balance", amount, func() (*big.Int, error) { return token.BalanceOf(nil, holder.From) })
+	assertBigInt(t, "total supply after minting", amount, func() (*big.Int, error) { return token.TotalSupply(nil) })
+
+	// The holder cannot mint: the tokens are transferred from its balance
+	recipient := common.HexToAddress("0x1234")
+	if err := goethereumhelper.FundAccountWithToken(ctx, chain.Backend(), holder, tokenAddress, recipient, big.NewInt(200)); err != nil {
+		t.Fatal(err)
+	}
+	assertBigInt(t, "recipient token balance", big.NewInt(200), func() (*big.Int, error) { return token.BalanceOf(nil, recipient) })
+	assertBigInt(t, "holder token balance after transferring", big.NewInt(300), func() (*big.Int, error) { return token.BalanceOf(nil, holder.From) })
+	assertBigInt(t, "total supply after transferring", amount, func() (*big.Int, error) { return token.TotalSupply(nil) })
+
+	// Without minter role nor enough tokens the funding fails
+	if err := goethereumhelper.FundAccountWithToken(ctx, chain.Backend(), holder, tokenAddress, recipient, big.NewInt(1000)); err == nil {
+		t.Fatal("expected an error transferring more tokens than the funder holds")
+	}
+}
+
+func TestFundAccount(t *testing.T) {
+	chain, err := goethereumhelper.NewTestChain()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Close()
+	ctx := context.Background()
+	recipient := common.HexToAddress("0x1234")
+	amount := big.NewInt(12345)
+	if err := goethereumhelper.FundAccount(ctx, chain.Backend(), chain.Accounts[1], recipient, amount); err != nil {
+		t.Fatal(err)
+	}
+	assertBigInt(t, "recipient balance", amount, func() (*big.Int, error) { return chain.BalanceAt(ctx, recipient, nil) })
+	if err := goethereumhelper.FundAccount(ctx, chain.Backend(), chain.Accounts[1], recipient, goethereumhelper.DefaultTestChainAccountBalance); err == nil {
+		t.Fatal("expected an error sending more than the funder balance")
+	}
+}
+
+func assertBigInt(t *testing.T, what string, expected *big.Int, read func() (*big.Int, error)) {
+	t.Helper()
+	value, err := read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value.Cmp(expected) != 0 {
+		t.Fatalf("%s is %s, expected %s", what, value, expected)
+	}
+}
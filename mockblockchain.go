@@ -1,23 +1,22 @@
 package goethereumhelper
 
 import (
-	"context"
 	"crypto/ecdsa"
+	"log"
 	"math/big"
 
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
-	"github.com/ethereum/go-ethereum/core"
-	"github.com/ethereum/go-ethereum/crypto"
 )
 
 // GetMockBlockchain get a "in-memory" Blockchain instance
+//
+// Deprecated: use NewTestChain, which allows choosing the number of accounts, their balances and the gas limit.
 func GetMockBlockchain() (auth *bind.TransactOpts, backend *backends.SimulatedBackend, coinbaseAccountPrivateKey *ecdsa.PrivateKey) {
-	coinbaseAccountPrivateKey, _ = crypto.GenerateKey()
-	auth = bind.NewKeyedTransactor(coinbaseAccountPrivateKey)
-	auth.Context = context.Background()
-	alloc := make(core.GenesisAlloc)
-	alloc[auth.From] = core.GenesisAccount{Balance: big.NewInt(9000000000000000)}
-	backend = backends.NewSimulatedBackend(alloc, 90000000)
-	return
+	chain, err := NewTestChain(WithAccounts(1, big.NewInt(9000000000000000)), WithGasLimit(90000000))
+	if err != nil {
+		log.Println("[GetMockBlockchain] Error creating the test chain: ", err.Error())
+		return
+	}
+	return chain.Accounts[0], chain.Backend(), chain.Keys[0]
 }
@@ -0,0 +1,302 @@
+package goethereumhelper
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// ErrOfflineReview is returned when an offline transaction differs from what was reviewed
+var ErrOfflineReview = errors.New("transaction does not match the review")
+
+// TransactionBackend is the subset of ethclient.Client (or the TestChain) used to prepare and broadcast transactions
+type TransactionBackend interface {
+	bind.ContractBackend
+	ChainID(ctx context.Context) (*big.Int, error)
+}
+
+// OfflineTxRequest describes a transaction to prepare with PrepareOfflineTransaction
+type OfflineTxRequest struct {
+	Type       uint8 // types.LegacyTxType, types.AccessListTxType or types.DynamicFeeTxType
+	From       common.Address
+	To         *common.Address // nil deploys a contract
+	Value      *big.Int
+	Data       []byte
+	AccessList types.AccessList // Only for access list and dynamic fee transactions
+	GasLimit   uint64           // Estimated when 0
+}
+
+// OfflineTransaction is a transaction prepared on a connected machine, signed on an air-gapped one and
+// broadcast later, saved as a JSON file between the steps.
+//
+// Example:
+//
+//	// Online: prepare the transaction
+//	offlineTx, err := PrepareOfflineTransaction(ctx, client, OfflineTxRequest{Type: types.DynamicFeeTxType, From: from, To: &to, Value: value})
+//	err = offlineTx.Save("tx.json")
+//
+//	// Offline: review the summary and sign
+//	offlineTx, err := LoadOfflineTransaction("tx.json")
+//	fmt.Println(offlineTx.Summary())
+//	review := OfflineReview{ChainID: big.NewInt(1), Nonce: 12, To: &to, Value: value, MaxFeePerGas: maxFee}
+//	err = offlineTx.SignWithKeystoreWallet(wallet, passphrase, review)
+//	err = offlineTx.Save("signed.json")
+//
+//	// Online: broadcast
+//	offlineTx, err := LoadOfflineTransaction("signed.json")
+//	signedTx, err := BroadcastOfflineTransaction(ctx, client, offlineTx)
+type OfflineTransaction struct {
+	From        common.Address     `json:"from"`
+	ChainID     *hexutil.Big       `json:"chainId"`
+	Transaction *types.Transaction `json:"transaction"` // Unsigned until signed
+}
+
+// OfflineReview is what the signer checked in the summary of an offline transaction. Signing fails with
+// ErrOfflineReview when the transaction differs.
+type OfflineReview struct {
+	ChainID      *big.Int
+	Nonce        uint64
+	To           *common.Address // nil for a contract creation
+	Value        *big.Int        // nil for no value
+	DataHash     common.Hash     // Keccak-256 hash of the data shown by Summary, zero for no data
+	MaxFeePerGas *big.Int        // Highest gas price, or fee cap of dynamic fee transactions, accepted. Not checked when nil
+}
+
+// PrepareOfflineTransaction builds an unsigned transaction with the chain ID, nonce, gas limit and fees
+// read from client. Dynamic fee transactions accept up to twice the current base fee plus the tip.
+func PrepareOfflineTransaction(ctx context.Context, client TransactionBackend, request OfflineTxRequest) (offlineTx *OfflineTransaction, err error) {
+	chainID, err := client.ChainID(ctx)
+	if err != nil {
+		return
+	}
+	nonce, err := client.PendingNonceAt(ctx, request.From)
+	if err != nil {
+		return
+	}
+	value := request.Value
+	if value == nil {
+		value = new(big.Int)
+	}
+	gasLimit := request.GasLimit
+	if gasLimit == 0 {
+		gasLimit, err = client.EstimateGas(ctx, ethereum.CallMsg{From: request.From, To: request.To, Value: value, Data: request.Data, AccessList: request.AccessList})
+		if err != nil {
+			err = fmt.Errorf("could not estimate gas: %w", err)
+			return
+		}
+	}
+
+	var txData types.TxData
+	switch request.Type {
+	case types.LegacyTxType, types.AccessListTxType:
+		gasPrice, errPrice := client.SuggestGasPrice(ctx)
+		if errPrice != nil {
+			return nil, errPrice
+		}
+		if request.Type == types.LegacyTxType {
+			if len(request.AccessList) > 0 {
+				return nil, errors.New("legacy transactions have no access list")
+			}
+			txData = &types.LegacyTx{Nonce: nonce, GasPrice: gasPrice, Gas: gasLimit, To: request.To, Value: value, Data: request.Data}
+			break
+		}
+		txData = &types.AccessListTx{ChainID: chainID, Nonce: nonce, GasPrice: gasPrice, Gas: gasLimit, To: request.To, Value: value, Data: request.Data, AccessList: request.AccessList}
+	case types.DynamicFeeTxType:
+		head, errHead := client.HeaderByNumber(ctx, nil)
+		if errHead != nil {
+			return nil, errHead
+		}
+		if head.BaseFee == nil {
+			return nil, errors.New("chain does not support dynamic fee transactions")
+		}
+		gasTip, errTip := client.SuggestGasTipCap(ctx)
+		if errTip != nil {
+			return nil, errTip
+		}
+		gasFeeCap := new(big.Int).Add(new(big.Int).Mul(head.BaseFee, big.NewInt(2)), gasTip)
+		txData = &types.DynamicFeeTx{ChainID: chainID, Nonce: nonce, GasTipCap: gasTip, GasFeeCap: gasFeeCap, Gas: gasLimit, To: request.To, Value: value, Data: request.Data, AccessList: request.AccessList}
+	default:
+		return nil, fmt.Errorf("unsupported transaction type %d", request.Type)
+	}
+	offlineTx = &OfflineTransaction{
+		From:        request.From,
+		ChainID:     (*hexutil.Big)(chainID),
+		Transaction: types.NewTx(txData),
+	}
+	return
+}
+
+// LoadOfflineTransaction reads an offline transaction saved by Save
+func LoadOfflineTransaction(path string) (offlineTx *OfflineTransaction, err error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	offlineTx = new(OfflineTransaction)
+	err = json.Unmarshal(content, offlineTx)
+	if err != nil {
+		return nil, fmt.Errorf("could not read offline transaction %s: %w", path, err)
+	}
+	if offlineTx.ChainID == nil || offlineTx.Transaction == nil {
+		return nil, fmt.Errorf("could not read offline transaction %s: chainId or transaction missing", path)
+	}
+	return
+}
+
+// Save writes the offline transaction as JSON to path
+func (o *OfflineTransaction) Save(path string) (err error) {
+	content, err := json.MarshalIndent(o, "", "  ")
+	if err != nil {
+		return
+	}
+	return os.WriteFile(path, content, 0o644)
+}
+
+// Signed tells if the transaction has a signature
+func (o *OfflineTransaction) Signed() bool {
+	v, r, s := o.Transaction.RawSignatureValues()
+	return v.Sign() != 0 || r.Sign() != 0 || s.Sign() != 0
+}
+
+// Summary describes the transaction for the review before signing
+func (o *OfflineTransaction) Summary() string {
+	tx := o.Transaction
+	var summary strings.Builder
+	fmt.Fprintf(&summary, "Type: %d\n", tx.Type())
+	fmt.Fprintf(&summary, "Chain ID: %s\n", o.ChainID.ToInt())
+	fmt.Fprintf(&summary, "From: %s\n", o.From.Hex())
+	fmt.Fprintf(&summary, "To: %s\n", offlineRecipient(tx.To()))
+	fmt.Fprintf(&summary, "Value: %s wei\n", tx.Value())
+	fmt.Fprintf(&summary, "Nonce: %d\n", tx.Nonce())
+	fmt.Fprintf(&summary, "Gas limit: %d\n", tx.Gas())
+	if tx.Type() == types.DynamicFeeTxType {
+		fmt.Fprintf(&summary, "Max fee per gas: %s wei\n", tx.GasFeeCap())
+		fmt.Fprintf(&summary, "Max priority fee per gas: %s wei\n", tx.GasTipCap())
+	} else {
+		fmt.Fprintf(&summary, "Gas price: %s wei\n", tx.GasPrice())
+	}
+	fmt.Fprintf(&summary, "Max cost: %s wei\n", tx.Cost())
+	fmt.Fprintf(&summary, "Data: %d bytes", len(tx.Data()))
+	if len(tx.Data()) > 0 {
+		if len(tx.Data()) >= 4 {
+			fmt.Fprintf(&summary, ", selector %s", hexutil.Encode(tx.Data()[:4]))
+		}
+		fmt.Fprintf(&summary, "\nData hash: %s", crypto.Keccak256Hash(tx.Data()).Hex())
+	}
+	return summary.String()
+}
+
+// check compares the transaction with review and with the account signing it
+func (o *OfflineTransaction) check(signer common.Address, review OfflineReview) error {
+	tx := o.Transaction
+	value := review.Value
+	if value == nil {
+		value = new(big.Int)
+	}
+	dataHash := common.Hash{}
+	if len(tx.Data()) > 0 {
+		dataHash = crypto.Keccak256Hash(tx.Data())
+	}
+	switch {
+	case signer != o.From:
+		return fmt.Errorf("%w: it is from %s, not from the signer %s", ErrOfflineReview, o.From.Hex(), signer.Hex())
+	case review.ChainID == nil || review.ChainID.Cmp(o.ChainID.ToInt()) != 0:
+		return fmt.Errorf("%w: chain ID is %s, reviewed %v", ErrOfflineReview, o.ChainID.ToInt(), review.ChainID)
+	case tx.Type() != types.LegacyTxType && tx.ChainId().Cmp(o.ChainID.ToInt()) != 0:
+		return fmt.Errorf("%w: transaction chain ID %s differs from %s", ErrOfflineReview, tx.ChainId(), o.ChainID.ToInt())
+	case review.Nonce != tx.Nonce():
+		return fmt.Errorf("%w: nonce is %d, reviewed %d", ErrOfflineReview, tx.Nonce(), review.Nonce)
+	case (review.To == nil) != (tx.To() == nil) || review.To != nil && *review.To != *tx.To():
+		return fmt.Errorf("%w: recipient is %s, reviewed %s", ErrOfflineReview, offlineRecipient(tx.To()), offlineRecipient(review.To))
+	case value.Cmp(tx.Value()) != 0:
+		return fmt.Errorf("%w: value is %s wei, reviewed %s wei", ErrOfflineReview, tx.Value(), value)
+	case review.DataHash != dataHash:
+		return fmt.Errorf("%w: data hash is %s, reviewed %s", ErrOfflineReview, dataHash.Hex(), review.DataHash.Hex())
+	case review.MaxFeePerGas != nil && tx.GasFeeCap().Cmp(review.MaxFeePerGas) > 0:
+		return fmt.Errorf("%w: fee per gas up to %s wei, reviewed %s wei", ErrOfflineReview, tx.GasFeeCap(), review.MaxFeePerGas)
+	}
+	return nil
+}
+
+// offlineRecipient describes the recipient to, nil for contract creations
+func offlineRecipient(to *common.Address) string {
+	if to == nil {
+		return "contract creation"
+	}
+	return to.Hex()
+}
+
+// SignWithPrivateKey checks the transaction matches review and signs it with privateKey
+func (o *OfflineTransaction) SignWithPrivateKey(privateKey *ecdsa.PrivateKey, review OfflineReview) (err error) {
+	err = o.check(crypto.PubkeyToAddress(privateKey.PublicKey), review)
+	if err != nil {
+		return
+	}
+	signedTx, err := types.SignTx(o.Transaction, types.LatestSignerForChainID(o.ChainID.ToInt()), privateKey)
+	if err != nil {
+		return
+	}
+	o.Transaction = signedTx
+	return
+}
+
+// SignWithKeystoreWallet checks the transaction matches review and signs it with the account of wallet
+func (o *OfflineTransaction) SignWithKeystoreWallet(wallet *KeystoreWallet, passphrase string, review OfflineReview) (err error) {
+	err = o.check(wallet.Account.Address, review)
+	if err != nil {
+		return
+	}
+	signedTx, err := wallet.SignTxWithPassphrase(passphrase, o.Transaction, o.ChainID.ToInt())
+	if err != nil {
+		return
+	}
+	o.Transaction = signedTx
+	return
+}
+
+// BroadcastOfflineTransaction sends a signed offline transaction after checking its signer, that client is
+// connected to its chain and that its nonce is the next one of the account
+func BroadcastOfflineTransaction(ctx context.Context, client TransactionBackend, offlineTx *OfflineTransaction) (signedTx *types.Transaction, err error) {
+	if !offlineTx.Signed() {
+		return nil, errors.New("transaction is not signed")
+	}
+	signedTx = offlineTx.Transaction
+	sender, err := types.Sender(types.LatestSignerForChainID(offlineTx.ChainID.ToInt()), signedTx)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature: %w", err)
+	}
+	if sender != offlineTx.From {
+		return nil, fmt.Errorf("transaction is signed by %s instead of %s", sender.Hex(), offlineTx.From.Hex())
+	}
+	chainID, err := client.ChainID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if chainID.Cmp(offlineTx.ChainID.ToInt()) != 0 {
+		return nil, fmt.Errorf("transaction is for chain %s, client is connected to chain %s", offlineTx.ChainID.ToInt(), chainID)
+	}
+	nonce, err := client.PendingNonceAt(ctx, sender)
+	if err != nil {
+		return nil, err
+	}
+	if nonce != signedTx.Nonce() {
+		return nil, fmt.Errorf("transaction nonce is %d, the next nonce of %s is %d", signedTx.Nonce(), sender.Hex(), nonce)
+	}
+	err = client.SendTransaction(ctx, signedTx)
+	if err != nil {
+		return nil, err
+	}
+	return
+}
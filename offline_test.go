@@ -0,0 +1,177 @@
+package goethereumhelper
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestOfflineTransactionRoundTrip(t *testing.T) {
+	chain, err := NewTestChain()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Close()
+	ctx := context.Background()
+	from, to := chain.Account(0), chain.Account(1)
+	ks := OpenKeystore(t.TempDir(), LightScrypt)
+	if _, err := ImportPrivateKey(ks, chain.Keys[0], "secret"); err != nil {
+		t.Fatal(err)
+	}
+	wallet, err := NewKeystoreWallet(ks, from.Hex(), "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := []byte{0xca, 0xfe, 0xba, 0xbe, 0x01}
+	accessList := types.AccessList{{Address: to, StorageKeys: []common.Hash{{}}}}
+
+	tests := []struct {
+		name       string
+		txType     uint8
+		accessList types.AccessList
+		keystore   bool // Sign with the keystore wallet instead of the private key
+	}{
+		{name: "legacy", txType: types.LegacyTxType},
+		{name: "access list", txType: types.AccessListTxType, accessList: accessList, keystore: true},
+		{name: "dynamic fee", txType: types.DynamicFeeTxType, accessList: accessList},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			prepared, err := PrepareOfflineTransaction(ctx, chain, OfflineTxRequest{Type: test.txType, From: from, To: &to, Value: big.NewInt(7), Data: data, AccessList: test.accessList})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := prepared.Save(filepath.Join(dir, "tx.json")); err != nil {
+				t.Fatal(err)
+			}
+
+			offlineTx, err := LoadOfflineTransaction(filepath.Join(dir, "tx.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if offlineTx.Signed() || offlineTx.Transaction.Type() != test.txType {
+				t.Fatalf("loaded a signed %d transaction", offlineTx.Transaction.Type())
+			}
+			if offlineTx.Transaction.Hash() != prepared.Transaction.Hash() {
+				t.Fatal("loaded transaction differs from the saved one")
+			}
+			if summary := offlineTx.Summary(); !strings.Contains(summary, "Data hash: "+crypto.Keccak256Hash(data).Hex()) {
+				t.Fatalf("summary without the data hash:\n%s", summary)
+			}
+			review := OfflineReview{
+				ChainID:      chain.Config().ChainID,
+				Nonce:        offlineTx.Transaction.Nonce(),
+				To:           &to,
+				Value:        big.NewInt(7),
+				DataHash:     crypto.Keccak256Hash(data),
+				MaxFeePerGas: offlineTx.Transaction.GasFeeCap(),
+			}
+			if test.keystore {
+				err = offlineTx.SignWithKeystoreWallet(wallet, "secret", review)
+			} else {
+				err = offlineTx.SignWithPrivateKey(chain.Keys[0], review)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := offlineTx.Save(filepath.Join(dir, "signed.json")); err != nil {
+				t.Fatal(err)
+			}
+
+			signed, err := LoadOfflineTransaction(filepath.Join(dir, "signed.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !signed.Signed() {
+				t.Fatal("signature lost when saving")
+			}
+			signedTx, err := BroadcastOfflineTransaction(ctx, chain, signed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			chain.Commit()
+			receipt, err := chain.TransactionReceipt(ctx, signedTx.Hash())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if receipt.Status != types.ReceiptStatusSuccessful || receipt.Type != test.txType {
+				t.Fatalf("transaction of type %d mined with status %d", receipt.Type, receipt.Status)
+			}
+			if _, err := BroadcastOfflineTransaction(ctx, chain, signed); err == nil {
+				t.Fatal("expected an error broadcasting the transaction again")
+			}
+		})
+	}
+}
+
+func TestOfflineReviewMismatch(t *testing.T) {
+	chain, err := NewTestChain()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Close()
+	from, to, other := chain.Account(0), chain.Account(1), chain.Account(2)
+	data := []byte{0xca, 0xfe, 0xba, 0xbe}
+	offlineTx, err := PrepareOfflineTransaction(context.Background(), chain, OfflineTxRequest{Type: types.DynamicFeeTxType, From: from, To: &to, Value: big.NewInt(7), Data: data})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reviewed := func(change func(review *OfflineReview)) OfflineReview {
+		review := OfflineReview{
+			ChainID:      chain.Config().ChainID,
+			Nonce:        offlineTx.Transaction.Nonce(),
+			To:           &to,
+			Value:        big.NewInt(7),
+			DataHash:     crypto.Keccak256Hash(data),
+			MaxFeePerGas: offlineTx.Transaction.GasFeeCap(),
+		}
+		change(&review)
+		return review
+	}
+
+	tests := []struct {
+		name   string
+		key    int
+		review OfflineReview
+	}{
+		{"other signer", 1, reviewed(func(review *OfflineReview) {})},
+		{"chain ID", 0, reviewed(func(review *OfflineReview) { review.ChainID = big.NewInt(1) })},
+		{"chain ID not reviewed", 0, reviewed(func(review *OfflineReview) { review.ChainID = nil })},
+		{"nonce", 0, reviewed(func(review *OfflineReview) { review.Nonce++ })},
+		{"recipient", 0, reviewed(func(review *OfflineReview) { review.To = &other })},
+		{"contract creation", 0, reviewed(func(review *OfflineReview) { review.To = nil })},
+		{"value", 0, reviewed(func(review *OfflineReview) { review.Value = big.NewInt(8) })},
+		{"no value", 0, reviewed(func(review *OfflineReview) { review.Value = nil })},
+		{"data hash", 0, reviewed(func(review *OfflineReview) { review.DataHash = crypto.Keccak256Hash([]byte{0xca, 0xfe}) })},
+		{"no data", 0, reviewed(func(review *OfflineReview) { review.DataHash = common.Hash{} })},
+		{"fee", 0, reviewed(func(review *OfflineReview) { review.MaxFeePerGas = new(big.Int).Sub(review.MaxFeePerGas, common.Big1) })},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := offlineTx.SignWithPrivateKey(chain.Keys[test.key], test.review)
+			if !errors.Is(err, ErrOfflineReview) {
+				t.Fatalf("expected ErrOfflineReview, got %v", err)
+			}
+			if offlineTx.Signed() {
+				t.Fatal("transaction signed")
+			}
+		})
+	}
+
+	// A transaction without value nor data matches a review leaving them out
+	plain, err := PrepareOfflineTransaction(context.Background(), chain, OfflineTxRequest{Type: types.LegacyTxType, From: from, To: &to})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := plain.SignWithPrivateKey(chain.Keys[0], OfflineReview{ChainID: chain.Config().ChainID, Nonce: plain.Transaction.Nonce(), To: &to}); err != nil {
+		t.Fatal(err)
+	}
+}
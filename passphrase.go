@@ -0,0 +1,145 @@
+package goethereumhelper
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"golang.org/x/term"
+)
+
+var (
+	// ErrPassphraseNotFound is returned by the providers that have no passphrase for an account
+	ErrPassphraseNotFound = errors.New("passphrase not found")
+	// ErrInsecurePassphraseFile is returned when a passphrase file can be read by other users
+	ErrInsecurePassphraseFile = errors.New("passphrase file is accessible by group or others")
+)
+
+// PassphraseProvider gives the passphrase decrypting the key of an account of a keystore, so the passphrases
+// are kept out of the code and configuration
+type PassphraseProvider interface {
+	Passphrase(account accounts.Account) (string, error)
+}
+
+// PassphraseFunc adapts a function to a PassphraseProvider
+type PassphraseFunc func(account accounts.Account) (string, error)
+
+// Passphrase calls f
+func (f PassphraseFunc) Passphrase(account accounts.Account) (string, error) {
+	return f(account)
+}
+
+// EnvPassphrase reads the passphrase of every account from the environment variable name
+func EnvPassphrase(name string) PassphraseProvider {
+	return PassphraseFunc(func(account accounts.Account) (passphrase string, err error) {
+		passphrase, found := os.LookupEnv(name)
+		if !found {
+			err = fmt.Errorf("%w: environment variable %s is not set", ErrPassphraseNotFound, name)
+		}
+		return
+	})
+}
+
+// FilePassphrase reads the passphrase of every account from the first line of the file at path. On Unix
+// systems it fails with ErrInsecurePassphraseFile unless only the owner can access the file, like
+// after chmod 600.
+func FilePassphrase(path string) PassphraseProvider {
+	return PassphraseFunc(func(account accounts.Account) (passphrase string, err error) {
+		info, err := os.Stat(path)
+		if err != nil {
+			err = fmt.Errorf("%w: %v", ErrPassphraseNotFound, err)
+			return
+		}
+		if runtime.GOOS != "windows" && info.Mode().Perm()&0o077 != 0 {
+			err = fmt.Errorf("%w: %s has mode %s", ErrInsecurePassphraseFile, path, info.Mode().Perm())
+			return
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return
+		}
+		passphrase, _, _ = strings.Cut(string(content), "\n")
+		passphrase = strings.TrimSuffix(passphrase, "\r")
+		return
+	})
+}
+
+// TerminalPassphrase asks the passphrase on the terminal, without echoing it. The prompt is written to
+// standard error and fails when standard input is not a terminal, like in services.
+func TerminalPassphrase() PassphraseProvider {
+	return PassphraseFunc(func(account accounts.Account) (passphrase string, err error) {
+		fd := int(os.Stdin.Fd())
+		if !term.IsTerminal(fd) {
+			err = fmt.Errorf("%w: standard input is not a terminal", ErrPassphraseNotFound)
+			return
+		}
+		fmt.Fprintf(os.Stderr, "Passphrase of account %s: ", account.Address.Hex())
+		read, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			err = fmt.Errorf("could not read passphrase: %w", err)
+			return
+		}
+		return string(read), nil
+	})
+}
+
+// PassphraseCache keeps the passphrases given by another provider for a while, so a terminal prompt is not
+// repeated for every transaction. It is safe for concurrent use.
+type PassphraseCache struct {
+	provider PassphraseProvider
+	ttl      time.Duration
+	mu       sync.Mutex
+	entries  map[common.Address]cachedPassphrase
+}
+
+type cachedPassphrase struct {
+	passphrase string
+	expires    time.Time
+}
+
+// NewPassphraseCache caches the passphrases of provider for ttl after they are read
+func NewPassphraseCache(provider PassphraseProvider, ttl time.Duration) *PassphraseCache {
+	return &PassphraseCache{
+		provider: provider,
+		ttl:      ttl,
+		entries:  make(map[common.Address]cachedPassphrase),
+	}
+}
+
+// Passphrase returns the cached passphrase of account, asking the provider when it is missing or expired
+func (c *PassphraseCache) Passphrase(account accounts.Account) (passphrase string, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, found := c.entries[account.Address]
+	if found && time.Now().Before(entry.expires) {
+		return entry.passphrase, nil
+	}
+	delete(c.entries, account.Address)
+	passphrase, err = c.provider.Passphrase(account)
+	if err != nil {
+		return
+	}
+	c.entries[account.Address] = cachedPassphrase{passphrase: passphrase, expires: time.Now().Add(c.ttl)}
+	return
+}
+
+// Forget removes the cached passphrase of address, for example after it failed to decrypt the key
+func (c *PassphraseCache) Forget(address common.Address) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, address)
+}
+
+// Clear removes every cached passphrase
+func (c *PassphraseCache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[common.Address]cachedPassphrase)
+}
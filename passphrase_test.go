@@ -0,0 +1,151 @@
+package goethereumhelper
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestEnvPassphrase(t *testing.T) {
+	t.Setenv("GOETHEREUMHELPER_TEST_PASSPHRASE", "secret")
+	passphrase, err := EnvPassphrase("GOETHEREUMHELPER_TEST_PASSPHRASE").Passphrase(accounts.Account{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if passphrase != "secret" {
+		t.Fatalf("read passphrase %q", passphrase)
+	}
+	if _, err := EnvPassphrase("GOETHEREUMHELPER_TEST_UNSET").Passphrase(accounts.Account{}); !errors.Is(err, ErrPassphraseNotFound) {
+		t.Fatalf("expected ErrPassphraseNotFound, got %v", err)
+	}
+}
+
+func TestFilePassphrase(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name       string
+		content    string
+		mode       os.FileMode
+		passphrase string
+		err        error
+	}{
+		{name: "first line", content: "secret\nother\n", mode: 0o600, passphrase: "secret"},
+		{name: "windows line ending", content: "secret\r\nother", mode: 0o600, passphrase: "secret"},
+		{name: "no line ending", content: "secret", mode: 0o400, passphrase: "secret"},
+		{name: "readable by others", content: "secret\n", mode: 0o644, err: ErrInsecurePassphraseFile},
+		{name: "readable by group", content: "secret\n", mode: 0o640, err: ErrInsecurePassphraseFile},
+		{name: "missing", err: ErrPassphraseNotFound},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if runtime.GOOS == "windows" && errors.Is(test.err, ErrInsecurePassphraseFile) {
+				t.Skip("file permissions are not checked on Windows")
+			}
+			path := filepath.Join(dir, test.name)
+			if test.mode != 0 {
+				if err := os.WriteFile(path, []byte(test.content), test.mode); err != nil {
+					t.Fatal(err)
+				}
+				// WriteFile applies the umask
+				if err := os.Chmod(path, test.mode); err != nil {
+					t.Fatal(err)
+				}
+			}
+			passphrase, err := FilePassphrase(path).Passphrase(accounts.Account{})
+			if !errors.Is(err, test.err) {
+				t.Fatalf("expected %v, got %v", test.err, err)
+			}
+			if passphrase != test.passphrase {
+				t.Fatalf("read passphrase %q, expected %q", passphrase, test.passphrase)
+			}
+		})
+	}
+}
+
+func TestTerminalPassphrase(t *testing.T) {
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	defer writer.Close()
+	stdin := os.Stdin
+	os.Stdin = reader
+	defer func() { os.Stdin = stdin }()
+
+	if _, err := TerminalPassphrase().Passphrase(accounts.Account{}); !errors.Is(err, ErrPassphraseNotFound) {
+		t.Fatalf("expected ErrPassphraseNotFound reading from a pipe, got %v", err)
+	}
+}
+
+func TestPassphraseCache(t *testing.T) {
+	var mu sync.Mutex
+	calls := make(map[common.Address]int)
+	failing := common.HexToAddress("0x02")
+	provider := PassphraseFunc(func(account accounts.Account) (string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls[account.Address]++
+		if account.Address == failing {
+			return "", ErrPassphraseNotFound
+		}
+		return "secret-" + account.Address.Hex(), nil
+	})
+	called := func(address common.Address) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return calls[address]
+	}
+	first := accounts.Account{Address: common.HexToAddress("0x01")}
+	cache := NewPassphraseCache(provider, 50*time.Millisecond)
+
+	for i := 0; i < 3; i++ {
+		passphrase, err := cache.Passphrase(first)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if passphrase != "secret-"+first.Address.Hex() {
+			t.Fatalf("cached passphrase %q", passphrase)
+		}
+	}
+	if called(first.Address) != 1 {
+		t.Fatalf("provider called %d times within the ttl", called(first.Address))
+	}
+
+	// Errors are not cached
+	for i := 0; i < 2; i++ {
+		if _, err := cache.Passphrase(accounts.Account{Address: failing}); !errors.Is(err, ErrPassphraseNotFound) {
+			t.Fatalf("expected ErrPassphraseNotFound, got %v", err)
+		}
+	}
+	if called(failing) != 2 {
+		t.Fatalf("provider called %d times for a failing account", called(failing))
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if _, err := cache.Passphrase(first); err != nil {
+		t.Fatal(err)
+	}
+	if called(first.Address) != 2 {
+		t.Fatal("expired passphrase not read again")
+	}
+
+	cache.Forget(first.Address)
+	if _, err := cache.Passphrase(first); err != nil {
+		t.Fatal(err)
+	}
+	cache.Clear()
+	if _, err := cache.Passphrase(first); err != nil {
+		t.Fatal(err)
+	}
+	if called(first.Address) != 4 {
+		t.Fatalf("provider called %d times, expected Forget and Clear to drop the passphrase", called(first.Address))
+	}
+}
@@ -0,0 +1,301 @@
+package goethereumhelper
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// ErrPolicyViolation is returned by PolicySigner.SignTx for the transactions its SigningPolicy rejects
+var ErrPolicyViolation = errors.New("signing policy violation")
+
+// spendingWindow is the period of SigningPolicy.MaxValuePerDay
+const spendingWindow = 24 * time.Hour
+
+// SigningPolicy restricts the transactions a PolicySigner signs. Zero value fields do not restrict them.
+type SigningPolicy struct {
+	// AllowedRecipients and AllowedContracts, when one of them is not empty, are the only destinations of
+	// transactions with data. AllowedRecipients, when not empty, also restricts the transactions without data,
+	// which may go to the addresses of both lists.
+	AllowedRecipients []common.Address
+	AllowedContracts  []common.Address
+	DeniedRecipients  []common.Address // Destinations refused for any transaction, with data or not
+	DeniedContracts   []common.Address // Destinations refused for transactions with data
+	// AllowedMethods lists, per contract, the method selectors callable on it, like abi.Methods["transfer"].ID.
+	// Contracts not listed accept any method.
+	AllowedMethods        map[common.Address][][4]byte
+	AllowContractCreation bool
+
+	MaxValuePerTx  *big.Int // Wei sent by a transaction
+	MaxValuePerDay *big.Int // Wei sent by the transactions signed during the last 24 hours
+
+	MaxFeePerGas         *big.Int // Gas fee cap, or gas price of legacy transactions
+	MaxPriorityFeePerGas *big.Int // Gas tip cap
+	MaxTxFee             *big.Int // Gas limit times the gas fee cap, the most a transaction can pay in fees
+}
+
+// AuditEntry describes a transaction submitted to a PolicySigner
+type AuditEntry struct {
+	Time      time.Time       `json:"time"`
+	Signer    common.Address  `json:"signer"`
+	ChainID   *big.Int        `json:"chainId"`
+	Nonce     uint64          `json:"nonce"`
+	To        *common.Address `json:"to"` // nil for contract creations
+	Value     *big.Int        `json:"value"`
+	Selector  hexutil.Bytes   `json:"selector,omitempty"`
+	Gas       uint64          `json:"gas"`
+	GasFeeCap *big.Int        `json:"gasFeeCap"`
+	Approved  bool            `json:"approved"`
+	Reason    string          `json:"reason,omitempty"` // Policy violated, or signer error, when not approved
+	TxHash    *common.Hash    `json:"txHash,omitempty"` // Hash of the signed transaction when approved
+}
+
+// AuditLog records the transactions submitted to a PolicySigner, approved or not. A PolicySigner does not
+// return a transaction its AuditLog failed to record.
+type AuditLog interface {
+	Record(entry AuditEntry) error
+}
+
+// AuditLogFunc adapts a function to an AuditLog
+type AuditLogFunc func(entry AuditEntry) error
+
+// Record calls f
+func (f AuditLogFunc) Record(entry AuditEntry) error {
+	return f(entry)
+}
+
+// jsonAuditLog writes the entries as JSON lines
+type jsonAuditLog struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+}
+
+// NewJSONAuditLog returns an AuditLog writing every entry as a line of JSON to w, like a file opened with
+// os.O_APPEND. Write errors are returned, so signing stops when the log cannot be written, like on a full disk.
+func NewJSONAuditLog(w io.Writer) AuditLog {
+	return &jsonAuditLog{encoder: json.NewEncoder(w)}
+}
+
+func (l *jsonAuditLog) Record(entry AuditEntry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.encoder.Encode(entry)
+}
+
+// PolicySigner is a TxSigner refusing the transactions its SigningPolicy does not allow before passing the
+// others to another TxSigner. It is safe for concurrent use.
+//
+// Example, with the send and transactor helpers:
+//
+//	signer := NewPolicySigner(NewPrivateKeySigner(key), SigningPolicy{
+//		AllowedRecipients: []common.Address{exchange},
+//		MaxValuePerDay:    ether,
+//		MaxFeePerGas:      big.NewInt(200 * params.GWei),
+//	}, NewJSONAuditLog(auditFile))
+//	tx, err := SendEtherUsingSigner(client, signer, exchange, value)
+//	txOpts := NewSignerTransactor(signer, chainID)
+type PolicySigner struct {
+	signer TxSigner
+	policy SigningPolicy
+	audit  AuditLog
+
+	mu    sync.Mutex
+	spent []*spending // Values signed, or being signed, during the last 24 hours, oldest first
+}
+
+// spending is the value of a signed transaction
+type spending struct {
+	at    time.Time
+	value *big.Int
+}
+
+// NewPolicySigner returns a signer applying policy to the transactions signed by signer. Every transaction is
+// recorded in audit, which may be nil.
+func NewPolicySigner(signer TxSigner, policy SigningPolicy, audit AuditLog) *PolicySigner {
+	return &PolicySigner{signer: signer, policy: policy, audit: audit}
+}
+
+// Address returns the address of the wrapped signer
+func (s *PolicySigner) Address() common.Address {
+	return s.signer.Address()
+}
+
+// SpentToday returns the wei sent by the transactions signed during the last 24 hours. Transactions signed
+// but never mined are counted too, as well as the ones being signed.
+func (s *PolicySigner) SpentToday() *big.Int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.spentSince(time.Now().Add(-spendingWindow))
+}
+
+// SignTx signs tx with the wrapped signer when the policy allows it, and fails with ErrPolicyViolation
+// otherwise. The value of tx is reserved in the daily limit while the wrapped signer runs, so concurrent
+// transactions cannot exceed it, and released when signing or the audit log fails.
+func (s *PolicySigner) SignTx(tx *types.Transaction, chainID *big.Int) (signedTx *types.Transaction, err error) {
+	s.mu.Lock()
+	now := time.Now()
+	violation := s.check(tx, now)
+	var reserved *spending
+	if violation == "" && tx.Value().Sign() > 0 {
+		reserved = &spending{at: now, value: tx.Value()}
+		s.spent = append(s.spent, reserved)
+	}
+	s.mu.Unlock()
+
+	entry := AuditEntry{
+		Time:      now,
+		Signer:    s.signer.Address(),
+		ChainID:   chainID,
+		Nonce:     tx.Nonce(),
+		To:        tx.To(),
+		Value:     tx.Value(),
+		Gas:       tx.Gas(),
+		GasFeeCap: tx.GasFeeCap(),
+	}
+	if len(tx.Data()) >= 4 {
+		entry.Selector = tx.Data()[:4]
+	}
+	if violation != "" {
+		entry.Reason = violation
+		err = fmt.Errorf("%w: %s", ErrPolicyViolation, violation)
+	} else {
+		signedTx, err = s.signer.SignTx(tx, chainID)
+		if err != nil {
+			entry.Reason = err.Error()
+		} else {
+			hash := signedTx.Hash()
+			entry.Approved = true
+			entry.TxHash = &hash
+		}
+	}
+	if s.audit != nil {
+		errAudit := s.audit.Record(entry)
+		if errAudit != nil && err == nil {
+			signedTx, err = nil, fmt.Errorf("could not record the transaction in the audit log: %w", errAudit)
+		} else if errAudit != nil {
+			err = fmt.Errorf("%w, and could not record it in the audit log: %v", err, errAudit)
+		}
+	}
+	if err != nil && reserved != nil {
+		s.release(reserved)
+	}
+	return
+}
+
+// release removes a spending reserved by SignTx for a transaction that was not signed
+func (s *PolicySigner) release(reserved *spending) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, spent := range s.spent {
+		if spent == reserved {
+			s.spent = append(s.spent[:i:i], s.spent[i+1:]...)
+			return
+		}
+	}
+}
+
+// check returns the rule of the policy tx breaks, or an empty string when it is allowed. It must be called
+// with the mutex held.
+func (s *PolicySigner) check(tx *types.Transaction, now time.Time) (violation string) {
+	policy := s.policy
+	to := tx.To()
+	switch {
+	case to == nil:
+		if !policy.AllowContractCreation {
+			return "contract creation is not allowed"
+		}
+	case containsAddress(policy.DeniedRecipients, *to):
+		return fmt.Sprintf("recipient %s is denied", to.Hex())
+	case len(tx.Data()) == 0:
+		if len(policy.AllowedRecipients) > 0 && !policy.allowlisted(*to) {
+			return fmt.Sprintf("recipient %s is not allowed", to.Hex())
+		}
+	default:
+		if containsAddress(policy.DeniedContracts, *to) {
+			return fmt.Sprintf("contract %s is denied", to.Hex())
+		}
+		// Adding calldata must not get around the recipient allowlist, so it restricts calls too
+		if (len(policy.AllowedContracts) > 0 || len(policy.AllowedRecipients) > 0) && !policy.allowlisted(*to) {
+			return fmt.Sprintf("contract %s is not allowed", to.Hex())
+		}
+		if selectors, found := policy.AllowedMethods[*to]; found && !containsSelector(selectors, tx.Data()) {
+			selector := tx.Data()
+			if len(selector) > 4 {
+				selector = selector[:4]
+			}
+			return fmt.Sprintf("method %s of contract %s is not allowed", hexutil.Encode(selector), to.Hex())
+		}
+	}
+
+	if policy.MaxValuePerTx != nil && tx.Value().Cmp(policy.MaxValuePerTx) > 0 {
+		return fmt.Sprintf("value %s exceeds the limit of %s per transaction", tx.Value(), policy.MaxValuePerTx)
+	}
+	if policy.MaxValuePerDay != nil && tx.Value().Sign() > 0 {
+		total := new(big.Int).Add(s.spentSince(now.Add(-spendingWindow)), tx.Value())
+		if total.Cmp(policy.MaxValuePerDay) > 0 {
+			return fmt.Sprintf("value %s would bring the last 24 hours total to %s, over the limit of %s", tx.Value(), total, policy.MaxValuePerDay)
+		}
+	}
+
+	if policy.MaxFeePerGas != nil && tx.GasFeeCap().Cmp(policy.MaxFeePerGas) > 0 {
+		return fmt.Sprintf("gas fee cap %s exceeds the limit of %s", tx.GasFeeCap(), policy.MaxFeePerGas)
+	}
+	if policy.MaxPriorityFeePerGas != nil && tx.GasTipCap().Cmp(policy.MaxPriorityFeePerGas) > 0 {
+		return fmt.Sprintf("gas tip cap %s exceeds the limit of %s", tx.GasTipCap(), policy.MaxPriorityFeePerGas)
+	}
+	if policy.MaxTxFee != nil {
+		fee := new(big.Int).Mul(tx.GasFeeCap(), new(big.Int).SetUint64(tx.Gas()))
+		if fee.Cmp(policy.MaxTxFee) > 0 {
+			return fmt.Sprintf("maximum fee %s exceeds the limit of %s", fee, policy.MaxTxFee)
+		}
+	}
+	return
+}
+
+// spentSince drops the spendings older than since and sums the others. It must be called with the mutex held.
+func (s *PolicySigner) spentSince(since time.Time) (total *big.Int) {
+	for len(s.spent) > 0 && !s.spent[0].at.After(since) {
+		s.spent = s.spent[1:]
+	}
+	total = new(big.Int)
+	for _, spent := range s.spent {
+		total.Add(total, spent.value)
+	}
+	return
+}
+
+// allowlisted tells if address is in AllowedRecipients or AllowedContracts
+func (p SigningPolicy) allowlisted(address common.Address) bool {
+	return containsAddress(p.AllowedRecipients, address) || containsAddress(p.AllowedContracts, address)
+}
+
+func containsAddress(addresses []common.Address, address common.Address) bool {
+	for _, candidate := range addresses {
+		if candidate == address {
+			return true
+		}
+	}
+	return false
+}
+
+// containsSelector tells if data calls one of the selectors
+func containsSelector(selectors [][4]byte, data []byte) bool {
+	if len(data) < 4 {
+		return false
+	}
+	for _, selector := range selectors {
+		if bytes.Equal(selector[:], data[:4]) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,209 @@
+package goethereumhelper
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestPolicySigner(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	exchange := common.HexToAddress("0x01")
+	denied := common.HexToAddress("0x02")
+	token := common.HexToAddress("0x03")
+	other := common.HexToAddress("0x04")
+	transfer := [4]byte{0xa9, 0x05, 0x9c, 0xbb}
+	approve := [4]byte{0x09, 0x5e, 0xa7, 0xb3}
+
+	var audit bytes.Buffer
+	signer := NewPolicySigner(NewPrivateKeySigner(key), SigningPolicy{
+		AllowedRecipients: []common.Address{exchange},
+		AllowedContracts:  []common.Address{token},
+		DeniedRecipients:  []common.Address{denied},
+		AllowedMethods:    map[common.Address][][4]byte{token: {transfer}},
+		MaxValuePerTx:     big.NewInt(100),
+		MaxValuePerDay:    big.NewInt(150),
+		MaxFeePerGas:      big.NewInt(1000),
+		MaxTxFee:          big.NewInt(1000 * 30000),
+	}, NewJSONAuditLog(&audit))
+	chainID := big.NewInt(1337)
+	newTx := func(to *common.Address, value int64, data []byte, gasFeeCap int64, gas uint64) *types.Transaction {
+		return types.NewTx(&types.DynamicFeeTx{
+			ChainID:   chainID,
+			To:        to,
+			Value:     big.NewInt(value),
+			Data:      data,
+			GasFeeCap: big.NewInt(gasFeeCap),
+			GasTipCap: big.NewInt(1),
+			Gas:       gas,
+		})
+	}
+
+	tests := []struct {
+		name      string
+		tx        *types.Transaction
+		violation string // Empty when the transaction must be signed
+	}{
+		{"allowed recipient", newTx(&exchange, 100, nil, 10, 21000), ""},
+		{"value per transaction", newTx(&exchange, 101, nil, 10, 21000), "per transaction"},
+		{"recipient not allowed", newTx(&other, 1, nil, 10, 21000), "recipient 0x0000000000000000000000000000000000000004 is not allowed"},
+		{"calldata to recipient not allowed", newTx(&other, 1, []byte{0}, 10, 21000), "contract 0x0000000000000000000000000000000000000004 is not allowed"},
+		{"denied recipient with calldata", newTx(&denied, 1, []byte{1, 2, 3, 4}, 10, 21000), "denied"},
+		{"contract creation", newTx(nil, 0, []byte{1}, 10, 21000), "contract creation"},
+		{"allowed method", newTx(&token, 0, append(transfer[:], 0), 10, 21000), ""},
+		{"method not allowed", newTx(&token, 0, approve[:], 10, 21000), "method 0x095ea7b3"},
+		{"gas fee cap", newTx(&exchange, 1, nil, 1001, 21000), "gas fee cap"},
+		{"maximum fee", newTx(&exchange, 1, nil, 1000, 30001), "maximum fee"},
+		{"value per day", newTx(&exchange, 51, nil, 10, 21000), "24 hours"},
+		{"value per day reached", newTx(&exchange, 50, nil, 10, 21000), ""},
+	}
+	for _, test := range tests {
+		signedTx, err := signer.SignTx(test.tx, chainID)
+		if test.violation == "" {
+			if err != nil {
+				t.Fatalf("%s: %v", test.name, err)
+			}
+			sender, err := types.Sender(types.LatestSignerForChainID(chainID), signedTx)
+			if err != nil || sender != signer.Address() {
+				t.Fatalf("%s: signed by %s, %v", test.name, sender.Hex(), err)
+			}
+			continue
+		}
+		if !errors.Is(err, ErrPolicyViolation) || !strings.Contains(err.Error(), test.violation) {
+			t.Fatalf("%s: expected violation %q, got %v", test.name, test.violation, err)
+		}
+	}
+
+	if spent := signer.SpentToday(); spent.Int64() != 150 {
+		t.Fatalf("spent today is %s, expected 150", spent)
+	}
+	signer.spent[0].at = time.Now().Add(-25 * time.Hour)
+	if spent := signer.SpentToday(); spent.Int64() != 50 {
+		t.Fatalf("spent today is %s after a day, expected 50", spent)
+	}
+
+	var entries []AuditEntry
+	decoder := json.NewDecoder(&audit)
+	for decoder.More() {
+		var entry AuditEntry
+		if err := decoder.Decode(&entry); err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, entry)
+	}
+	if len(entries) != len(tests) {
+		t.Fatalf("audit log has %d entries, expected %d", len(entries), len(tests))
+	}
+	for i, test := range tests {
+		if entries[i].Approved != (test.violation == "") || (entries[i].TxHash == nil) == entries[i].Approved {
+			t.Fatalf("%s: wrong audit entry %+v", test.name, entries[i])
+		}
+	}
+}
+
+// failingWriter is an io.Writer always failing, like a full disk
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("no space left on device")
+}
+
+func TestPolicySignerFailures(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	to := common.HexToAddress("0x01")
+	chainID := big.NewInt(1337)
+	tx := types.NewTx(&types.DynamicFeeTx{ChainID: chainID, To: &to, Value: big.NewInt(10), GasFeeCap: big.NewInt(10), Gas: 21000})
+	policy := SigningPolicy{MaxValuePerDay: big.NewInt(10)}
+
+	tests := []struct {
+		name   string
+		signer TxSigner
+		audit  AuditLog
+		err    string
+	}{
+		{"audit log", NewPrivateKeySigner(key), NewJSONAuditLog(failingWriter{}), "no space left on device"},
+		{"signer", failingSigner{NewPrivateKeySigner(key)}, nil, "signer unavailable"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			signer := NewPolicySigner(test.signer, policy, test.audit)
+			signedTx, err := signer.SignTx(tx, chainID)
+			if err == nil || signedTx != nil || !strings.Contains(err.Error(), test.err) {
+				t.Fatalf("expected %q, got %v", test.err, err)
+			}
+			// The value reserved for the transaction is released
+			if spent := signer.SpentToday(); spent.Sign() != 0 {
+				t.Fatalf("spent today is %s, expected 0", spent)
+			}
+		})
+	}
+
+	// A violation the audit log fails to record is still refused
+	signer := NewPolicySigner(NewPrivateKeySigner(key), SigningPolicy{MaxValuePerTx: big.NewInt(1)}, NewJSONAuditLog(failingWriter{}))
+	if _, err := signer.SignTx(tx, chainID); !errors.Is(err, ErrPolicyViolation) || !strings.Contains(err.Error(), "audit log") {
+		t.Fatalf("expected a violation not recorded, got %v", err)
+	}
+}
+
+// blockingSigner is a TxSigner waiting for release before signing
+type blockingSigner struct {
+	TxSigner
+	signing chan struct{}
+	release chan struct{}
+}
+
+func (s blockingSigner) SignTx(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	s.signing <- struct{}{}
+	<-s.release
+	return s.TxSigner.SignTx(tx, chainID)
+}
+
+func TestPolicySignerConcurrentLimit(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	to := common.HexToAddress("0x01")
+	chainID := big.NewInt(1337)
+	newTx := func(nonce uint64) *types.Transaction {
+		return types.NewTx(&types.DynamicFeeTx{ChainID: chainID, Nonce: nonce, To: &to, Value: big.NewInt(10), GasFeeCap: big.NewInt(10), Gas: 21000})
+	}
+	wrapped := blockingSigner{TxSigner: NewPrivateKeySigner(key), signing: make(chan struct{}), release: make(chan struct{})}
+	signer := NewPolicySigner(wrapped, SigningPolicy{MaxValuePerDay: big.NewInt(15)}, nil)
+
+	signed := make(chan error)
+	go func() {
+		_, err := signer.SignTx(newTx(0), chainID)
+		signed <- err
+	}()
+	<-wrapped.signing
+
+	// The policy is not locked while the first transaction is signed, and its value is already counted
+	if spent := signer.SpentToday(); spent.Int64() != 10 {
+		t.Fatalf("spent today is %s while signing, expected 10", spent)
+	}
+	if _, err := signer.SignTx(newTx(1), chainID); !errors.Is(err, ErrPolicyViolation) {
+		t.Fatalf("expected the daily limit reached, got %v", err)
+	}
+	close(wrapped.release)
+	if err := <-signed; err != nil {
+		t.Fatal(err)
+	}
+	if spent := signer.SpentToday(); spent.Int64() != 10 {
+		t.Fatalf("spent today is %s, expected 10", spent)
+	}
+}
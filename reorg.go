@@ -0,0 +1,100 @@
+package goethereumhelper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// ForkFrom starts a branch on top of the canonical block number. The transactions sent afterwards and the
+// blocks mined with Commit or MineBlocks go into the branch, while the chain keeps serving the previous
+// head until the branch is made canonical with SetCanonical or grows longer than it.
+// It fails when there are pending transactions: Commit or DiscardPending them first.
+// Balance, code and storage cheats and impersonated transactions always go on top of the canonical head.
+func (c *TestChain) ForkFrom(number uint64) (err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.forkFromLocked(number)
+}
+
+// forkFromLocked is ForkFrom for callers already holding c.mu
+func (c *TestChain) forkFromLocked(number uint64) (err error) {
+	blockchain := c.SimulatedBackend.Blockchain()
+	if head := blockchain.CurrentBlock().Number.Uint64(); number > head {
+		return fmt.Errorf("could not fork from block %d, the chain head is block %d", number, head)
+	}
+	parent := blockchain.GetCanonicalHash(number)
+	err = c.SimulatedBackend.Fork(context.Background(), parent)
+	if err != nil {
+		err = fmt.Errorf("could not fork from block %d: %w", number, err)
+	}
+	return
+}
+
+// SetCanonical makes the block head and its ancestors the canonical chain, even when the branch is shorter
+// than the current one. Blocks and transactions of the replaced branch are dropped from the chain, their
+// logs are sent again to the subscribers with Removed set, and receipts are served from the new branch.
+func (c *TestChain) SetCanonical(head common.Hash) (err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	blockchain := c.SimulatedBackend.Blockchain()
+	block := blockchain.GetBlockByHash(head)
+	if block == nil {
+		return fmt.Errorf("could not set block %s as canonical: block not found", head.Hex())
+	}
+	if blockchain.CurrentBlock().Hash() != head {
+		_, err = blockchain.SetCanonical(block)
+		if err != nil {
+			return fmt.Errorf("could not set block %s as canonical: %w", head.Hex(), err)
+		}
+	}
+	c.SimulatedBackend.Rollback()
+	return
+}
+
+// Reorg replaces the latest depth blocks by the branch mined in build, which sends the transactions of the
+// new branch, commits them and returns the hash of the last block mined. When the branch is shorter than
+// depth the chain head goes back. When build fails the branch is dropped and the previous head restored.
+//
+// build mines with the TestChain methods, so the chain is not locked while it runs: blocks mined by other
+// goroutines meanwhile go into the branch as well.
+//
+// Example, checking a confirmation watcher drops a transaction reorged out of the chain:
+//
+//	chain, _ := NewTestChain()
+//	// ... send the transaction and mine it ...
+//	err := chain.Reorg(1, func() (common.Hash, error) {
+//		return chain.MineBlocks(2), nil
+//	})
+//	if err != nil {
+//		t.Fatal(err)
+//	}
+//	// ... the receipt of the transaction is not found anymore ...
+func (c *TestChain) Reorg(depth uint64, build func() (head common.Hash, err error)) (err error) {
+	if build == nil {
+		return errors.New("could not reorg without a function building the new branch")
+	}
+	c.mu.Lock()
+	previous := c.SimulatedBackend.Blockchain().CurrentBlock()
+	if current := previous.Number.Uint64(); depth > current {
+		c.mu.Unlock()
+		return fmt.Errorf("could not reorg %d blocks, the chain head is block %d", depth, current)
+	}
+	err = c.forkFromLocked(previous.Number.Uint64() - depth)
+	c.mu.Unlock()
+	if err != nil {
+		return
+	}
+	head, err := build()
+	if err != nil {
+		// The branch may already be canonical if it grew longer than the previous head
+		errRestore := c.SetCanonical(previous.Hash())
+		if errRestore != nil {
+			err = fmt.Errorf("%w, and could not restore the previous head: %v", err, errRestore)
+		}
+		return
+	}
+	return c.SetCanonical(head)
+}
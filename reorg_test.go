@@ -0,0 +1,90 @@
+package goethereumhelper
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestReorg(t *testing.T) {
+	chain, err := NewTestChain()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Close()
+	ctx := context.Background()
+	tx, err := chain.Accounts[0].Signer(chain.Account(0), types.NewTransaction(0, chain.Account(1), big.NewInt(1), 21000, big.NewInt(2e9), nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := chain.SendTransaction(ctx, tx); err != nil {
+		t.Fatal(err)
+	}
+	chain.Commit()
+
+	err = chain.Reorg(1, func() (common.Hash, error) {
+		return chain.MineBlocks(2), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head := chain.Blockchain().CurrentBlock().Number.Uint64(); head != 2 {
+		t.Fatalf("head is block %d, expected 2", head)
+	}
+	if _, err := chain.TransactionReceipt(ctx, tx.Hash()); !errors.Is(err, ethereum.NotFound) {
+		t.Fatalf("expected the reorged transaction to be dropped, got %v", err)
+	}
+	if err := chain.Reorg(3, nil); err == nil {
+		t.Fatal("expected an error reorging past the genesis")
+	}
+}
+
+func TestReorgFailedBuild(t *testing.T) {
+	chain, err := NewTestChain()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Close()
+	ctx := context.Background()
+	previous := chain.MineBlocks(2)
+
+	if err := chain.Reorg(1, nil); err == nil {
+		t.Fatal("expected an error reorging without a build function")
+	}
+	if head := chain.Blockchain().CurrentBlock().Hash(); head != previous {
+		t.Fatal("chain head changed by a rejected reorg")
+	}
+
+	// The branch grows longer than the previous head and has a pending transaction when build fails
+	errBuild := errors.New("build failed")
+	var branchTx *types.Transaction
+	err = chain.Reorg(1, func() (common.Hash, error) {
+		chain.MineBlocks(3)
+		branchTx = sendTestTx(t, chain, 0, chain.Account(1), big.NewInt(1))
+		return common.Hash{}, errBuild
+	})
+	if !errors.Is(err, errBuild) {
+		t.Fatalf("expected the build error, got %v", err)
+	}
+	if head := chain.Blockchain().CurrentBlock().Hash(); head != previous {
+		t.Fatalf("head is block %d, expected the previous head restored", chain.Blockchain().CurrentBlock().Number)
+	}
+
+	// Blocks mined afterwards go on top of the restored head, without the transaction of the branch
+	head := chain.MineBlocks(1)
+	block, err := chain.BlockByHash(ctx, head)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if block.ParentHash() != previous || len(block.Transactions()) != 0 {
+		t.Fatalf("block %d mined on %s with %d transactions", block.NumberU64(), block.ParentHash().Hex(), len(block.Transactions()))
+	}
+	if _, err := chain.TransactionReceipt(ctx, branchTx.Hash()); !errors.Is(err, ethereum.NotFound) {
+		t.Fatalf("expected the branch transaction to be dropped, got %v", err)
+	}
+}
@@ -0,0 +1,183 @@
+package goethereumhelper
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var (
+	// ErrInvalidSignature is returned for signatures that are not 64 or 65 bytes long or have a wrong V, R or S
+	ErrInvalidSignature = errors.New("invalid signature")
+	// ErrMalleableSignature is returned for signatures whose S is in the upper half of the curve order. They
+	// are valid but anyone can derive a second signature of the same message from them, so Ethereum
+	// (EIP-2) and OpenZeppelin ECDSA reject them. ToLowS converts them.
+	ErrMalleableSignature = errors.New("signature S value is not in the lower half of the curve order")
+)
+
+var (
+	secp256k1N     = crypto.S256().Params().N
+	secp256k1HalfN = new(big.Int).Rsh(secp256k1N, 1)
+)
+
+// SignPersonalMessage signs message as personal_sign and eth_sign do (EIP-191 version 0x45), returning a
+// 65 bytes [R || S || V] signature with V 27 or 28
+func SignPersonalMessage(privateKey *ecdsa.PrivateKey, message []byte) (signature []byte, err error) {
+	signature, err = crypto.Sign(accounts.TextHash(message), privateKey)
+	if err != nil {
+		return
+	}
+	signature[crypto.RecoveryIDOffset] += 27
+	return
+}
+
+// SignPersonalMessage signs message with the unlocked wallet account, like the SignPersonalMessage function
+func (w *KeystoreWallet) SignPersonalMessage(message []byte) (signature []byte, err error) {
+	signature, err = w.Keystore.SignHash(w.Account, accounts.TextHash(message))
+	if err != nil {
+		return
+	}
+	w.signed()
+	signature[crypto.RecoveryIDOffset] += 27
+	return
+}
+
+// RecoverPersonalSigner returns the address that signed message with personal_sign
+func RecoverPersonalSigner(message, signature []byte) (signer common.Address, err error) {
+	return RecoverSigner(common.BytesToHash(accounts.TextHash(message)), signature)
+}
+
+// VerifyPersonalMessage tells if signature of message, made with personal_sign, was made by address
+func VerifyPersonalMessage(message, signature []byte, address common.Address) (valid bool, err error) {
+	return VerifySignature(common.BytesToHash(accounts.TextHash(message)), signature, address)
+}
+
+// RecoverSigner returns the address whose key signed hash. The signature may be 65 bytes long with V 0, 1,
+// 27 or 28, or 64 bytes long in the EIP-2098 compact form. It fails with ErrMalleableSignature when S is
+// not low.
+func RecoverSigner(hash common.Hash, signature []byte) (signer common.Address, err error) {
+	sig, err := NormalizeSignature(signature)
+	if err != nil {
+		return
+	}
+	if !IsLowS(sig) {
+		err = ErrMalleableSignature
+		return
+	}
+	publicKey, err := crypto.SigToPub(hash.Bytes(), sig)
+	if err != nil {
+		err = fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+		return
+	}
+	return crypto.PubkeyToAddress(*publicKey), nil
+}
+
+// VerifySignature tells if signature of hash was made by address. Signatures are accepted in the forms
+// RecoverSigner does.
+func VerifySignature(hash common.Hash, signature []byte, address common.Address) (valid bool, err error) {
+	signer, err := RecoverSigner(hash, signature)
+	if err != nil {
+		return
+	}
+	return signer == address, nil
+}
+
+// NormalizeSignature returns a copy of signature with V 0 or 1, the form used by go-ethereum crypto
+// functions. It accepts V 0, 1, 27 or 28 and EIP-2098 compact signatures.
+func NormalizeSignature(signature []byte) (normalized []byte, err error) {
+	switch len(signature) {
+	case 64:
+		return ExpandCompactSignature(signature)
+	case crypto.SignatureLength:
+	default:
+		err = fmt.Errorf("%w: length is %d bytes", ErrInvalidSignature, len(signature))
+		return
+	}
+	normalized = make([]byte, crypto.SignatureLength)
+	copy(normalized, signature)
+	if normalized[crypto.RecoveryIDOffset] >= 27 {
+		normalized[crypto.RecoveryIDOffset] -= 27
+	}
+	if normalized[crypto.RecoveryIDOffset] > 1 {
+		err = fmt.Errorf("%w: V is %d", ErrInvalidSignature, signature[crypto.RecoveryIDOffset])
+		return nil, err
+	}
+	return
+}
+
+// EthereumSignature returns a copy of signature with V 27 or 28, the form returned by personal_sign and
+// expected by Solidity ecrecover
+func EthereumSignature(signature []byte) (converted []byte, err error) {
+	converted, err = NormalizeSignature(signature)
+	if err != nil {
+		return
+	}
+	converted[crypto.RecoveryIDOffset] += 27
+	return
+}
+
+// IsLowS tells if the S value of the 64 or 65 bytes signature is in the lower half of the curve order
+func IsLowS(signature []byte) bool {
+	if len(signature) < 64 {
+		return false
+	}
+	s := new(big.Int).SetBytes(signature[32:64])
+	return s.Sign() > 0 && s.Cmp(secp256k1HalfN) <= 0
+}
+
+// ToLowS returns a copy of the 65 bytes signature with S in the lower half of the curve order, flipping the
+// parity of V when S is replaced by N - S. Both signatures recover the same address. V keeps its convention,
+// 0 or 1, or 27 or 28.
+func ToLowS(signature []byte) (converted []byte, err error) {
+	if len(signature) != crypto.SignatureLength {
+		err = fmt.Errorf("%w: length is %d bytes", ErrInvalidSignature, len(signature))
+		return
+	}
+	converted, err = NormalizeSignature(signature)
+	if err != nil {
+		return
+	}
+	if !IsLowS(converted) {
+		s := new(big.Int).SetBytes(converted[32:64])
+		new(big.Int).Sub(secp256k1N, s).FillBytes(converted[32:64])
+		converted[crypto.RecoveryIDOffset] ^= 1
+	}
+	if signature[crypto.RecoveryIDOffset] >= 27 {
+		converted[crypto.RecoveryIDOffset] += 27
+	}
+	return
+}
+
+// CompactSignature converts a 65 bytes signature to the 64 bytes EIP-2098 form, where the parity of V is
+// stored in the top bit of S. S must be low.
+func CompactSignature(signature []byte) (compact []byte, err error) {
+	normalized, err := NormalizeSignature(signature)
+	if err != nil {
+		return
+	}
+	if !IsLowS(normalized) {
+		err = ErrMalleableSignature
+		return
+	}
+	compact = normalized[:64]
+	compact[32] |= normalized[crypto.RecoveryIDOffset] << 7
+	return
+}
+
+// ExpandCompactSignature converts a 64 bytes EIP-2098 signature to the 65 bytes form with V 0 or 1
+func ExpandCompactSignature(compact []byte) (signature []byte, err error) {
+	if len(compact) != 64 {
+		err = fmt.Errorf("%w: compact signature length is %d bytes", ErrInvalidSignature, len(compact))
+		return
+	}
+	signature = make([]byte, crypto.SignatureLength)
+	copy(signature, compact)
+	signature[crypto.RecoveryIDOffset] = signature[32] >> 7
+	signature[32] &= 0x7f
+	return
+}
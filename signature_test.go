@@ -0,0 +1,105 @@
+package goethereumhelper
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// eip2098Vectors are the test cases of EIP-2098, personal_sign signatures made with the key 0x1234...1234
+var eip2098Vectors = []struct {
+	message   string
+	signature string
+	compact   string
+}{
+	{
+		"Hello World",
+		"0x68a020a209d3d56c46f38cc50a33f704f4a9a10a59377f8dd762ac66910e9b907e865ad05c4035ab5792787d4a0297a43617ae897930a6fe4d822b8faea520641b",
+		"0x68a020a209d3d56c46f38cc50a33f704f4a9a10a59377f8dd762ac66910e9b907e865ad05c4035ab5792787d4a0297a43617ae897930a6fe4d822b8faea52064",
+	},
+	{
+		"It's a small(er) world",
+		"0x9328da16089fcba9bececa81663203989f2df5fe1faa6291a45381c81bd17f76139c6d6b623b42da56557e5e734a43dc83345ddfadec52cbe24d0cc64f5507931c",
+		"0x9328da16089fcba9bececa81663203989f2df5fe1faa6291a45381c81bd17f76939c6d6b623b42da56557e5e734a43dc83345ddfadec52cbe24d0cc64f550793",
+	},
+}
+
+func TestPersonalSignatureVectors(t *testing.T) {
+	key, err := crypto.HexToECDSA("1234567890123456789012345678901234567890123456789012345678901234")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := crypto.PubkeyToAddress(key.PublicKey)
+	for _, vector := range eip2098Vectors {
+		signature, err := SignPersonalMessage(key, []byte(vector.message))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hexutil.Encode(signature) != vector.signature {
+			t.Fatalf("%q: signature is %s, expected %s", vector.message, hexutil.Encode(signature), vector.signature)
+		}
+		compact, err := CompactSignature(signature)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hexutil.Encode(compact) != vector.compact {
+			t.Fatalf("%q: compact signature is %s, expected %s", vector.message, hexutil.Encode(compact), vector.compact)
+		}
+		normalized, err := NormalizeSignature(signature)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, form := range [][]byte{signature, compact, normalized} {
+			valid, err := VerifyPersonalMessage([]byte(vector.message), form, address)
+			if err != nil || !valid {
+				t.Fatalf("%q: signature %x not verified: %v", vector.message, form, err)
+			}
+		}
+	}
+}
+
+func TestToLowS(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := crypto.PubkeyToAddress(key.PublicKey)
+	message := []byte("malleable")
+	signature, err := SignPersonalMessage(key, message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	normalized, err := NormalizeSignature(signature)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// For both V conventions, build the high S twin of the signature and convert it back
+	for _, low := range [][]byte{signature, normalized} {
+		high := common.CopyBytes(low)
+		s := new(big.Int).SetBytes(low[32:64])
+		new(big.Int).Sub(secp256k1N, s).FillBytes(high[32:64])
+		if low[64] >= 27 {
+			high[64] = 27 + ((low[64] - 27) ^ 1)
+		} else {
+			high[64] ^= 1
+		}
+		if _, err := RecoverPersonalSigner(message, high); !errors.Is(err, ErrMalleableSignature) {
+			t.Fatalf("V %d: expected ErrMalleableSignature, got %v", low[64], err)
+		}
+		converted, err := ToLowS(high)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hexutil.Encode(converted) != hexutil.Encode(low) {
+			t.Fatalf("V %d: converted to %x, expected %x", low[64], converted, low)
+		}
+		signer, err := RecoverPersonalSigner(message, converted)
+		if err != nil || signer != address {
+			t.Fatalf("V %d: recovered %s, %v", low[64], signer.Hex(), err)
+		}
+	}
+}
@@ -0,0 +1,287 @@
+/*
+Package siwe builds, parses and verifies Sign-In with Ethereum (EIP-4361) messages. Signatures of externally
+owned accounts are recovered locally and the ones of smart contract wallets are checked with their EIP-1271
+isValidSignature function.
+
+Example, on the server:
+
+	nonce, err := siwe.GenerateNonce() // Stored in the session and sent to the browser
+	...
+	message, err := siwe.Verify(ctx, client, text, signature, siwe.VerifyOptions{Domain: "example.com", Nonce: nonce, ChainID: 1})
+	if err != nil {
+		// reject the sign in
+	}
+	// message.Address is authenticated
+*/
+package siwe
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var (
+	// ErrInvalidMessage is returned for text that is not an EIP-4361 message
+	ErrInvalidMessage = errors.New("invalid sign-in with ethereum message")
+	// ErrDomainMismatch is returned when the message was made for another domain
+	ErrDomainMismatch = errors.New("message domain does not match")
+	// ErrNonceMismatch is returned when the message nonce is not the one given by the server
+	ErrNonceMismatch = errors.New("message nonce does not match")
+	// ErrChainIDMismatch is returned when the message was made for another chain
+	ErrChainIDMismatch = errors.New("message chain ID does not match")
+	// ErrExpired is returned when the expiration time of the message passed or it was issued too long ago
+	ErrExpired = errors.New("message expired")
+	// ErrNotYetValid is returned when the message was issued in the future or its not before time is not reached
+	ErrNotYetValid = errors.New("message is not valid yet")
+	// ErrInvalidSignature is returned when the signature was not made by the message address
+	ErrInvalidSignature = errors.New("signature does not match message address")
+)
+
+const (
+	header           = " wants you to sign in with your Ethereum account:"
+	uriTag           = "URI: "
+	versionTag       = "Version: "
+	chainIDTag       = "Chain ID: "
+	nonceTag         = "Nonce: "
+	issuedAtTag      = "Issued At: "
+	expirationTag    = "Expiration Time: "
+	notBeforeTag     = "Not Before: "
+	requestIDTag     = "Request ID: "
+	resourcesTag     = "Resources:"
+	resourcePrefix   = "- "
+	nonceAlphabet    = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	generatedNonce   = 17
+	minimumNonceSize = 8
+)
+
+// Message is an EIP-4361 message
+type Message struct {
+	Scheme         string // Optional, like https
+	Domain         string // Host, and port when not the default one, of the site asking the sign in
+	Address        common.Address
+	Statement      string // Optional, a single line shown to the user
+	URI            string // Resource the sign in is for
+	Version        string // Always 1, set by String when empty
+	ChainID        uint64
+	Nonce          string // At least 8 alphanumeric characters, see GenerateNonce
+	IssuedAt       time.Time
+	ExpirationTime *time.Time // Optional
+	NotBefore      *time.Time // Optional
+	RequestID      string     // Optional
+	Resources      []string   // Optional URIs
+}
+
+// GenerateNonce returns a random nonce for a message, to be stored by the server until the sign in
+func GenerateNonce() (nonce string, err error) {
+	alphabetSize := big.NewInt(int64(len(nonceAlphabet)))
+	var b strings.Builder
+	for i := 0; i < generatedNonce; i++ {
+		index, errRand := rand.Int(rand.Reader, alphabetSize)
+		if errRand != nil {
+			return "", fmt.Errorf("could not generate nonce: %w", errRand)
+		}
+		b.WriteByte(nonceAlphabet[index.Int64()])
+	}
+	return b.String(), nil
+}
+
+// String returns the text of the message, the one signed by the wallet
+func (m *Message) String() string {
+	var b strings.Builder
+	if m.Scheme != "" {
+		b.WriteString(m.Scheme + "://")
+	}
+	b.WriteString(m.Domain + header + "\n")
+	b.WriteString(m.Address.Hex() + "\n\n")
+	if m.Statement != "" {
+		b.WriteString(m.Statement + "\n")
+	}
+	b.WriteString("\n")
+	version := m.Version
+	if version == "" {
+		version = "1"
+	}
+	b.WriteString(uriTag + m.URI + "\n")
+	b.WriteString(versionTag + version + "\n")
+	b.WriteString(chainIDTag + strconv.FormatUint(m.ChainID, 10) + "\n")
+	b.WriteString(nonceTag + m.Nonce + "\n")
+	b.WriteString(issuedAtTag + m.IssuedAt.Format(time.RFC3339Nano))
+	if m.ExpirationTime != nil {
+		b.WriteString("\n" + expirationTag + m.ExpirationTime.Format(time.RFC3339Nano))
+	}
+	if m.NotBefore != nil {
+		b.WriteString("\n" + notBeforeTag + m.NotBefore.Format(time.RFC3339Nano))
+	}
+	if m.RequestID != "" {
+		b.WriteString("\n" + requestIDTag + m.RequestID)
+	}
+	if len(m.Resources) > 0 {
+		b.WriteString("\n" + resourcesTag)
+		for _, resource := range m.Resources {
+			b.WriteString("\n" + resourcePrefix + resource)
+		}
+	}
+	return b.String()
+}
+
+// Validate checks the fields of the message follow EIP-4361
+func (m *Message) Validate() (err error) {
+	switch {
+	case m.Domain == "" || strings.ContainsAny(m.Domain, "/ \n"):
+		return fmt.Errorf("%w: invalid domain %q", ErrInvalidMessage, m.Domain)
+	case strings.Contains(m.Statement, "\n"):
+		return fmt.Errorf("%w: statement has more than one line", ErrInvalidMessage)
+	case m.Version != "" && m.Version != "1":
+		return fmt.Errorf("%w: unsupported version %q", ErrInvalidMessage, m.Version)
+	case m.IssuedAt.IsZero():
+		return fmt.Errorf("%w: issued at is missing", ErrInvalidMessage)
+	}
+	if _, errURI := url.Parse(m.URI); m.URI == "" || errURI != nil {
+		return fmt.Errorf("%w: invalid URI %q", ErrInvalidMessage, m.URI)
+	}
+	if len(m.Nonce) < minimumNonceSize || strings.Trim(m.Nonce, nonceAlphabet) != "" {
+		return fmt.Errorf("%w: nonce must have at least %d alphanumeric characters", ErrInvalidMessage, minimumNonceSize)
+	}
+	return
+}
+
+// ParseMessage parses the text of an EIP-4361 message. The address must have the EIP-55 checksum.
+func ParseMessage(text string) (message *Message, err error) {
+	lines := strings.Split(text, "\n")
+	p := &parser{lines: lines}
+	message = new(Message)
+
+	origin, found := strings.CutSuffix(p.next(), header)
+	if !found {
+		return nil, fmt.Errorf("%w: missing header", ErrInvalidMessage)
+	}
+	if scheme, domain, hasScheme := strings.Cut(origin, "://"); hasScheme {
+		message.Scheme, message.Domain = scheme, domain
+	} else {
+		message.Domain = origin
+	}
+	address := p.next()
+	if !common.IsHexAddress(address) || common.HexToAddress(address).Hex() != address {
+		return nil, fmt.Errorf("%w: address %q is not checksummed", ErrInvalidMessage, address)
+	}
+	message.Address = common.HexToAddress(address)
+	if p.next() != "" {
+		return nil, fmt.Errorf("%w: missing empty line after address", ErrInvalidMessage)
+	}
+	// The statement and the empty line following it are optional. Some wallets also drop the empty line
+	// when there is no statement.
+	if !strings.HasPrefix(p.peek(), uriTag) {
+		if statement := p.next(); statement != "" {
+			message.Statement = statement
+			if p.next() != "" {
+				return nil, fmt.Errorf("%w: missing empty line after statement", ErrInvalidMessage)
+			}
+		}
+	}
+
+	message.URI, err = p.field(uriTag)
+	if err != nil {
+		return nil, err
+	}
+	message.Version, err = p.field(versionTag)
+	if err != nil {
+		return nil, err
+	}
+	chainID, err := p.field(chainIDTag)
+	if err != nil {
+		return nil, err
+	}
+	message.ChainID, err = strconv.ParseUint(chainID, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid chain ID %q", ErrInvalidMessage, chainID)
+	}
+	message.Nonce, err = p.field(nonceTag)
+	if err != nil {
+		return nil, err
+	}
+	message.IssuedAt, err = p.timeField(issuedAtTag)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(p.peek(), expirationTag) {
+		expiration, errTime := p.timeField(expirationTag)
+		if errTime != nil {
+			return nil, errTime
+		}
+		message.ExpirationTime = &expiration
+	}
+	if strings.HasPrefix(p.peek(), notBeforeTag) {
+		notBefore, errTime := p.timeField(notBeforeTag)
+		if errTime != nil {
+			return nil, errTime
+		}
+		message.NotBefore = &notBefore
+	}
+	if strings.HasPrefix(p.peek(), requestIDTag) {
+		message.RequestID, _ = p.field(requestIDTag)
+	}
+	if p.peek() == resourcesTag {
+		p.next()
+		for strings.HasPrefix(p.peek(), resourcePrefix) {
+			message.Resources = append(message.Resources, strings.TrimPrefix(p.next(), resourcePrefix))
+		}
+	}
+	if p.index < len(lines) {
+		return nil, fmt.Errorf("%w: unexpected line %q", ErrInvalidMessage, p.peek())
+	}
+	err = message.Validate()
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+// parser reads the lines of a message in order
+type parser struct {
+	lines []string
+	index int
+}
+
+func (p *parser) peek() string {
+	if p.index >= len(p.lines) {
+		return ""
+	}
+	return p.lines[p.index]
+}
+
+func (p *parser) next() (line string) {
+	line = p.peek()
+	p.index++
+	return
+}
+
+// field reads the value of the line starting with tag
+func (p *parser) field(tag string) (value string, err error) {
+	value, found := strings.CutPrefix(p.peek(), tag)
+	if !found || p.index >= len(p.lines) {
+		return "", fmt.Errorf("%w: missing %q", ErrInvalidMessage, strings.TrimSuffix(tag, ": "))
+	}
+	p.index++
+	return
+}
+
+// timeField reads the RFC 3339 time of the line starting with tag
+func (p *parser) timeField(tag string) (value time.Time, err error) {
+	text, err := p.field(tag)
+	if err != nil {
+		return
+	}
+	value, err = time.Parse(time.RFC3339Nano, text)
+	if err != nil {
+		err = fmt.Errorf("%w: invalid %q time %q", ErrInvalidMessage, strings.TrimSuffix(tag, ": "), text)
+	}
+	return
+}
@@ -0,0 +1,141 @@
+package siwe
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestParseMessageRoundTrip(t *testing.T) {
+	issuedAt := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	expiration := issuedAt.Add(time.Hour)
+	notBefore := issuedAt.Add(time.Minute)
+	tests := []struct {
+		name    string
+		message Message
+	}{
+		{
+			name: "required fields",
+			message: Message{
+				Domain:   "example.com",
+				Address:  common.HexToAddress("0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2"),
+				URI:      "https://example.com/login",
+				Version:  "1",
+				ChainID:  1,
+				Nonce:    "32891756",
+				IssuedAt: issuedAt,
+			},
+		},
+		{
+			name: "every field",
+			message: Message{
+				Scheme:         "https",
+				Domain:         "example.com:8443",
+				Address:        common.HexToAddress("0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2"),
+				Statement:      "I accept the ExampleOrg Terms of Service: https://example.com/tos",
+				URI:            "https://example.com:8443/login",
+				Version:        "1",
+				ChainID:        137,
+				Nonce:          "abcdEFGH1234",
+				IssuedAt:       issuedAt,
+				ExpirationTime: &expiration,
+				NotBefore:      &notBefore,
+				RequestID:      "request-42",
+				Resources:      []string{"ipfs://bafybeiemxf5abjwjbikoz4mc3a3dla6ual3jsgpdr4cjr3oz3evfyavhwq/", "https://example.com/my-web2-claim.json"},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			text := test.message.String()
+			parsed, err := ParseMessage(text)
+			if err != nil {
+				t.Fatalf("%v\n%s", err, text)
+			}
+			if !reflect.DeepEqual(*parsed, test.message) {
+				t.Fatalf("parsed %+v, expected %+v", *parsed, test.message)
+			}
+			if parsed.String() != text {
+				t.Fatalf("text changed by the round trip:\n%s", parsed.String())
+			}
+		})
+	}
+}
+
+func TestParseMessageLayout(t *testing.T) {
+	const address = "0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2"
+	const fields = "URI: https://example.com\nVersion: 1\nChain ID: 1\nNonce: 32891756\nIssued At: 2023-05-01T12:00:00Z"
+	tests := []struct {
+		name      string
+		text      string
+		statement string
+	}{
+		// The EIP-4361 layout without statement, as written by String
+		{name: "two blank lines", text: "example.com wants you to sign in with your Ethereum account:\n" + address + "\n\n\n" + fields},
+		// Some wallets drop the blank line of the missing statement
+		{name: "one blank line", text: "example.com wants you to sign in with your Ethereum account:\n" + address + "\n\n" + fields},
+		{name: "statement", text: "example.com wants you to sign in with your Ethereum account:\n" + address + "\n\nSign in\n\n" + fields, statement: "Sign in"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			message, err := ParseMessage(test.text)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if message.Statement != test.statement || message.URI != "https://example.com" || message.Nonce != "32891756" {
+				t.Fatalf("parsed %+v", message)
+			}
+		})
+	}
+}
+
+func TestParseMessageRejects(t *testing.T) {
+	valid := (&Message{
+		Domain:    "example.com",
+		Address:   common.HexToAddress("0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2"),
+		Statement: "Sign in",
+		URI:       "https://example.com",
+		ChainID:   1,
+		Nonce:     "32891756",
+		IssuedAt:  time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC),
+		RequestID: "request-42",
+		Resources: []string{"https://example.com/a"},
+	}).String()
+	tests := []struct {
+		name    string
+		replace string
+		by      string
+	}{
+		{"missing header", " wants you to sign in with your Ethereum account:", ""},
+		{"lowercase address", "0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2", "0xc02aaa39b223fe8d0a0e5c4f27ead9083c756cc2"},
+		{"wrong checksum", "0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2", "0xc02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2"},
+		{"invalid address", "0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2", "0xC02aaA39"},
+		{"no blank line after statement", "Sign in\n\n", "Sign in\n"},
+		{"missing URI", "URI: https://example.com\n", ""},
+		{"unsupported version", "Version: 1", "Version: 2"},
+		{"invalid chain ID", "Chain ID: 1", "Chain ID: one"},
+		{"short nonce", "Nonce: 32891756", "Nonce: 1234"},
+		{"nonce not alphanumeric", "Nonce: 32891756", "Nonce: 3289-1756"},
+		{"invalid issued at", "2023-05-01T12:00:00Z", "May 1st 2023"},
+		{"fields out of order", "Version: 1\nChain ID: 1", "Chain ID: 1\nVersion: 1"},
+		{"unexpected line", "- https://example.com/a", "- https://example.com/a\nextra"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			text := strings.Replace(valid, test.replace, test.by, 1)
+			if text == valid {
+				t.Fatalf("%q not found in the message", test.replace)
+			}
+			if _, err := ParseMessage(text); !errors.Is(err, ErrInvalidMessage) {
+				t.Fatalf("expected ErrInvalidMessage, got %v", err)
+			}
+		})
+	}
+	if _, err := ParseMessage(valid); err != nil {
+		t.Fatal(err)
+	}
+}
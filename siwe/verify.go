@@ -0,0 +1,149 @@
+package siwe
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/jeffprestes/goethereumhelper"
+)
+
+// eip1271ABI is the isValidSignature function of EIP-1271 smart contract wallets
+const eip1271ABI = `[{"inputs":[{"name":"hash","type":"bytes32"},{"name":"signature","type":"bytes"}],"name":"isValidSignature","outputs":[{"name":"magicValue","type":"bytes4"}],"stateMutability":"view","type":"function"}]`
+
+// eip1271MagicValue is returned by isValidSignature for valid signatures
+var eip1271MagicValue = [4]byte{0x16, 0x26, 0xba, 0x7e}
+
+// VerifyOptions are the values the message must match. Zero value fields are not checked by Check, while
+// Verify requires Domain and Nonce.
+type VerifyOptions struct {
+	Domain    string        // Host of the site, and port when not the default one
+	Scheme    string        // Checked when the message has a scheme
+	Nonce     string        // Nonce given to the client for this sign in
+	ChainID   uint64        // Chain the client must be connected to
+	Time      time.Time     // Time the message must be valid at, now when zero
+	MaxAge    time.Duration // Rejects messages issued longer ago than this
+	ClockSkew time.Duration // Tolerated difference between the clocks of the client and the server
+}
+
+// Verify parses text, checks it against options and that signature was made by the message address, and
+// returns the message. options.Domain and options.Nonce are required, as a message for another site or
+// sign in would be accepted without them. The client is used to check the signatures of smart contract
+// wallets with EIP-1271 and may be nil to accept only externally owned accounts.
+func Verify(ctx context.Context, client bind.ContractCaller, text string, signature []byte, options VerifyOptions) (message *Message, err error) {
+	if options.Domain == "" {
+		return nil, errors.New("verify options need the domain of the site")
+	}
+	if options.Nonce == "" {
+		return nil, errors.New("verify options need the nonce given to the client")
+	}
+	message, err = ParseMessage(text)
+	if err != nil {
+		return
+	}
+	err = message.Check(options)
+	if err != nil {
+		return nil, err
+	}
+	err = VerifySignature(ctx, client, text, signature, message.Address)
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+// Check tells if the message matches options and is valid at options.Time
+func (m *Message) Check(options VerifyOptions) (err error) {
+	if options.Domain != "" && !strings.EqualFold(m.Domain, options.Domain) {
+		return fmt.Errorf("%w: message is for %s", ErrDomainMismatch, m.Domain)
+	}
+	if options.Scheme != "" && m.Scheme != "" && m.Scheme != options.Scheme {
+		return fmt.Errorf("%w: message is for scheme %s", ErrDomainMismatch, m.Scheme)
+	}
+	if options.Nonce != "" && m.Nonce != options.Nonce {
+		return ErrNonceMismatch
+	}
+	if options.ChainID != 0 && m.ChainID != options.ChainID {
+		return fmt.Errorf("%w: message is for chain %d", ErrChainIDMismatch, m.ChainID)
+	}
+	now := options.Time
+	if now.IsZero() {
+		now = time.Now()
+	}
+	if m.IssuedAt.After(now.Add(options.ClockSkew)) {
+		return fmt.Errorf("%w: issued at %s", ErrNotYetValid, m.IssuedAt.Format(time.RFC3339))
+	}
+	if m.NotBefore != nil && m.NotBefore.After(now.Add(options.ClockSkew)) {
+		return fmt.Errorf("%w: not before %s", ErrNotYetValid, m.NotBefore.Format(time.RFC3339))
+	}
+	if m.ExpirationTime != nil && !m.ExpirationTime.After(now.Add(-options.ClockSkew)) {
+		return fmt.Errorf("%w: expired at %s", ErrExpired, m.ExpirationTime.Format(time.RFC3339))
+	}
+	if options.MaxAge > 0 && m.IssuedAt.Add(options.MaxAge).Before(now.Add(-options.ClockSkew)) {
+		return fmt.Errorf("%w: issued at %s", ErrExpired, m.IssuedAt.Format(time.RFC3339))
+	}
+	return
+}
+
+// VerifySignature checks that signature of text, made with personal_sign, was made by address. When the
+// signature is not from the key of address and client is not nil, address is checked to be an EIP-1271
+// smart contract wallet accepting it.
+func VerifySignature(ctx context.Context, client bind.ContractCaller, text string, signature []byte, address common.Address) (err error) {
+	hash := common.BytesToHash(accounts.TextHash([]byte(text)))
+	signer, errRecover := goethereumhelper.RecoverSigner(hash, signature)
+	if errRecover == nil && signer == address {
+		return
+	}
+	if client == nil {
+		if errRecover != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidSignature, errRecover)
+		}
+		return ErrInvalidSignature
+	}
+	valid, err := IsValidSignature(ctx, client, address, hash, signature)
+	if err != nil {
+		return
+	}
+	if !valid {
+		return ErrInvalidSignature
+	}
+	return
+}
+
+// IsValidSignature calls the EIP-1271 isValidSignature function of the contract at address, telling if it
+// accepts signature of hash. It returns false for addresses without code.
+func IsValidSignature(ctx context.Context, client bind.ContractCaller, address common.Address, hash common.Hash, signature []byte) (valid bool, err error) {
+	code, err := client.CodeAt(ctx, address, nil)
+	if err != nil {
+		return false, fmt.Errorf("could not get code of %s: %w", address.Hex(), err)
+	}
+	if len(code) == 0 {
+		return
+	}
+	parsedABI, err := abi.JSON(strings.NewReader(eip1271ABI))
+	if err != nil {
+		return
+	}
+	data, err := parsedABI.Pack("isValidSignature", hash, signature)
+	if err != nil {
+		return
+	}
+	output, err := client.CallContract(ctx, ethereum.CallMsg{To: &address, Data: data}, nil)
+	if err != nil {
+		// Wallets may revert on invalid signatures instead of returning another value
+		var dataErr interface{ ErrorData() interface{} }
+		if errors.As(err, &dataErr) || strings.Contains(err.Error(), "execution reverted") {
+			return false, nil
+		}
+		return false, fmt.Errorf("could not call isValidSignature of %s: %w", address.Hex(), err)
+	}
+	return len(output) == 32 && bytes.Equal(output[:4], eip1271MagicValue[:]), nil
+}
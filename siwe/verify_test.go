@@ -0,0 +1,170 @@
+package siwe
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/jeffprestes/goethereumhelper"
+)
+
+func TestVerify(t *testing.T) {
+	chain, err := goethereumhelper.NewTestChain()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Close()
+	nonce, err := GenerateNonce()
+	if err != nil {
+		t.Fatal(err)
+	}
+	message := &Message{
+		Domain:   "localhost:8080",
+		Address:  chain.Account(0),
+		URI:      "http://localhost:8080",
+		ChainID:  1337,
+		Nonce:    nonce,
+		IssuedAt: time.Now().Add(-time.Second),
+	}
+	text := message.String()
+	signature, err := goethereumhelper.SignPersonalMessage(chain.Keys[0], []byte(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	verified, err := Verify(ctx, nil, text, signature, VerifyOptions{Domain: "localhost:8080", Nonce: nonce, ChainID: 1337})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if verified.Address != chain.Account(0) {
+		t.Fatalf("verified address is %s", verified.Address.Hex())
+	}
+	if _, err := Verify(ctx, nil, text, signature, VerifyOptions{Nonce: nonce}); err == nil {
+		t.Fatal("expected an error without domain")
+	}
+	if _, err := Verify(ctx, nil, text, signature, VerifyOptions{Domain: "localhost:8080"}); err == nil {
+		t.Fatal("expected an error without nonce")
+	}
+	if _, err := Verify(ctx, nil, text, signature, VerifyOptions{Domain: "localhost:8080", Nonce: "abcdefghij"}); !errors.Is(err, ErrNonceMismatch) {
+		t.Fatalf("expected ErrNonceMismatch, got %v", err)
+	}
+	if _, err := Verify(ctx, nil, text, signature, VerifyOptions{Domain: "evil.com", Nonce: nonce}); !errors.Is(err, ErrDomainMismatch) {
+		t.Fatalf("expected ErrDomainMismatch, got %v", err)
+	}
+
+	// Check keeps skipping the options left empty
+	if err := verified.Check(VerifyOptions{}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// eip1271Wallet returns the runtime code of a minimal EIP-1271 wallet, accepting the signatures of owner:
+// it ecrecovers the 65 bytes signature of the hash and returns the magic value when owner signed it
+func eip1271Wallet(owner common.Address) []byte {
+	return common.FromHex("60043560005260a43560f81c602052606435604052608435606052602060806080600060015afa5060805173" +
+		owner.Hex()[2:] + "1460545763ffffffff60e01b60005260206000f35b631626ba7e60e01b60005260206000f3")
+}
+
+func TestVerifyEIP1271(t *testing.T) {
+	chain, err := goethereumhelper.NewTestChain()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Close()
+	ctx := context.Background()
+	wallet, reverting := common.HexToAddress("0x1271"), common.HexToAddress("0xbad")
+	if err := chain.SetCode(wallet, eip1271Wallet(chain.Account(1))); err != nil {
+		t.Fatal(err)
+	}
+	if err := chain.SetCode(reverting, common.FromHex("60006000fd")); err != nil {
+		t.Fatal(err)
+	}
+	message := func(address common.Address) string {
+		return (&Message{
+			Domain:   "example.com",
+			Address:  address,
+			URI:      "https://example.com",
+			ChainID:  1337,
+			Nonce:    "abcdefghij",
+			IssuedAt: time.Now().Add(-time.Second),
+		}).String()
+	}
+	options := VerifyOptions{Domain: "example.com", Nonce: "abcdefghij"}
+	sign := func(key int, text string) []byte {
+		signature, err := goethereumhelper.SignPersonalMessage(chain.Keys[key], []byte(text))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signature
+	}
+
+	text := message(wallet)
+	verified, err := Verify(ctx, chain, text, sign(1, text), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if verified.Address != wallet {
+		t.Fatalf("verified address is %s", verified.Address.Hex())
+	}
+	// Without client only externally owned accounts are accepted
+	if _, err := Verify(ctx, nil, text, sign(1, text), options); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("expected ErrInvalidSignature without client, got %v", err)
+	}
+	if _, err := Verify(ctx, chain, text, sign(2, text), options); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("expected ErrInvalidSignature for a signature of another owner, got %v", err)
+	}
+
+	// Wallets reverting on invalid signatures and accounts without code reject them
+	text = message(reverting)
+	if _, err := Verify(ctx, chain, text, sign(1, text), options); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("expected ErrInvalidSignature from a reverting wallet, got %v", err)
+	}
+	hash := common.BytesToHash(accounts.TextHash([]byte(text)))
+	valid, err := IsValidSignature(ctx, chain, chain.Account(3), hash, sign(3, text))
+	if err != nil || valid {
+		t.Fatalf("account without code accepted the signature: %v", err)
+	}
+}
+
+func TestCheckTime(t *testing.T) {
+	issuedAt := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	expiration := issuedAt.Add(time.Hour)
+	notBefore := issuedAt.Add(10 * time.Minute)
+	message := &Message{IssuedAt: issuedAt, ExpirationTime: &expiration, NotBefore: &notBefore}
+	tests := []struct {
+		name    string
+		options VerifyOptions
+		err     error
+	}{
+		{name: "valid", options: VerifyOptions{Time: issuedAt.Add(30 * time.Minute)}},
+		{name: "issued in the future", options: VerifyOptions{Time: issuedAt.Add(-time.Minute)}, err: ErrNotYetValid},
+		{name: "before not before", options: VerifyOptions{Time: notBefore.Add(-time.Second)}, err: ErrNotYetValid},
+		{name: "not before within clock skew", options: VerifyOptions{Time: notBefore.Add(-time.Second), ClockSkew: time.Minute}},
+		{name: "at not before", options: VerifyOptions{Time: notBefore}},
+		{name: "at expiration", options: VerifyOptions{Time: expiration}, err: ErrExpired},
+		{name: "expired", options: VerifyOptions{Time: expiration.Add(time.Minute)}, err: ErrExpired},
+		{name: "expired within clock skew", options: VerifyOptions{Time: expiration.Add(time.Second), ClockSkew: time.Minute}},
+		{name: "max age", options: VerifyOptions{Time: issuedAt.Add(30 * time.Minute), MaxAge: 20 * time.Minute}, err: ErrExpired},
+		{name: "within max age", options: VerifyOptions{Time: issuedAt.Add(15 * time.Minute), MaxAge: 20 * time.Minute}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := message.Check(test.options); !errors.Is(err, test.err) {
+				t.Fatalf("expected %v, got %v", test.err, err)
+			}
+		})
+	}
+
+	// Messages without expiration are only limited by MaxAge
+	message = &Message{IssuedAt: time.Now().Add(-time.Hour)}
+	if err := message.Check(VerifyOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := message.Check(VerifyOptions{MaxAge: time.Minute}); !errors.Is(err, ErrExpired) {
+		t.Fatalf("expected ErrExpired, got %v", err)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	ethereum "github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
 )
 
 // SubLogs Subscribe to watch to notifications to a specific Ethereum address
@@ -39,3 +40,45 @@ func SubLogs(addressToWatch common.Address, wg *sync.WaitGroup) {
 		}
 	}
 }
+
+// WatchDecodedLogs subscribes to the logs matching query and delivers them to events decoded by decoder.
+// Logs that do not match any ABI registered in the decoder are delivered raw, with Decoded set to false.
+// The watcher stops when ctx is cancelled or the returned subscription is unsubscribed; network errors are
+// reported through the subscription Err channel.
+func WatchDecodedLogs(ctx context.Context, client ethereum.LogFilterer, query ethereum.FilterQuery, decoder *EventDecoder, events chan<- DecodedEvent) (sub ethereum.Subscription, err error) {
+	logs := make(chan types.Log)
+	logSub, err := client.SubscribeFilterLogs(ctx, query, logs)
+	if err != nil {
+		log.Printf("[WatchDecodedLogs] Failure subscribing to logs: %+v", err)
+		return
+	}
+	sub = event.NewSubscription(func(quit <-chan struct{}) error {
+		defer logSub.Unsubscribe()
+		for {
+			select {
+			case infoLog := <-logs:
+				decoded, err := decoder.Decode(infoLog)
+				if err != nil {
+					// A log the decoder cannot handle must not stop the watcher, deliver it raw
+					decoded = DecodedEvent{Address: infoLog.Address, Raw: infoLog}
+				}
+				select {
+				case events <- decoded:
+				case err := <-logSub.Err():
+					return err
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-quit:
+					return nil
+				}
+			case err := <-logSub.Err():
+				return err
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-quit:
+				return nil
+			}
+		}
+	})
+	return
+}
@@ -0,0 +1,277 @@
+package goethereumhelper
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Defaults used by NewTestChain
+var (
+	DefaultTestChainAccounts              = 10
+	DefaultTestChainAccountBalance        = new(big.Int).Mul(big.NewInt(1000), big.NewInt(params.Ether))
+	DefaultTestChainGasLimit       uint64 = 150000000
+)
+
+// TestChain is an in-memory blockchain for contract tests. It embeds the simulated backend, so it can be
+// used wherever a bind.ContractBackend is expected, and holds funded accounts ready to send transactions.
+type TestChain struct {
+	*backends.SimulatedBackend
+
+	Accounts []*bind.TransactOpts // Transactors of the funded accounts, in the same order as Keys
+	Keys     []*ecdsa.PrivateKey  // Private keys of the funded accounts
+
+	config *params.ChainConfig
+
+	mu           sync.Mutex // Serializes the operations made of several backend calls
+	snapshots    []chainSnapshot
+	lastSnapshot int
+
+	impersonated    map[common.Address]bool
+	impersonatedTxs map[common.Hash]common.Address // Sender of the transactions made by Impersonate transactors
+
+	touched      stateKeys            // Accounts and slots found in the state, for DumpState
+	tracedBlocks map[common.Hash]bool // Blocks already replayed to find touched accounts and slots
+}
+
+// testChainSettings are the settings changed by the TestChainOption functions
+type testChainSettings struct {
+	accounts int
+	balance  *big.Int
+	seed     []byte
+	gasLimit uint64
+	config   *params.ChainConfig
+	alloc    core.GenesisAlloc
+}
+
+// TestChainOption customizes the chain built by NewTestChain
+type TestChainOption func(settings *testChainSettings) error
+
+// WithAccounts creates n accounts funded with balance wei at genesis
+func WithAccounts(n int, balance *big.Int) TestChainOption {
+	return func(settings *testChainSettings) error {
+		if n < 0 {
+			return fmt.Errorf("invalid number of accounts: %d", n)
+		}
+		if balance == nil || balance.Sign() < 0 {
+			return fmt.Errorf("invalid account balance: %v", balance)
+		}
+		settings.accounts = n
+		settings.balance = new(big.Int).Set(balance)
+		return nil
+	}
+}
+
+// WithDeterministicKeys derives the account keys from seed, so every run uses the same addresses
+func WithDeterministicKeys(seed string) TestChainOption {
+	return func(settings *testChainSettings) error {
+		settings.seed = []byte(seed)
+		return nil
+	}
+}
+
+// WithGasLimit sets the block gas limit
+func WithGasLimit(gasLimit uint64) TestChainOption {
+	return func(settings *testChainSettings) error {
+		if gasLimit < params.MinGasLimit {
+			return fmt.Errorf("gas limit %d is below the protocol minimum %d", gasLimit, params.MinGasLimit)
+		}
+		settings.gasLimit = gasLimit
+		return nil
+	}
+}
+
+// WithChainConfig checks the chain runs with the rules of config. It does not change them: the go-ethereum
+// simulated backend always runs params.AllEthashProtocolChanges (chain ID 1337 and every fork up to Gray
+// Glacier active at genesis). NewTestChain fails when config differs from it in the chain ID, a fork block,
+// the DAO fork, the merge or Shanghai and later forks. The consensus engine and the difficulty bomb delays
+// are ignored, as they do not change how transactions execute.
+func WithChainConfig(config *params.ChainConfig) TestChainOption {
+	return func(settings *testChainSettings) error {
+		if config == nil {
+			return fmt.Errorf("chain config is nil")
+		}
+		settings.config = config
+		return nil
+	}
+}
+
+// WithGenesisAlloc adds accounts (balances, code, storage and nonces) to the genesis block.
+// The accounts created by WithAccounts are added on top of alloc.
+func WithGenesisAlloc(alloc core.GenesisAlloc) TestChainOption {
+	return func(settings *testChainSettings) error {
+		for address, account := range alloc {
+			settings.alloc[address] = account
+		}
+		return nil
+	}
+}
+
+// NewTestChain builds an in-memory blockchain. Without options it creates DefaultTestChainAccounts random
+// accounts funded with DefaultTestChainAccountBalance and uses DefaultTestChainGasLimit as block gas limit.
+func NewTestChain(options ...TestChainOption) (chain *TestChain, err error) {
+	settings := &testChainSettings{
+		accounts: DefaultTestChainAccounts,
+		balance:  DefaultTestChainAccountBalance,
+		gasLimit: DefaultTestChainGasLimit,
+		alloc:    make(core.GenesisAlloc),
+	}
+	for _, option := range options {
+		err = option(settings)
+		if err != nil {
+			return
+		}
+	}
+
+	config := copyChainConfig(params.AllEthashProtocolChanges)
+	if settings.config != nil {
+		err = checkSimulatedConfig(settings.config)
+		if err != nil {
+			return
+		}
+	}
+
+	chain = &TestChain{
+		config:          config,
+		impersonated:    make(map[common.Address]bool),
+		impersonatedTxs: make(map[common.Hash]common.Address),
+		touched:         make(stateKeys),
+		tracedBlocks:    make(map[common.Hash]bool),
+	}
+	for i := 0; i < settings.accounts; i++ {
+		var key *ecdsa.PrivateKey
+		if settings.seed != nil {
+			key, err = deterministicKey(settings.seed, i)
+		} else {
+			key, err = crypto.GenerateKey()
+		}
+		if err != nil {
+			chain = nil
+			return
+		}
+		account, errTransactor := bind.NewKeyedTransactorWithChainID(key, config.ChainID)
+		if errTransactor != nil {
+			chain, err = nil, errTransactor
+			return
+		}
+		account.Context = context.Background()
+		settings.alloc[account.From] = core.GenesisAccount{Balance: new(big.Int).Set(settings.balance)}
+		chain.Keys = append(chain.Keys, key)
+		chain.Accounts = append(chain.Accounts, account)
+	}
+
+	chain.touched.addAlloc(settings.alloc)
+	chain.SimulatedBackend = backends.NewSimulatedBackend(settings.alloc, settings.gasLimit)
+	return
+}
+
+// checkSimulatedConfig returns an error when the simulated backend, running params.AllEthashProtocolChanges,
+// does not execute transactions with the rules of config
+func checkSimulatedConfig(config *params.ChainConfig) (err error) {
+	simulated := params.AllEthashProtocolChanges
+	if config.ChainID == nil || config.ChainID.Cmp(simulated.ChainID) != 0 {
+		return fmt.Errorf("simulated backend only supports chain ID %s, got %v", simulated.ChainID, config.ChainID)
+	}
+	// Without a Petersburg block, Petersburg activates with Constantinople
+	petersburg := config.PetersburgBlock
+	if petersburg == nil {
+		petersburg = config.ConstantinopleBlock
+	}
+	forks := []struct {
+		name              string
+		simulated, config *big.Int
+	}{
+		{"Homestead", simulated.HomesteadBlock, config.HomesteadBlock},
+		{"EIP-150", simulated.EIP150Block, config.EIP150Block},
+		{"EIP-155", simulated.EIP155Block, config.EIP155Block},
+		{"EIP-158", simulated.EIP158Block, config.EIP158Block},
+		{"Byzantium", simulated.ByzantiumBlock, config.ByzantiumBlock},
+		{"Constantinople", simulated.ConstantinopleBlock, config.ConstantinopleBlock},
+		{"Petersburg", simulated.PetersburgBlock, petersburg},
+		{"Istanbul", simulated.IstanbulBlock, config.IstanbulBlock},
+		{"Berlin", simulated.BerlinBlock, config.BerlinBlock},
+		{"London", simulated.LondonBlock, config.LondonBlock},
+	}
+	for _, fork := range forks {
+		if (fork.simulated == nil) != (fork.config == nil) || (fork.config != nil && fork.simulated.Cmp(fork.config) != 0) {
+			return fmt.Errorf("simulated backend activates %s at block %v, chain config at %v", fork.name, fork.simulated, fork.config)
+		}
+	}
+	if config.DAOForkSupport && config.DAOForkBlock != nil {
+		return errors.New("simulated backend does not support the DAO fork")
+	}
+	if config.TerminalTotalDifficulty != nil || config.MergeNetsplitBlock != nil {
+		return errors.New("simulated backend does not support the merge")
+	}
+	if config.ShanghaiTime != nil || config.CancunTime != nil || config.PragueTime != nil {
+		return errors.New("simulated backend does not support Shanghai and later forks")
+	}
+	return
+}
+
+// copyChainConfig returns a deep copy of config, so changing it does not affect the chains using config
+func copyChainConfig(config *params.ChainConfig) *params.ChainConfig {
+	copied := new(params.ChainConfig)
+	content, err := json.Marshal(config)
+	if err == nil {
+		err = json.Unmarshal(content, copied)
+	}
+	if err != nil {
+		// Every ChainConfig field round trips through JSON, this is only a safety net
+		shallow := *config
+		return &shallow
+	}
+	return copied
+}
+
+// deterministicKey derives the index-th private key from seed
+func deterministicKey(seed []byte, index int) (key *ecdsa.PrivateKey, err error) {
+	counter := make([]byte, 8)
+	for attempt := uint32(0); ; attempt++ {
+		binary.BigEndian.PutUint32(counter[:4], uint32(index))
+		binary.BigEndian.PutUint32(counter[4:], attempt)
+		key, err = crypto.ToECDSA(crypto.Keccak256(seed, counter))
+		// A hash outside the curve order is astronomically unlikely, but just try the next one
+		if err == nil || attempt == math.MaxUint32 {
+			return
+		}
+	}
+}
+
+// Backend returns the simulated backend of the chain
+func (c *TestChain) Backend() *backends.SimulatedBackend {
+	return c.SimulatedBackend
+}
+
+// Config returns a copy of the chain configuration the simulated backend runs with
+func (c *TestChain) Config() *params.ChainConfig {
+	return copyChainConfig(c.config)
+}
+
+// ChainID returns the chain ID used to sign transactions for the chain, like ethclient.Client.ChainID
+func (c *TestChain) ChainID(ctx context.Context) (*big.Int, error) {
+	return new(big.Int).Set(c.config.ChainID), nil
+}
+
+// Account returns the address of the index-th funded account
+func (c *TestChain) Account(index int) common.Address {
+	return c.Accounts[index].From
+}
+
+// Close releases the resources of the simulated backend
+func (c *TestChain) Close() error {
+	return c.SimulatedBackend.Close()
+}
@@ -0,0 +1,34 @@
+package goethereumhelper
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func TestTestChainConfigIsCopied(t *testing.T) {
+	if copied := copyChainConfig(params.AllEthashProtocolChanges); !reflect.DeepEqual(copied, params.AllEthashProtocolChanges) {
+		t.Fatalf("copy differs from the original: %v", copied)
+	}
+	chain, err := NewTestChain()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Close()
+
+	chain.Config().ChainID.SetInt64(5)
+	chain.Config().LondonBlock = nil
+	genesis, err := chain.ExportGenesis()
+	if err != nil {
+		t.Fatal(err)
+	}
+	genesis.Config.ChainID.SetInt64(6)
+
+	if chainID := params.AllEthashProtocolChanges.ChainID.Int64(); chainID != 1337 {
+		t.Fatalf("go-ethereum config changed to chain ID %d", chainID)
+	}
+	if config := chain.Config(); config.ChainID.Int64() != 1337 || config.LondonBlock == nil {
+		t.Fatalf("chain config changed: %v", config)
+	}
+}
@@ -0,0 +1,9 @@
+// SPDX-License-Identifier: MIT
+pragma solidity 0.8.19;
+
+import "@openzeppelin/contracts/token/ERC1155/presets/ERC1155PresetMinterPauser.sol";
+
+// ERC1155 of the testcontracts package, as MockERC1155 of the Polygon core-contracts
+contract MockERC1155 is ERC1155PresetMinterPauser {
+    constructor() ERC1155PresetMinterPauser("lorem") {}
+}
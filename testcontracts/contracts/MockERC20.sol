@@ -0,0 +1,9 @@
+// SPDX-License-Identifier: MIT
+pragma solidity 0.8.19;
+
+import "@openzeppelin/contracts/token/ERC20/presets/ERC20PresetMinterPauser.sol";
+
+// ERC20 of the testcontracts package, as MockERC20 of the Polygon core-contracts
+contract MockERC20 is ERC20PresetMinterPauser {
+    constructor() ERC20PresetMinterPauser("TEST", "TEST") {}
+}
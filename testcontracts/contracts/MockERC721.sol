@@ -0,0 +1,9 @@
+// SPDX-License-Identifier: MIT
+pragma solidity 0.8.19;
+
+import "@openzeppelin/contracts/token/ERC721/presets/ERC721PresetMinterPauserAutoId.sol";
+
+// ERC721 of the testcontracts package, as MockERC721 of the Polygon core-contracts
+contract MockERC721 is ERC721PresetMinterPauserAutoId {
+    constructor() ERC721PresetMinterPauserAutoId("TEST", "TEST", "lorem") {}
+}
@@ -0,0 +1,39 @@
+#!/bin/sh
+# Compiles the contracts of this directory and generates the bindings of the testcontracts package.
+# Needs solc-select, npm and abigen of the go-ethereum version in go.mod:
+#
+#	pip install solc-select && go install github.com/ethereum/go-ethereum/cmd/abigen@v1.11.4
+#
+# The compiler version, EVM version and optimizer settings of every contract are pinned below, so a rebuild
+# gives the committed bytecode. With "check" as argument the bindings are not regenerated: the script fails
+# when the bytecode compiled from the sources differs from the one committed in the bindings.
+set -e
+cd "$(dirname "$0")"
+mode=${1:-generate}
+status=0
+build=$(mktemp -d)
+trap 'rm -rf "$build"' EXIT
+
+npm install --no-save --silent @openzeppelin/contracts@4.9.3
+
+# bind <solc version> <evm version> <source> <contract> <binding type> <output file>
+bind() {
+	solc-select install "$1" >/dev/null
+	SOLC_VERSION=$1 solc --optimize --optimize-runs 200 --evm-version "$2" --metadata-hash ipfs \
+		--abi --bin --base-path . --include-path node_modules -o "$build/$4" "$3"
+	if [ "$mode" = check ]; then
+		committed=$(sed -n 's/^	Bin: "0x\([0-9a-f]*\)",$/\1/p' "../$6")
+		if [ "$committed" != "$(cat "$build/$4/$4.bin")" ]; then
+			echo "$6: committed bytecode differs from $3 compiled with solc $1" >&2
+			status=1
+		fi
+		return
+	fi
+	abigen --abi "$build/$4/$4.abi" --bin "$build/$4/$4.bin" --pkg testcontracts --type "$5" --out "../$6"
+}
+
+bind 0.8.19 paris MockERC20.sol MockERC20 ERC20 erc20.go
+bind 0.8.19 paris MockERC721.sol MockERC721 ERC721 erc721.go
+bind 0.8.19 paris MockERC1155.sol MockERC1155 ERC1155 erc1155.go
+bind 0.8.12 london Multicall3.sol Multicall3 Multicall3 multicall3.go
+exit $status
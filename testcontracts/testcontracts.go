@@ -0,0 +1,109 @@
+// Package testcontracts has compiled bytecode and Go bindings of reference token contracts to test token
+// handling code on the simulated chain without external artifacts:
+//
+//   - ERC20: OpenZeppelin ERC20PresetMinterPauser, as MockERC20 of the Polygon core-contracts
+//   - ERC721: OpenZeppelin ERC721PresetMinterPauserAutoId, as MockERC721 of the Polygon core-contracts
+//   - ERC1155: OpenZeppelin ERC1155PresetMinterPauser, as MockERC1155 of the Polygon core-contracts
+//   - Multicall3: the call aggregator of github.com/mds1/multicall (MIT)
+//
+// The deployer of the token contracts has the admin, minter and pauser roles. The Solidity sources are in
+// the contracts directory, go generate compiles them with the pinned compiler versions and settings and
+// regenerates the bindings with abigen. TestBytecodeMatchesSources checks the committed bytecode against them.
+package testcontracts
+
+//go:generate sh contracts/build.sh
+
+import (
+	"context"
+	"fmt"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	goethereumhelper "github.com/jeffprestes/goethereumhelper"
+)
+
+// Multicall3Address is where Multicall3 is deployed on mainnet and most other chains
+var Multicall3Address = common.HexToAddress("0xcA11bde05977b3631167028862bE2a173976CA11")
+
+// DeploySimulatedERC20 deploys a mintable ERC-20 token named and symbolized TEST, with 18 decimals, and mines it.
+// Give tokens to accounts with Mint or goethereumhelper.FundAccountWithToken.
+func DeploySimulatedERC20(ctx context.Context, backend *backends.SimulatedBackend, deployer *bind.TransactOpts) (address common.Address, token *ERC20, err error) {
+	address, tx, token, err := DeployERC20(withContext(ctx, deployer), backend)
+	if err != nil {
+		err = fmt.Errorf("could not deploy ERC20: %w", err)
+		return
+	}
+	err = mined(ctx, backend, tx)
+	return
+}
+
+// DeploySimulatedERC721 deploys a mintable ERC-721 token, whose ids are assigned by Mint starting from 0, and mines it
+func DeploySimulatedERC721(ctx context.Context, backend *backends.SimulatedBackend, deployer *bind.TransactOpts) (address common.Address, token *ERC721, err error) {
+	address, tx, token, err := DeployERC721(withContext(ctx, deployer), backend)
+	if err != nil {
+		err = fmt.Errorf("could not deploy ERC721: %w", err)
+		return
+	}
+	err = mined(ctx, backend, tx)
+	return
+}
+
+// DeploySimulatedERC1155 deploys a mintable ERC-1155 token and mines it
+func DeploySimulatedERC1155(ctx context.Context, backend *backends.SimulatedBackend, deployer *bind.TransactOpts) (address common.Address, token *ERC1155, err error) {
+	address, tx, token, err := DeployERC1155(withContext(ctx, deployer), backend)
+	if err != nil {
+		err = fmt.Errorf("could not deploy ERC1155: %w", err)
+		return
+	}
+	err = mined(ctx, backend, tx)
+	return
+}
+
+// DeploySimulatedMulticall3 deploys Multicall3 and mines it. Use InstallMulticall3 for code expecting it at
+// Multicall3Address.
+func DeploySimulatedMulticall3(ctx context.Context, backend *backends.SimulatedBackend, deployer *bind.TransactOpts) (address common.Address, multicall *Multicall3, err error) {
+	address, tx, multicall, err := DeployMulticall3(withContext(ctx, deployer), backend)
+	if err != nil {
+		err = fmt.Errorf("could not deploy Multicall3: %w", err)
+		return
+	}
+	err = mined(ctx, backend, tx)
+	return
+}
+
+// InstallMulticall3 writes the Multicall3 code at Multicall3Address, as on the public chains
+func InstallMulticall3(chain *goethereumhelper.TestChain) (multicall *Multicall3, err error) {
+	// Running the creation code returns the runtime code
+	code, err := chain.CallContract(context.Background(), ethereum.CallMsg{From: chain.Account(0), Data: common.FromHex(Multicall3MetaData.Bin)}, nil)
+	if err != nil {
+		err = fmt.Errorf("could not build Multicall3 code: %w", err)
+		return
+	}
+	err = chain.SetCode(Multicall3Address, code)
+	if err != nil {
+		return
+	}
+	return NewMulticall3(Multicall3Address, chain)
+}
+
+func withContext(ctx context.Context, transactor *bind.TransactOpts) *bind.TransactOpts {
+	opts := *transactor
+	opts.Context = ctx
+	return &opts
+}
+
+// mined commits the deployment transaction and checks it succeeded
+func mined(ctx context.Context, backend *backends.SimulatedBackend, tx *types.Transaction) (err error) {
+	backend.Commit()
+	receipt, err := backend.TransactionReceipt(ctx, tx.Hash())
+	if err != nil {
+		return
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		err = fmt.Errorf("deployment transaction %s failed", tx.Hash().Hex())
+	}
+	return
+}
@@ -0,0 +1,24 @@
+package testcontracts
+
+import (
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// TestBytecodeMatchesSources compiles the contracts with the pinned compilers and fails when the bytecode of
+// the committed bindings was not built from them. It is skipped without the tools of contracts/build.sh.
+func TestBytecodeMatchesSources(t *testing.T) {
+	if testing.Short() {
+		t.Skip("compiling the contracts is slow")
+	}
+	for _, tool := range []string{"solc-select", "solc", "npm"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("%s is not installed", tool)
+		}
+	}
+	output, err := exec.Command("sh", filepath.Join("contracts", "build.sh"), "check").CombinedOutput()
+	if err != nil {
+		t.Fatalf("%v\n%s", err, output)
+	}
+}
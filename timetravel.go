@@ -0,0 +1,127 @@
+package goethereumhelper
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// ErrSnapshotNotFound is returned when reverting to a snapshot unknown or already reverted
+var ErrSnapshotNotFound = errors.New("snapshot not found")
+
+// chainSnapshot is the chain head saved by TestChain.Snapshot
+type chainSnapshot struct {
+	id     int
+	number uint64
+	hash   common.Hash
+}
+
+// AdvanceTime mines an empty block whose timestamp is d later than it would otherwise be, so contracts
+// reading block.timestamp observe the time jump in calls and in the following transactions.
+// It fails when there are pending transactions: Commit or DiscardPending them first.
+//
+// Example, testing a timelock released after one day:
+//
+//	chain, _ := NewTestChain()
+//	// ... deploy the timelock and lock funds ...
+//	if err := chain.AdvanceTime(24 * time.Hour); err != nil {
+//		t.Fatal(err)
+//	}
+//	// ... release must succeed now ...
+func (c *TestChain) AdvanceTime(d time.Duration) (err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	err = c.SimulatedBackend.AdjustTime(d)
+	if err != nil {
+		err = fmt.Errorf("could not advance time by %s: %w", d, err)
+		return
+	}
+	c.SimulatedBackend.Commit()
+	return
+}
+
+// MineBlocks mines n blocks, the first one including the pending transactions, and returns the hash of the last one
+func (c *TestChain) MineBlocks(n int) (head common.Hash) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i := 0; i < n; i++ {
+		head = c.SimulatedBackend.Commit()
+	}
+	return
+}
+
+// DiscardPending drops the pending transactions not mined yet, restoring the state of the latest block
+func (c *TestChain) DiscardPending() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.SimulatedBackend.Rollback()
+}
+
+// Snapshot saves the current chain head and returns an id to be used with RevertToSnapshot.
+// Pending transactions are not part of the snapshot.
+//
+// Example, resetting the state between test cases:
+//
+//	chain, _ := NewTestChain()
+//	// ... deploy the contracts shared by every case ...
+//	base := chain.Snapshot()
+//	for _, tc := range cases {
+//		// ... run tc, committing as many blocks as needed ...
+//		if err := chain.RevertToSnapshot(base); err != nil {
+//			t.Fatal(err)
+//		}
+//		base = chain.Snapshot()
+//	}
+func (c *TestChain) Snapshot() (id int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	head := c.SimulatedBackend.Blockchain().CurrentBlock()
+	c.lastSnapshot++
+	c.snapshots = append(c.snapshots, chainSnapshot{
+		id:     c.lastSnapshot,
+		number: head.Number.Uint64(),
+		hash:   head.Hash(),
+	})
+	return c.lastSnapshot
+}
+
+// RevertToSnapshot rewinds the chain to the head saved by Snapshot, deleting the blocks mined after it and
+// dropping pending transactions. The snapshot and every snapshot taken after it are consumed.
+// The simulated backend only keeps the state of the latest 128 blocks in memory, so older snapshots
+// cannot be reverted.
+func (c *TestChain) RevertToSnapshot(id int) (err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	index := -1
+	for i, snapshot := range c.snapshots {
+		if snapshot.id == id {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return fmt.Errorf("%w: %d", ErrSnapshotNotFound, id)
+	}
+	snapshot := c.snapshots[index]
+	c.snapshots = c.snapshots[:index]
+
+	blockchain := c.SimulatedBackend.Blockchain()
+	block := blockchain.GetBlock(snapshot.hash, snapshot.number)
+	if block == nil || blockchain.GetCanonicalHash(snapshot.number) != snapshot.hash {
+		return fmt.Errorf("snapshot %d block %d is no longer part of the chain", id, snapshot.number)
+	}
+	if !blockchain.HasState(block.Root()) {
+		return fmt.Errorf("state of snapshot %d block %d is no longer available", id, snapshot.number)
+	}
+	err = blockchain.SetHead(snapshot.number)
+	if err != nil {
+		return
+	}
+	if head := blockchain.CurrentBlock(); head.Hash() != snapshot.hash {
+		return fmt.Errorf("chain rewound to block %d instead of snapshot %d block %d", head.Number.Uint64(), id, snapshot.number)
+	}
+	c.SimulatedBackend.Rollback()
+	return
+}
@@ -0,0 +1,119 @@
+package goethereumhelper_test
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/jeffprestes/goethereumhelper"
+)
+
+func ExampleTestChain_AdvanceTime() {
+	chain, err := goethereumhelper.NewTestChain()
+	if err != nil {
+		panic(err)
+	}
+	defer chain.Close()
+	ctx := context.Background()
+	before, _ := chain.HeaderByNumber(ctx, nil)
+	if err := chain.AdvanceTime(24 * time.Hour); err != nil {
+		panic(err)
+	}
+	after, _ := chain.HeaderByNumber(ctx, nil)
+	fmt.Println("blocks mined:", after.Number.Uint64()-before.Number.Uint64())
+	fmt.Println("at least a day later:", after.Time-before.Time >= uint64((24*time.Hour).Seconds()))
+	// Output:
+	// blocks mined: 1
+	// at least a day later: true
+}
+
+func ExampleTestChain_MineBlocks() {
+	chain, err := goethereumhelper.NewTestChain()
+	if err != nil {
+		panic(err)
+	}
+	defer chain.Close()
+	head := chain.MineBlocks(10)
+	block, _ := chain.BlockByHash(context.Background(), head)
+	fmt.Println("head:", block.NumberU64())
+	// Output:
+	// head: 10
+}
+
+func ExampleTestChain_Snapshot() {
+	chain, err := goethereumhelper.NewTestChain()
+	if err != nil {
+		panic(err)
+	}
+	defer chain.Close()
+	ctx := context.Background()
+	recipient := chain.Account(1)
+	base := chain.Snapshot()
+
+	sendEther(chain, recipient, big.NewInt(1000))
+	chain.Commit()
+	balance, _ := chain.BalanceAt(ctx, recipient, nil)
+	fmt.Println("balance after transfer:", balance)
+
+	if err := chain.RevertToSnapshot(base); err != nil {
+		panic(err)
+	}
+	balance, _ = chain.BalanceAt(ctx, recipient, nil)
+	fmt.Println("balance after revert:", balance)
+	// Output:
+	// balance after transfer: 1000000000000000001000
+	// balance after revert: 1000000000000000000000
+}
+
+func ExampleTestChain_DiscardPending() {
+	chain, err := goethereumhelper.NewTestChain()
+	if err != nil {
+		panic(err)
+	}
+	defer chain.Close()
+	ctx := context.Background()
+	recipient := chain.Account(1)
+
+	sendEther(chain, recipient, big.NewInt(1000))
+	pending, _ := chain.PendingNonceAt(ctx, chain.Account(0))
+	fmt.Println("pending nonce:", pending)
+
+	chain.DiscardPending()
+	pending, _ = chain.PendingNonceAt(ctx, chain.Account(0))
+	fmt.Println("pending nonce after discard:", pending)
+	// Output:
+	// pending nonce: 1
+	// pending nonce after discard: 0
+}
+
+// sendEther sends value wei from the first account of chain to recipient, without mining it
+func sendEther(chain *goethereumhelper.TestChain, recipient common.Address, value *big.Int) {
+	ctx := context.Background()
+	chainID, _ := chain.ChainID(ctx)
+	nonce, err := chain.PendingNonceAt(ctx, chain.Account(0))
+	if err != nil {
+		panic(err)
+	}
+	head, err := chain.HeaderByNumber(ctx, nil)
+	if err != nil {
+		panic(err)
+	}
+	tx, err := types.SignTx(types.NewTx(&types.DynamicFeeTx{
+		ChainID:   chainID,
+		Nonce:     nonce,
+		Gas:       21000,
+		GasFeeCap: new(big.Int).Mul(head.BaseFee, big.NewInt(2)),
+		GasTipCap: big.NewInt(1),
+		To:        &recipient,
+		Value:     value,
+	}), types.LatestSignerForChainID(chainID), chain.Keys[0])
+	if err != nil {
+		panic(err)
+	}
+	if err := chain.SendTransaction(ctx, tx); err != nil {
+		panic(err)
+	}
+}
@@ -8,6 +8,8 @@ import (
 	"math/big"
 	"os"
 
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethclient"
 
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
@@ -122,3 +124,21 @@ func GetKeyedTransactorWithOptions(client *ethclient.Client, increaseNonceFactor
 
 	return
 }
+
+/*
+NewSignerTransactor returns a transactor signing the transactions of chainID with signer, like a ClefSigner, a
+KeystoreWallet or a PrivateKeySigner
+*/
+func NewSignerTransactor(signer TxSigner, chainID *big.Int) *bind.TransactOpts {
+	from := signer.Address()
+	return &bind.TransactOpts{
+		From:    from,
+		Context: context.Background(),
+		Signer: func(address common.Address, tx *types.Transaction) (*types.Transaction, error) {
+			if address != from {
+				return nil, bind.ErrNotAuthorized
+			}
+			return signer.SignTx(tx, chainID)
+		},
+	}
+}
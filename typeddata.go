@@ -0,0 +1,158 @@
+package goethereumhelper
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+// NewTypedData builds EIP-712 typed data. The message is a map or a Go struct, converted like
+// NormalizeABIValue does, so name its fields with json tags matching types. The EIP712Domain type is
+// added to types when missing, with the fields set in domain.
+//
+// Example, an ERC-2612 permit:
+//
+//	type Permit struct {
+//		Owner    common.Address `json:"owner"`
+//		Spender  common.Address `json:"spender"`
+//		Value    *big.Int       `json:"value"`
+//		Nonce    *big.Int       `json:"nonce"`
+//		Deadline *big.Int       `json:"deadline"`
+//	}
+//	typedData, err := NewTypedData(
+//		apitypes.TypedDataDomain{Name: "Token", Version: "1", ChainId: math.NewHexOrDecimal256(1), VerifyingContract: token.Hex()},
+//		apitypes.Types{"Permit": {{Name: "owner", Type: "address"}, {Name: "spender", Type: "address"}, {Name: "value", Type: "uint256"}, {Name: "nonce", Type: "uint256"}, {Name: "deadline", Type: "uint256"}}},
+//		"Permit",
+//		Permit{Owner: owner, Spender: spender, Value: value, Nonce: nonce, Deadline: deadline},
+//	)
+//	signature, err := SignTypedData(ownerKey, typedData)
+func NewTypedData(domain apitypes.TypedDataDomain, types apitypes.Types, primaryType string, message interface{}) (typedData apitypes.TypedData, err error) {
+	normalized, ok := NormalizeABIValue(message).(map[string]interface{})
+	if !ok {
+		err = fmt.Errorf("typed data message must be a struct or a map, not %T", message)
+		return
+	}
+	allTypes := make(apitypes.Types, len(types)+1)
+	for name, fields := range types {
+		allTypes[name] = fields
+	}
+	if _, found := allTypes["EIP712Domain"]; !found {
+		allTypes["EIP712Domain"] = domainType(domain)
+	}
+	typedData = apitypes.TypedData{
+		Types:       allTypes,
+		PrimaryType: primaryType,
+		Domain:      domain,
+		Message:     normalized,
+	}
+	_, err = TypedDataHash(typedData)
+	return
+}
+
+// domainType returns the EIP712Domain fields set in domain, in the order of the EIP
+func domainType(domain apitypes.TypedDataDomain) (fields []apitypes.Type) {
+	if domain.Name != "" {
+		fields = append(fields, apitypes.Type{Name: "name", Type: "string"})
+	}
+	if domain.Version != "" {
+		fields = append(fields, apitypes.Type{Name: "version", Type: "string"})
+	}
+	if domain.ChainId != nil {
+		fields = append(fields, apitypes.Type{Name: "chainId", Type: "uint256"})
+	}
+	if domain.VerifyingContract != "" {
+		fields = append(fields, apitypes.Type{Name: "verifyingContract", Type: "address"})
+	}
+	if domain.Salt != "" {
+		fields = append(fields, apitypes.Type{Name: "salt", Type: "bytes32"})
+	}
+	return
+}
+
+// ParseTypedData parses EIP-712 typed data in the JSON format of eth_signTypedData_v4
+func ParseTypedData(content []byte) (typedData apitypes.TypedData, err error) {
+	err = json.Unmarshal(content, &typedData)
+	if err != nil {
+		err = fmt.Errorf("invalid typed data: %w", err)
+		return
+	}
+	_, err = TypedDataHash(typedData)
+	return
+}
+
+// TypedDataHash returns the EIP-712 hash of typedData, the one signed
+func TypedDataHash(typedData apitypes.TypedData) (hash common.Hash, err error) {
+	hashBytes, _, err := apitypes.TypedDataAndHash(typedData)
+	if err != nil {
+		err = fmt.Errorf("invalid typed data: %w", err)
+		return
+	}
+	return common.BytesToHash(hashBytes), nil
+}
+
+// SignTypedData signs typedData with privateKey, returning a 65 bytes [R || S || V] signature with V 27 or 28,
+// as eth_signTypedData_v4
+func SignTypedData(privateKey *ecdsa.PrivateKey, typedData apitypes.TypedData) (signature []byte, err error) {
+	hash, err := TypedDataHash(typedData)
+	if err != nil {
+		return
+	}
+	signature, err = crypto.Sign(hash.Bytes(), privateKey)
+	if err != nil {
+		return
+	}
+	signature[crypto.RecoveryIDOffset] += 27
+	return
+}
+
+// SignTypedData signs typedData with the unlocked wallet account, like the SignTypedData function
+func (w *KeystoreWallet) SignTypedData(typedData apitypes.TypedData) (signature []byte, err error) {
+	hash, err := TypedDataHash(typedData)
+	if err != nil {
+		return
+	}
+	signature, err = w.Keystore.SignHash(w.Account, hash.Bytes())
+	if err != nil {
+		return
+	}
+	w.signed()
+	signature[crypto.RecoveryIDOffset] += 27
+	return
+}
+
+// SignTypedDataWithPassphrase signs typedData with the wallet account, decrypting its key with passphrase
+func (w *KeystoreWallet) SignTypedDataWithPassphrase(passphrase string, typedData apitypes.TypedData) (signature []byte, err error) {
+	hash, err := TypedDataHash(typedData)
+	if err != nil {
+		return
+	}
+	signature, err = w.Keystore.SignHashWithPassphrase(w.Account, passphrase, hash.Bytes())
+	if err != nil {
+		return
+	}
+	signature[crypto.RecoveryIDOffset] += 27
+	return
+}
+
+// RecoverTypedDataSigner returns the address whose key made signature of typedData. Signatures are accepted
+// in the forms RecoverSigner does.
+func RecoverTypedDataSigner(typedData apitypes.TypedData, signature []byte) (signer common.Address, err error) {
+	hash, err := TypedDataHash(typedData)
+	if err != nil {
+		return
+	}
+	return RecoverSigner(hash, signature)
+}
+
+// VerifyTypedData tells if signature of typedData was made by address
+func VerifyTypedData(typedData apitypes.TypedData, signature []byte, address common.Address) (valid bool, err error) {
+	signer, err := RecoverTypedDataSigner(typedData, signature)
+	if err != nil {
+		return
+	}
+	return signer == address, nil
+}
@@ -0,0 +1,161 @@
+package goethereumhelper
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+// The Mail example of EIP-712, signed by the key keccak256("cow")
+const (
+	mailTypedData = `{
+	"types": {
+		"EIP712Domain": [
+			{"name": "name", "type": "string"},
+			{"name": "version", "type": "string"},
+			{"name": "chainId", "type": "uint256"},
+			{"name": "verifyingContract", "type": "address"}
+		],
+		"Person": [
+			{"name": "name", "type": "string"},
+			{"name": "wallet", "type": "address"}
+		],
+		"Mail": [
+			{"name": "from", "type": "Person"},
+			{"name": "to", "type": "Person"},
+			{"name": "contents", "type": "string"}
+		]
+	},
+	"primaryType": "Mail",
+	"domain": {
+		"name": "Ether Mail",
+		"version": "1",
+		"chainId": 1,
+		"verifyingContract": "0xCcCCccccCCCCcCCCCCCcCcCccCcCCCcCcccccccC"
+	},
+	"message": {
+		"from": {"name": "Cow", "wallet": "0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826"},
+		"to": {"name": "Bob", "wallet": "0xbBbBBBBbbBBBbbbBbbBbbbbBBbBbbbbBbBbbBBbB"},
+		"contents": "Hello, Bob!"
+	}
+}`
+	mailHash      = "0xbe609aee343fb3c4b28e1df9e632fca64fcfaede20f02e86244efddf30957bd2"
+	mailSignature = "0x4355c47d63924e8a72e509b65029052eb6c299d53a04e167c5775fd466751c9d07299936d304c153f6443dfa05f40ff007d72911b6f72307f996231605b915621c"
+	mailSigner    = "0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826"
+)
+
+type mailPerson struct {
+	Name   string         `json:"name"`
+	Wallet common.Address `json:"wallet"`
+}
+
+type mail struct {
+	From     mailPerson `json:"from"`
+	To       mailPerson `json:"to"`
+	Contents string     `json:"contents"`
+}
+
+func TestTypedDataMailVector(t *testing.T) {
+	typedData, err := ParseTypedData([]byte(mailTypedData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, err := TypedDataHash(typedData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash.Hex() != mailHash {
+		t.Fatalf("hash is %s, expected %s", hash.Hex(), mailHash)
+	}
+
+	key, err := crypto.ToECDSA(crypto.Keccak256([]byte("cow")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature, err := SignTypedData(key, typedData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hexutil.Encode(signature) != mailSignature {
+		t.Fatalf("signature is %s, expected %s", hexutil.Encode(signature), mailSignature)
+	}
+	valid, err := VerifyTypedData(typedData, signature, common.HexToAddress(mailSigner))
+	if err != nil || !valid {
+		t.Fatalf("signature not verified: %v", err)
+	}
+	signature[64] -= 27
+	signer, err := RecoverTypedDataSigner(typedData, signature)
+	if err != nil || signer != common.HexToAddress(mailSigner) {
+		t.Fatalf("recovered %s with V 0 or 1, %v", signer.Hex(), err)
+	}
+}
+
+func TestNewTypedDataFromStruct(t *testing.T) {
+	typedData, err := NewTypedData(
+		apitypes.TypedDataDomain{
+			Name:              "Ether Mail",
+			Version:           "1",
+			ChainId:           math.NewHexOrDecimal256(1),
+			VerifyingContract: "0xCcCCccccCCCCcCCCCCCcCcCccCcCCCcCcccccccC",
+		},
+		apitypes.Types{
+			"Person": {{Name: "name", Type: "string"}, {Name: "wallet", Type: "address"}},
+			"Mail":   {{Name: "from", Type: "Person"}, {Name: "to", Type: "Person"}, {Name: "contents", Type: "string"}},
+		},
+		"Mail",
+		mail{
+			From:     mailPerson{Name: "Cow", Wallet: common.HexToAddress(mailSigner)},
+			To:       mailPerson{Name: "Bob", Wallet: common.HexToAddress("0xbBbBBBBbbBBBbbbBbbBbbbbBBbBbbbbBbBbbBBbB")},
+			Contents: "Hello, Bob!",
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, err := TypedDataHash(typedData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash.Hex() != mailHash {
+		t.Fatalf("hash is %s, expected %s", hash.Hex(), mailHash)
+	}
+}
+
+func TestKeystoreWalletSignTypedData(t *testing.T) {
+	ks := OpenKeystore(t.TempDir(), LightScrypt)
+	key, err := crypto.ToECDSA(crypto.Keccak256([]byte("cow")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	account, err := ImportPrivateKey(ks, key, "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wallet, err := NewKeystoreWallet(ks, account.Address.Hex(), "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	typedData, err := ParseTypedData([]byte(mailTypedData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature, err := wallet.SignTypedData(typedData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hexutil.Encode(signature) != mailSignature {
+		t.Fatalf("signature is %s, expected %s", hexutil.Encode(signature), mailSignature)
+	}
+	signature, err = wallet.SignData(accounts.MimetypeTypedData, []byte(mailTypedData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hexutil.Encode(signature) != mailSignature {
+		t.Fatalf("SignData signature is %s, expected %s", hexutil.Encode(signature), mailSignature)
+	}
+}
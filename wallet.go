@@ -22,31 +22,31 @@ type KeystoreWallet struct {
 	Account  accounts.Account   // Single account contained in this wallet
 	Keystore *keystore.KeyStore // Keystore where the account originates from
 	Wallet   accounts.Wallet
+
+	// Passphrases gives the passphrases to the methods not taking them as an argument, like SwitchAccountWithProvider
+	Passphrases PassphraseProvider
+
+	lock *walletLock
 }
 
-// NewKeystoreWallet returns new instance of KeystoreWallet
+// NewKeystoreWallet returns new instance of KeystoreWallet, unlocking the account accountHex of ks
 func NewKeystoreWallet(ks *keystore.KeyStore, accountHex, keystorePassphrase string) (ksw *KeystoreWallet, err error) {
-	ksw = new(KeystoreWallet)
-	a1 := accounts.Account{}
-	a1.Address = common.HexToAddress(accountHex)
-	account, err := ks.Find(a1)
+	ksw = &KeystoreWallet{Keystore: ks, lock: new(walletLock)}
+	err = ksw.SwitchAccount(accountHex, keystorePassphrase)
 	if err != nil {
-		log.Fatalln("NewKeystoreWallet - Account not found within keystore", err.Error())
-	}
-	for _, w := range ks.Wallets() {
-		for _, acc := range w.Accounts() {
-			if acc.Address.Hash() == account.Address.Hash() {
-				ksw.Wallet = w
-				break
-			}
-		}
+		return nil, err
 	}
-	err = ks.Unlock(account, keystorePassphrase)
+	return
+}
+
+// NewKeystoreWalletWithProvider returns new instance of KeystoreWallet, unlocking the account accountHex of ks
+// with the passphrase given by provider
+func NewKeystoreWalletWithProvider(ks *keystore.KeyStore, accountHex string, provider PassphraseProvider) (ksw *KeystoreWallet, err error) {
+	ksw = &KeystoreWallet{Keystore: ks, Passphrases: provider, lock: new(walletLock)}
+	err = ksw.SwitchAccountWithProvider(accountHex)
 	if err != nil {
-		log.Fatalln("NewKeystoreWallet - ", account.Address.Hex(), " could not be unlock: ", err.Error())
+		return nil, err
 	}
-	ksw.Keystore = ks
-	ksw.Account = account
 	return
 }
 
@@ -69,14 +69,30 @@ func (w *KeystoreWallet) SignTxWithPassphrase(passphrase string, tx *types.Trans
 	return w.Keystore.SignTxWithPassphrase(w.Account, passphrase, tx, chainID)
 }
 
-// SignData signs keccak256(data). The mimetype parameter describes the type of data being signed
-func (w *KeystoreWallet) SignData(mimeType string, data []byte) ([]byte, error) {
-	return w.Keystore.SignHash(w.Account, crypto.Keccak256(data))
+// SignData signs keccak256(data). The mimetype parameter describes the type of data being signed: with
+// accounts.MimetypeTypedData, data is EIP-712 typed data as JSON and is signed like SignTypedData does.
+func (w *KeystoreWallet) SignData(mimeType string, data []byte) (signature []byte, err error) {
+	if mimeType == accounts.MimetypeTypedData {
+		typedData, errParse := ParseTypedData(data)
+		if errParse != nil {
+			return nil, errParse
+		}
+		return w.SignTypedData(typedData)
+	}
+	signature, err = w.Keystore.SignHash(w.Account, crypto.Keccak256(data))
+	if err == nil {
+		w.signed()
+	}
+	return
 }
 
 // SignTx signs a transaction using the selected account in the keystore. Important: the account must be unlocked.
-func (w *KeystoreWallet) SignTx(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
-	return w.Keystore.SignTx(w.Account, tx, chainID)
+func (w *KeystoreWallet) SignTx(tx *types.Transaction, chainID *big.Int) (signedTx *types.Transaction, err error) {
+	signedTx, err = w.Keystore.SignTx(w.Account, tx, chainID)
+	if err == nil {
+		w.signed()
+	}
+	return
 }
 
 // GetNonceNumber gets actual nonce number of an Ethereum address/account
@@ -89,34 +105,54 @@ func (w *KeystoreWallet) GetNonceNumber(client *ethclient.Client) (nonce uint64,
 	return
 }
 
+// SwitchAccount selects and unlocks the account accountHex of the wallet keystore
 func (w *KeystoreWallet) SwitchAccount(accountHex, keystorePassphrase string) (err error) {
-	err = nil
-	a1 := accounts.Account{}
-	a1.Address = common.HexToAddress(accountHex)
-	account, err := w.Keystore.Find(a1)
+	w.lockState()
+	account, err := findKeystoreAccount(w.Keystore, common.HexToAddress(accountHex))
 	if err != nil {
-		err = fmt.Errorf("NewKeystoreWallet - SwitchAccount - Account: %s not found in keystore. Error: %s", accountHex, err.Error())
 		return
 	}
-	var accountFound bool
-	for _, wallet := range w.Keystore.Wallets() {
-		for _, acc := range wallet.Accounts() {
-			if acc.Address.Hash() == account.Address.Hash() {
-				w.Wallet = wallet
-				accountFound = true
-				break
-			}
+	var wallet accounts.Wallet
+	for _, candidate := range w.Keystore.Wallets() {
+		if candidate.Contains(account) {
+			wallet = candidate
+			break
 		}
 	}
-	if !accountFound {
-		err = fmt.Errorf("NewKeystoreWallet - SwitchAccount - Account: %s not found in wallets. Error: %s", accountHex, err.Error())
-		return
+	if wallet == nil {
+		return fmt.Errorf("account %s not found in keystore wallets", account.Address.Hex())
+	}
+	err = w.unlock(account, keystorePassphrase, 0)
+	if err != nil {
+		return fmt.Errorf("account %s could not be unlocked: %w", account.Address.Hex(), err)
 	}
+	w.Wallet = wallet
 	w.Account = account
+	return
+}
+
+// SwitchAccountWithProvider selects and unlocks the account accountHex with the passphrase given by w.Passphrases
+func (w *KeystoreWallet) SwitchAccountWithProvider(accountHex string) (err error) {
+	account, err := findKeystoreAccount(w.Keystore, common.HexToAddress(accountHex))
+	if err != nil {
+		return
+	}
+	passphrase, err := w.passphrase(account)
+	if err != nil {
+		return
+	}
+	return w.SwitchAccount(accountHex, passphrase)
+}
 
-	err = w.Keystore.Unlock(account, keystorePassphrase)
+// passphrase returns the passphrase of account given by w.Passphrases
+func (w *KeystoreWallet) passphrase(account accounts.Account) (passphrase string, err error) {
+	if w.Passphrases == nil {
+		err = errors.New("wallet has no passphrase provider")
+		return
+	}
+	passphrase, err = w.Passphrases.Passphrase(account)
 	if err != nil {
-		log.Fatalln("NewKeystoreWallet - ", account.Address.Hex(), " could not be unlock: ", err.Error())
+		err = fmt.Errorf("could not get the passphrase of account %s: %w", account.Address.Hex(), err)
 	}
 	return
 }
@@ -161,6 +197,18 @@ func (w *KeystoreWallet) UpdateKeyedTransactor(transactor *bind.TransactOpts, cl
 // NewKeyStoreTransactor is a utility method to easily create a transaction signer from
 // an decrypted key from a keystore
 func (w *KeystoreWallet) NewKeyStoreTransactor(passphrase string, client *ethclient.Client) (*bind.TransactOpts, error) {
+	return w.newKeyStoreTransactor(client, func(accounts.Account) (string, error) {
+		return passphrase, nil
+	})
+}
+
+// NewKeyStoreTransactorWithProvider is like NewKeyStoreTransactor, asking w.Passphrases for the passphrase
+// at every signature
+func (w *KeystoreWallet) NewKeyStoreTransactorWithProvider(client *ethclient.Client) (*bind.TransactOpts, error) {
+	return w.newKeyStoreTransactor(client, w.passphrase)
+}
+
+func (w *KeystoreWallet) newKeyStoreTransactor(client *ethclient.Client, passphrase PassphraseFunc) (*bind.TransactOpts, error) {
 	chainID, err := client.ChainID(context.Background())
 	if err != nil {
 		log.Println("[NewKeyStoreTransactor] Error getting chainID: ", err.Error())
@@ -172,11 +220,16 @@ func (w *KeystoreWallet) NewKeyStoreTransactor(passphrase string, client *ethcli
 		log.Println("[NewKeyStoreTransactor] Error generating NewKeyStoreTransactorWithChainID: ", err.Error())
 		return nil, err
 	}
+	account := w.Account
 	txOpts.Signer = func(address common.Address, tx *types.Transaction) (*types.Transaction, error) {
-		if address != w.Account.Address {
+		if address != account.Address {
 			return nil, errors.New("not authorized to sign this account")
 		}
-		signature, err := w.Keystore.SignHashWithPassphrase(w.Account, passphrase, signer.Hash(tx).Bytes())
+		accountPassphrase, err := passphrase(account)
+		if err != nil {
+			return nil, err
+		}
+		signature, err := w.Keystore.SignHashWithPassphrase(account, accountPassphrase, signer.Hash(tx).Bytes())
 		if err != nil {
 			return nil, err
 		}
@@ -186,8 +239,9 @@ func (w *KeystoreWallet) NewKeyStoreTransactor(passphrase string, client *ethcli
 	return txOpts, nil
 }
 
-// GenerateSignedTxAsJSON generates a JSON signed raw Ethereum Transaction
-// TODO: Update to London fork
+// GenerateSignedTxAsJSON generates a JSON signed raw Ethereum Transaction. It is an EIP-1559 dynamic fee
+// transaction paying txOpts.GasFeeCap and txOpts.GasTipCap, or a legacy one when txOpts.GasPrice is set.
+// Use OfflineTransaction to prepare and sign transactions on different machines.
 func (w *KeystoreWallet) GenerateSignedTxAsJSON(
 	txOpts *bind.TransactOpts,
 	passphrase string,
@@ -195,18 +249,51 @@ func (w *KeystoreWallet) GenerateSignedTxAsJSON(
 	smartContractAddress common.Address,
 	nonce, chainID uint64,
 ) (txJSON []byte, err error) {
-	tx := types.NewTransaction(nonce, smartContractAddress, big.NewInt(0), txOpts.GasLimit, txOpts.GasPrice, contractMethodParameters)
+	var txData types.TxData
+	switch {
+	case txOpts.GasPrice != nil:
+		txData = &types.LegacyTx{
+			Nonce:    nonce,
+			GasPrice: txOpts.GasPrice,
+			Gas:      txOpts.GasLimit,
+			To:       &smartContractAddress,
+			Value:    big.NewInt(0),
+			Data:     contractMethodParameters,
+		}
+	case txOpts.GasFeeCap != nil && txOpts.GasTipCap != nil:
+		txData = &types.DynamicFeeTx{
+			ChainID:   new(big.Int).SetUint64(chainID),
+			Nonce:     nonce,
+			GasTipCap: txOpts.GasTipCap,
+			GasFeeCap: txOpts.GasFeeCap,
+			Gas:       txOpts.GasLimit,
+			To:        &smartContractAddress,
+			Value:     big.NewInt(0),
+			Data:      contractMethodParameters,
+		}
+	default:
+		err = errors.New("txOpts must have GasPrice or both GasFeeCap and GasTipCap")
+		return
+	}
 
-	txSigned, err := w.SignTxWithPassphrase(passphrase, tx, big.NewInt(int64(chainID)))
+	txSigned, err := w.SignTxWithPassphrase(passphrase, types.NewTx(txData), new(big.Int).SetUint64(chainID))
 	if err != nil {
-		log.Fatalln("Erro obter o signer da conta", w.Account.Address.Hex(), err.Error())
+		err = fmt.Errorf("could not sign the transaction with account %s: %w", w.Account.Address.Hex(), err)
 		return
 	}
+	return txSigned.MarshalJSON()
+}
 
-	txJSON, err = txSigned.MarshalJSON()
+// GenerateSignedTxAsJSONWithProvider is like GenerateSignedTxAsJSON, asking w.Passphrases for the passphrase
+func (w *KeystoreWallet) GenerateSignedTxAsJSONWithProvider(
+	txOpts *bind.TransactOpts,
+	contractMethodParameters []byte,
+	smartContractAddress common.Address,
+	nonce, chainID uint64,
+) (txJSON []byte, err error) {
+	passphrase, err := w.passphrase(w.Account)
 	if err != nil {
-		log.Fatalln("Error serializing the transaction", err.Error())
 		return
 	}
-	return
+	return w.GenerateSignedTxAsJSON(txOpts, passphrase, contractMethodParameters, smartContractAddress, nonce, chainID)
 }
@@ -0,0 +1,217 @@
+package goethereumhelper
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+)
+
+// LockReason tells why a KeystoreWallet was locked
+type LockReason int
+
+const (
+	LockRequested      LockReason = iota // Lock was called or the wallet switched to another account
+	LockExpired                          // The duration given to UnlockFor elapsed
+	LockIdle                             // No signature was made during WalletLockPolicy.IdleTimeout
+	LockSignatureLimit                   // WalletLockPolicy.MaxSignatures signatures were made
+)
+
+func (r LockReason) String() string {
+	switch r {
+	case LockRequested:
+		return "requested"
+	case LockExpired:
+		return "expired"
+	case LockIdle:
+		return "idle"
+	case LockSignatureLimit:
+		return "signature limit"
+	}
+	return "unknown"
+}
+
+// WalletLockPolicy limits how long the key of an unlocked KeystoreWallet stays decrypted in memory
+type WalletLockPolicy struct {
+	MaxSignatures int           // Locks after this number of signatures, no limit when 0
+	IdleTimeout   time.Duration // Locks when no signature is made for this long, no limit when 0
+}
+
+// walletLock is the unlock state of a KeystoreWallet. It is kept by pointer so the copies of the wallet,
+// like the one given to SendEtherUsingKeystoreWallet, share it.
+type walletLock struct {
+	mu         sync.Mutex
+	policy     WalletLockPolicy
+	hooks      []func(account accounts.Account, reason LockReason)
+	unlocked   bool
+	account    accounts.Account
+	expires    time.Time // Zero when unlocked until Lock
+	lastUsed   time.Time
+	signatures int
+	timer      *time.Timer
+	generation int // Incremented at every unlock and lock so stale timers are ignored
+}
+
+// walletLockAlloc guards the allocation of the lock state of the wallets built without NewKeystoreWallet
+var walletLockAlloc sync.Mutex
+
+// lockState returns the unlock state of the wallet. NewKeystoreWallet and SwitchAccount allocate it, so the
+// copies made afterwards share it.
+func (w *KeystoreWallet) lockState() *walletLock {
+	walletLockAlloc.Lock()
+	defer walletLockAlloc.Unlock()
+	if w.lock == nil {
+		w.lock = new(walletLock)
+	}
+	return w.lock
+}
+
+// SetLockPolicy sets the limits applied to the current and next unlocks
+func (w *KeystoreWallet) SetLockPolicy(policy WalletLockPolicy) {
+	state := w.lockState()
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	state.policy = policy
+	if state.unlocked {
+		state.schedule(w.Keystore)
+	}
+}
+
+// OnLock registers hook to be called after the wallet locks, with the account locked and the reason.
+// Hooks run in the goroutine locking the wallet, which is a timer goroutine for expirations.
+func (w *KeystoreWallet) OnLock(hook func(account accounts.Account, reason LockReason)) {
+	state := w.lockState()
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	state.hooks = append(state.hooks, hook)
+}
+
+// UnlockFor decrypts the key of the wallet account for duration d, or until Lock when d is 0.
+// The WalletLockPolicy may lock it earlier.
+func (w *KeystoreWallet) UnlockFor(passphrase string, d time.Duration) (err error) {
+	return w.unlock(w.Account, passphrase, d)
+}
+
+// Lock removes the decrypted key of the wallet account from memory
+func (w *KeystoreWallet) Lock() (err error) {
+	state := w.lockState()
+	state.mu.Lock()
+	if !state.unlocked {
+		state.mu.Unlock()
+		return w.Keystore.Lock(w.Account.Address)
+	}
+	return w.relock(state, state.generation, LockRequested)
+}
+
+// IsUnlocked tells if the key of the wallet account is decrypted and signatures can be made without passphrase
+func (w *KeystoreWallet) IsUnlocked() bool {
+	state := w.lockState()
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	if !state.unlocked || state.account.Address != w.Account.Address {
+		return false
+	}
+	return state.expires.IsZero() || time.Now().Before(state.expires)
+}
+
+// unlock decrypts the key of account, locking the account unlocked before when it is another one
+func (w *KeystoreWallet) unlock(account accounts.Account, passphrase string, d time.Duration) (err error) {
+	state := w.lockState()
+	state.mu.Lock()
+	if state.unlocked && state.account.Address != account.Address {
+		// relock releases the mutex
+		err = w.relock(state, state.generation, LockRequested)
+		if err != nil {
+			return
+		}
+		state.mu.Lock()
+	}
+	defer state.mu.Unlock()
+	err = w.Keystore.TimedUnlock(account, passphrase, d)
+	if err != nil {
+		return
+	}
+	state.generation++
+	state.unlocked = true
+	state.account = account
+	state.expires = time.Time{}
+	if d > 0 {
+		state.expires = time.Now().Add(d)
+	}
+	state.lastUsed = time.Now()
+	state.signatures = 0
+	state.schedule(w.Keystore)
+	return
+}
+
+// signed counts a signature made with the unlocked key, locking the wallet when the policy limit is reached
+func (w *KeystoreWallet) signed() {
+	state := w.lockState()
+	state.mu.Lock()
+	if !state.unlocked {
+		state.mu.Unlock()
+		return
+	}
+	state.signatures++
+	state.lastUsed = time.Now()
+	if state.policy.MaxSignatures > 0 && state.signatures >= state.policy.MaxSignatures {
+		w.relock(state, state.generation, LockSignatureLimit)
+		return
+	}
+	state.schedule(w.Keystore)
+	state.mu.Unlock()
+}
+
+// schedule arms the timer locking the wallet at expiration or after the idle timeout. It must be called
+// with the mutex held.
+func (l *walletLock) schedule(ks *keystore.KeyStore) {
+	if l.timer != nil {
+		l.timer.Stop()
+		l.timer = nil
+	}
+	var deadline time.Time
+	reason := LockExpired
+	if !l.expires.IsZero() {
+		deadline = l.expires
+	}
+	if l.policy.IdleTimeout > 0 {
+		idle := l.lastUsed.Add(l.policy.IdleTimeout)
+		if deadline.IsZero() || idle.Before(deadline) {
+			deadline = idle
+			reason = LockIdle
+		}
+	}
+	if deadline.IsZero() {
+		return
+	}
+	generation := l.generation
+	wallet := &KeystoreWallet{Account: l.account, Keystore: ks, lock: l}
+	l.timer = time.AfterFunc(time.Until(deadline), func() {
+		l.mu.Lock()
+		wallet.relock(l, generation, reason)
+	})
+}
+
+// relock locks the account of state when generation is still the current one and calls the hooks. It must
+// be called with the mutex held, which it releases.
+func (w *KeystoreWallet) relock(state *walletLock, generation int, reason LockReason) (err error) {
+	if !state.unlocked || state.generation != generation {
+		state.mu.Unlock()
+		return
+	}
+	account := state.account
+	err = w.Keystore.Lock(account.Address)
+	state.generation++
+	state.unlocked = false
+	if state.timer != nil {
+		state.timer.Stop()
+		state.timer = nil
+	}
+	hooks := append([]func(accounts.Account, LockReason){}, state.hooks...)
+	state.mu.Unlock()
+	for _, hook := range hooks {
+		hook(account, reason)
+	}
+	return
+}
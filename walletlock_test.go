@@ -0,0 +1,54 @@
+package goethereumhelper
+
+import (
+	"math/big"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestKeystoreWalletCopiesShareLock(t *testing.T) {
+	ks := OpenKeystore(t.TempDir(), LightScrypt)
+	account, err := CreateKeystoreAccount(ks, "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wallet, err := NewKeystoreWallet(ks, account.Address.Hex(), "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var reasons []LockReason
+	wallet.OnLock(func(account accounts.Account, reason LockReason) {
+		reasons = append(reasons, reason)
+	})
+	wallet.SetLockPolicy(WalletLockPolicy{MaxSignatures: 1})
+
+	// A copy, like the one taken by SendEtherUsingKeystoreWallet, counts its signatures for the original
+	walletCopy := *wallet
+	tx := types.NewTransaction(0, account.Address, big.NewInt(1), 21000, big.NewInt(1), nil)
+	if _, err := walletCopy.SignTx(tx, big.NewInt(1)); err != nil {
+		t.Fatal(err)
+	}
+	if wallet.IsUnlocked() {
+		t.Fatal("wallet still unlocked after reaching the signature limit of its copy")
+	}
+	if len(reasons) != 1 || reasons[0] != LockSignatureLimit {
+		t.Fatalf("lock hooks called with %v", reasons)
+	}
+}
+
+func TestKeystoreWalletLockStateConcurrentUse(t *testing.T) {
+	wallet := &KeystoreWallet{Keystore: OpenKeystore(t.TempDir(), LightScrypt)}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			wallet.SetLockPolicy(WalletLockPolicy{MaxSignatures: 3})
+			wallet.IsUnlocked()
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,419 @@
+package goethereumhelper
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// ErrNoSenderAvailable is returned by WalletPool.Send when every sender has too many pending transactions
+// or not enough balance
+var ErrNoSenderAvailable = errors.New("no sender available")
+
+// TxSigner signs transactions for a single account. KeystoreWallet and PrivateKeySigner implement it.
+type TxSigner interface {
+	Address() common.Address
+	SignTx(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error)
+}
+
+// Address returns the address of the wallet account
+func (w *KeystoreWallet) Address() common.Address {
+	return w.Account.Address
+}
+
+// PrivateKeySigner is a TxSigner holding a private key in memory
+type PrivateKeySigner struct {
+	key     *ecdsa.PrivateKey
+	address common.Address
+}
+
+// NewPrivateKeySigner returns a TxSigner signing with key
+func NewPrivateKeySigner(key *ecdsa.PrivateKey) *PrivateKeySigner {
+	return &PrivateKeySigner{key: key, address: crypto.PubkeyToAddress(key.PublicKey)}
+}
+
+// Address returns the address of the key
+func (s *PrivateKeySigner) Address() common.Address {
+	return s.address
+}
+
+// SignTx signs tx with the key, for chainID
+func (s *PrivateKeySigner) SignTx(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	return types.SignTx(tx, types.LatestSignerForChainID(chainID), s.key)
+}
+
+// WalletPoolClient is the subset of ethclient.Client (or the TestChain) used by WalletPool
+type WalletPoolClient interface {
+	TransactionBackend
+	bind.DeployBackend
+	ethereum.ChainStateReader
+	ethereum.TransactionReader
+}
+
+// WalletPoolConfig sets how a WalletPool picks senders and funds them
+type WalletPoolConfig struct {
+	MaxPending int // Transactions a sender may have waiting to be mined, no limit when 0
+
+	// Treasury funds the senders whose balance falls below MinBalance with TopUpAmount wei.
+	// There is no rebalancing when nil.
+	Treasury    TxSigner
+	MinBalance  *big.Int
+	TopUpAmount *big.Int
+}
+
+// PoolTransaction is a transaction sent by WalletPool.Send from one of its accounts
+type PoolTransaction struct {
+	To       *common.Address // nil deploys a contract
+	Value    *big.Int
+	Data     []byte
+	GasLimit uint64 // Estimated when 0
+}
+
+// WalletPool sends transactions from several accounts, so they are not queued behind the nonce of a
+// single account. It is safe for concurrent use: the node is queried without holding any lock, only the
+// signing and sending of the transactions of one account are serialized, to keep its nonces in order.
+// Concurrent Sends may pick the same sender, so MaxPending can be exceeded by the number of Sends in progress.
+//
+// Example:
+//
+//	pool, err := NewWalletPool(ctx, client, WalletPoolConfig{MaxPending: 4, Treasury: treasury, MinBalance: min, TopUpAmount: topUp}, wallet1, wallet2, NewPrivateKeySigner(key))
+//	tx, err := pool.Send(ctx, PoolTransaction{To: &contract, Data: data})
+type WalletPool struct {
+	client  WalletPoolClient
+	chainID *big.Int
+	config  WalletPoolConfig
+
+	senders  []*poolAccount
+	treasury *poolAccount
+
+	mu   sync.Mutex
+	next int // Index of the sender tried first, to rotate among equally busy senders
+
+	topUpMu sync.Mutex // Serializes the top-ups, so an account is not funded twice
+}
+
+// poolAccount tracks the nonce and the top-up of an account of the pool
+type poolAccount struct {
+	signer TxSigner
+
+	mu          sync.Mutex
+	nonce       uint64
+	nonceLoaded bool
+	mined       uint64 // Nonce of the latest block when the pending transactions were last counted
+
+	topUp common.Hash // Top-up transaction not mined yet, guarded by WalletPool.topUpMu
+}
+
+// NewWalletPool returns a pool sending from senders. The treasury may be one of the senders, it is then
+// never topped up.
+func NewWalletPool(ctx context.Context, client WalletPoolClient, config WalletPoolConfig, senders ...TxSigner) (pool *WalletPool, err error) {
+	if len(senders) == 0 {
+		return nil, errors.New("wallet pool needs at least one sender")
+	}
+	if config.Treasury != nil && (config.MinBalance == nil || config.TopUpAmount == nil) {
+		return nil, errors.New("wallet pool treasury needs MinBalance and TopUpAmount")
+	}
+	chainID, err := client.ChainID(ctx)
+	if err != nil {
+		return
+	}
+	pool = &WalletPool{client: client, chainID: chainID, config: config}
+	// One poolAccount per address, so its nonce is tracked in a single place
+	accounts := make(map[common.Address]*poolAccount)
+	for _, sender := range senders {
+		if accounts[sender.Address()] != nil {
+			return nil, fmt.Errorf("wallet pool sender %s given twice", sender.Address().Hex())
+		}
+		account := &poolAccount{signer: sender}
+		accounts[sender.Address()] = account
+		pool.senders = append(pool.senders, account)
+	}
+	if config.Treasury != nil {
+		pool.treasury = accounts[config.Treasury.Address()]
+		if pool.treasury == nil {
+			pool.treasury = &poolAccount{signer: config.Treasury}
+		}
+	}
+	return
+}
+
+// Senders returns the addresses of the accounts sending the transactions
+func (p *WalletPool) Senders() (addresses []common.Address) {
+	for _, sender := range p.senders {
+		addresses = append(addresses, sender.signer.Address())
+	}
+	return
+}
+
+// Send signs request with the sender having the fewest pending transactions among the ones able to pay
+// for it, rotating among equally busy senders, and sends it as an EIP-1559 transaction, or a legacy one
+// on chains without base fee.
+// Senders whose balance is below MinBalance are topped up by the treasury. A top-up only counts once
+// mined, so a sender is picked on the balance it can spend when the transaction is sent.
+// When no sender can send the transaction because its gas estimation fails, the estimation error, holding
+// the revert reason, is returned instead of ErrNoSenderAvailable.
+func (p *WalletPool) Send(ctx context.Context, request PoolTransaction) (signedTx *types.Transaction, err error) {
+	value := request.Value
+	if value == nil {
+		value = new(big.Int)
+	}
+	fees, err := p.suggestFees(ctx)
+	if err != nil {
+		return
+	}
+
+	p.mu.Lock()
+	first := p.next
+	p.mu.Unlock()
+	var sender *poolAccount
+	var gasLimit uint64
+	var errEstimate, errTopUp error
+	bestPending := uint64(0)
+	for i := range p.senders {
+		candidate := p.senders[(first+i)%len(p.senders)]
+		pending, balance, errState := p.accountState(ctx, candidate)
+		if errState != nil {
+			return nil, errState
+		}
+		if p.treasury != nil && candidate != p.treasury && balance.Cmp(p.config.MinBalance) < 0 {
+			// The candidate may still afford the transaction, and the other senders are tried anyway
+			if errCandidate := p.topUp(ctx, candidate, fees); errCandidate != nil {
+				errTopUp = errCandidate
+			}
+		}
+		if p.config.MaxPending > 0 && pending >= uint64(p.config.MaxPending) {
+			continue
+		}
+		if sender != nil && pending >= bestPending {
+			continue
+		}
+		candidateGas := request.GasLimit
+		if candidateGas == 0 {
+			candidateGas, errState = p.client.EstimateGas(ctx, ethereum.CallMsg{From: candidate.signer.Address(), To: request.To, Value: value, Data: request.Data})
+			if errState != nil {
+				errEstimate = errState
+				continue
+			}
+		}
+		cost := new(big.Int).Add(value, new(big.Int).Mul(fees.maxGasPrice(), new(big.Int).SetUint64(candidateGas)))
+		if balance.Cmp(cost) < 0 {
+			continue
+		}
+		sender, gasLimit, bestPending = candidate, candidateGas, pending
+	}
+	if sender == nil {
+		switch {
+		case errEstimate != nil:
+			return nil, fmt.Errorf("could not estimate gas: %w", errEstimate)
+		case errTopUp != nil:
+			return nil, fmt.Errorf("%w, %v", ErrNoSenderAvailable, errTopUp)
+		}
+		return nil, ErrNoSenderAvailable
+	}
+	p.mu.Lock()
+	for i, candidate := range p.senders {
+		if candidate == sender {
+			p.next = (i + 1) % len(p.senders)
+		}
+	}
+	p.mu.Unlock()
+
+	signedTx, err = p.send(ctx, sender, fees, PoolTransaction{To: request.To, Value: value, Data: request.Data, GasLimit: gasLimit})
+	return
+}
+
+// Rebalance tops up from the treasury every sender whose balance is below MinBalance
+func (p *WalletPool) Rebalance(ctx context.Context) (err error) {
+	if p.treasury == nil {
+		return errors.New("wallet pool has no treasury")
+	}
+	fees, err := p.suggestFees(ctx)
+	if err != nil {
+		return
+	}
+	for _, sender := range p.senders {
+		if sender == p.treasury {
+			continue
+		}
+		_, balance, errState := p.accountState(ctx, sender)
+		if errState != nil {
+			return errState
+		}
+		if balance.Cmp(p.config.MinBalance) < 0 {
+			err = p.topUp(ctx, sender, fees)
+			if err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+// accountState returns the transactions of account not mined yet and its balance in the latest block.
+// When none of them was mined since the previous call, the nonce is read again from the node, which may
+// have dropped some of them, so the account is not counted as busy forever.
+func (p *WalletPool) accountState(ctx context.Context, account *poolAccount) (pending uint64, balance *big.Int, err error) {
+	address := account.signer.Address()
+	mined, err := p.client.NonceAt(ctx, address, nil)
+	if err != nil {
+		return
+	}
+	balance, err = p.client.BalanceAt(ctx, address, nil)
+	if err != nil {
+		return
+	}
+	account.mu.Lock()
+	defer account.mu.Unlock()
+	err = p.loadNonce(ctx, account)
+	if err != nil {
+		return
+	}
+	if account.nonce > mined && mined == account.mined {
+		nonce, errNonce := p.client.PendingNonceAt(ctx, address)
+		if errNonce != nil {
+			return 0, nil, errNonce
+		}
+		if nonce < account.nonce {
+			account.nonce = nonce
+		}
+	}
+	account.mined = mined
+	if account.nonce > mined {
+		pending = account.nonce - mined
+	}
+	return
+}
+
+// loadNonce reads the next nonce of account from the node, unless it is already known. It must be called
+// with the account mutex held.
+func (p *WalletPool) loadNonce(ctx context.Context, account *poolAccount) (err error) {
+	if account.nonceLoaded {
+		return
+	}
+	account.nonce, err = p.client.PendingNonceAt(ctx, account.signer.Address())
+	if err != nil {
+		return
+	}
+	account.nonceLoaded = true
+	return
+}
+
+// topUp sends TopUpAmount wei from the treasury to account, unless a previous top-up is waiting to be
+// mined. A previous top-up dropped by the node is sent again.
+func (p *WalletPool) topUp(ctx context.Context, account *poolAccount, fees poolFees) (err error) {
+	p.topUpMu.Lock()
+	defer p.topUpMu.Unlock()
+	if account.topUp != (common.Hash{}) {
+		_, err = p.client.TransactionReceipt(ctx, account.topUp)
+		if err != nil && !errors.Is(err, ethereum.NotFound) {
+			return
+		}
+		if err != nil {
+			_, _, err = p.client.TransactionByHash(ctx, account.topUp)
+			if !errors.Is(err, ethereum.NotFound) {
+				// Still waiting to be mined
+				return
+			}
+			// The treasury nonce used by the dropped top-up is free again
+			p.treasury.mu.Lock()
+			p.treasury.nonceLoaded = false
+			p.treasury.mu.Unlock()
+		}
+		account.topUp = common.Hash{}
+	}
+	to := account.signer.Address()
+	tx, err := p.send(ctx, p.treasury, fees, PoolTransaction{To: &to, Value: p.config.TopUpAmount, GasLimit: 21000})
+	if err != nil {
+		return fmt.Errorf("could not top up sender %s from treasury: %w", to.Hex(), err)
+	}
+	account.topUp = tx.Hash()
+	return
+}
+
+// poolFees are the fees offered by the transactions of a WalletPool. GasPrice is set instead of the
+// EIP-1559 fees on chains without base fee.
+type poolFees struct {
+	GasTipCap *big.Int
+	GasFeeCap *big.Int
+	GasPrice  *big.Int
+}
+
+// suggestFees returns the fees for the next transactions, twice the base fee of the latest block plus
+// the suggested tip, or the suggested gas price when the chain has no base fee
+func (p *WalletPool) suggestFees(ctx context.Context) (fees poolFees, err error) {
+	head, err := p.client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return
+	}
+	if head.BaseFee == nil {
+		fees.GasPrice, err = p.client.SuggestGasPrice(ctx)
+		return
+	}
+	fees.GasTipCap, err = p.client.SuggestGasTipCap(ctx)
+	if err != nil {
+		return
+	}
+	fees.GasFeeCap = new(big.Int).Add(fees.GasTipCap, new(big.Int).Mul(head.BaseFee, big.NewInt(2)))
+	return
+}
+
+// maxGasPrice returns the highest price per gas the transaction may pay
+func (f poolFees) maxGasPrice() *big.Int {
+	if f.GasPrice != nil {
+		return f.GasPrice
+	}
+	return f.GasFeeCap
+}
+
+// send signs request, with fees and the next nonce of account, and sends it. The account mutex is held
+// until the node accepts it, so the nonces of the account are sent in order.
+func (p *WalletPool) send(ctx context.Context, account *poolAccount, fees poolFees, request PoolTransaction) (signedTx *types.Transaction, err error) {
+	address := account.signer.Address()
+	account.mu.Lock()
+	defer account.mu.Unlock()
+	err = p.loadNonce(ctx, account)
+	if err != nil {
+		return
+	}
+	var txData types.TxData = &types.DynamicFeeTx{
+		ChainID:   p.chainID,
+		Nonce:     account.nonce,
+		GasTipCap: fees.GasTipCap,
+		GasFeeCap: fees.GasFeeCap,
+		Gas:       request.GasLimit,
+		To:        request.To,
+		Value:     request.Value,
+		Data:      request.Data,
+	}
+	if fees.GasPrice != nil {
+		txData = &types.LegacyTx{
+			Nonce:    account.nonce,
+			GasPrice: fees.GasPrice,
+			Gas:      request.GasLimit,
+			To:       request.To,
+			Value:    request.Value,
+			Data:     request.Data,
+		}
+	}
+	signedTx, err = account.signer.SignTx(types.NewTx(txData), p.chainID)
+	if err != nil {
+		return nil, fmt.Errorf("could not sign with %s: %w", address.Hex(), err)
+	}
+	err = p.client.SendTransaction(ctx, signedTx)
+	if err != nil {
+		// The node may know a different nonce, read it again on the next transaction
+		account.nonceLoaded = false
+		return nil, err
+	}
+	account.nonce++
+	return
+}
@@ -0,0 +1,241 @@
+package goethereumhelper
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestWalletPoolTreasuryAsSender(t *testing.T) {
+	chain, err := NewTestChain()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Close()
+	ctx := context.Background()
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	treasury := NewPrivateKeySigner(chain.Keys[0])
+	eth := big.NewInt(1e18)
+	pool, err := NewWalletPool(ctx, chain, WalletPoolConfig{Treasury: treasury, MinBalance: eth, TopUpAmount: new(big.Int).Mul(eth, big.NewInt(2))}, treasury, NewPrivateKeySigner(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewWalletPool(ctx, chain, WalletPoolConfig{}, treasury, treasury); err == nil {
+		t.Fatal("expected an error for a sender given twice")
+	}
+
+	// The empty sender is topped up by the treasury, which also sends the transactions meanwhile
+	to := chain.Account(3)
+	var sent []*types.Transaction
+	for i := 0; i < 3; i++ {
+		tx, err := pool.Send(ctx, PoolTransaction{To: &to, Value: big.NewInt(1)})
+		if err != nil {
+			t.Fatalf("transaction %d: %v", i, err)
+		}
+		sent = append(sent, tx)
+	}
+	chain.Commit()
+	for _, tx := range sent {
+		receipt, err := chain.TransactionReceipt(ctx, tx.Hash())
+		if err != nil || receipt.Status != types.ReceiptStatusSuccessful {
+			t.Fatalf("transaction %s not mined: %v", tx.Hash().Hex(), err)
+		}
+	}
+	nonce, err := chain.NonceAt(ctx, treasury.Address(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nonce != 4 {
+		t.Fatalf("treasury nonce is %d, expected 3 transactions and 1 top-up", nonce)
+	}
+	balance, err := chain.BalanceAt(ctx, crypto.PubkeyToAddress(key.PublicKey), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balance.Cmp(eth) < 0 {
+		t.Fatalf("sender not topped up, balance %s", balance)
+	}
+}
+
+func TestWalletPoolConcurrentSend(t *testing.T) {
+	chain, err := NewTestChain()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Close()
+	ctx := context.Background()
+	pool, err := NewWalletPool(ctx, chain, WalletPoolConfig{}, NewPrivateKeySigner(chain.Keys[0]), NewPrivateKeySigner(chain.Keys[1]), NewPrivateKeySigner(chain.Keys[2]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	to := chain.Account(3)
+	sent := make(chan *types.Transaction, 20)
+	var wg sync.WaitGroup
+	for i := 0; i < cap(sent); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tx, err := pool.Send(ctx, PoolTransaction{To: &to, Value: big.NewInt(1)})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			sent <- tx
+		}()
+	}
+	wg.Wait()
+	close(sent)
+	chain.Commit()
+	for tx := range sent {
+		receipt, err := chain.TransactionReceipt(ctx, tx.Hash())
+		if err != nil || receipt.Status != types.ReceiptStatusSuccessful {
+			t.Fatalf("transaction %s not mined: %v", tx.Hash().Hex(), err)
+		}
+	}
+}
+
+// failingSigner is a TxSigner whose signatures always fail
+type failingSigner struct {
+	TxSigner
+}
+
+func (s failingSigner) SignTx(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	return nil, errors.New("signer unavailable")
+}
+
+func TestWalletPoolEstimationError(t *testing.T) {
+	chain, err := NewTestChain()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Close()
+	ctx := context.Background()
+	reverting := common.HexToAddress("0xbad")
+	if err := chain.SetCode(reverting, common.FromHex("60006000fd")); err != nil {
+		t.Fatal(err)
+	}
+	pool, err := NewWalletPool(ctx, chain, WalletPoolConfig{}, NewPrivateKeySigner(chain.Keys[0]), NewPrivateKeySigner(chain.Keys[1]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = pool.Send(ctx, PoolTransaction{To: &reverting})
+	if err == nil || errors.Is(err, ErrNoSenderAvailable) || !strings.Contains(err.Error(), "execution reverted") {
+		t.Fatalf("expected the estimation error, got %v", err)
+	}
+}
+
+func TestWalletPoolTopUpFailure(t *testing.T) {
+	chain, err := NewTestChain()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Close()
+	ctx := context.Background()
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	empty := NewPrivateKeySigner(key)
+	config := WalletPoolConfig{Treasury: failingSigner{NewPrivateKeySigner(chain.Keys[0])}, MinBalance: big.NewInt(1e18), TopUpAmount: big.NewInt(1e18)}
+	to := chain.Account(3)
+
+	// The empty sender is tried first, its top-up fails and the funded one sends
+	pool, err := NewWalletPool(ctx, chain, config, empty, NewPrivateKeySigner(chain.Keys[1]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err := pool.Send(ctx, PoolTransaction{To: &to, Value: big.NewInt(1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sender, _ := chain.Sender(tx); sender != chain.Account(1) {
+		t.Fatalf("sent by %s, expected the funded sender", sender.Hex())
+	}
+
+	pool, err = NewWalletPool(ctx, chain, config, empty)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = pool.Send(ctx, PoolTransaction{To: &to, Value: big.NewInt(1)})
+	if !errors.Is(err, ErrNoSenderAvailable) || !strings.Contains(err.Error(), "signer unavailable") {
+		t.Fatalf("expected ErrNoSenderAvailable with the top-up error, got %v", err)
+	}
+}
+
+func TestWalletPoolDroppedTopUp(t *testing.T) {
+	chain, err := NewTestChain()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Close()
+	ctx := context.Background()
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	topUp := big.NewInt(1e18)
+	pool, err := NewWalletPool(ctx, chain, WalletPoolConfig{Treasury: NewPrivateKeySigner(chain.Keys[0]), MinBalance: topUp, TopUpAmount: topUp}, NewPrivateKeySigner(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pool.Rebalance(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// The node drops the top-up before mining it
+	chain.DiscardPending()
+	if err := pool.Rebalance(ctx); err != nil {
+		t.Fatal(err)
+	}
+	chain.Commit()
+	balance, err := chain.BalanceAt(ctx, crypto.PubkeyToAddress(key.PublicKey), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balance.Cmp(topUp) != 0 {
+		t.Fatalf("sender balance is %s, expected the top-up sent again", balance)
+	}
+}
+
+func TestWalletPoolNonceResync(t *testing.T) {
+	chain, err := NewTestChain()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Close()
+	ctx := context.Background()
+	pool, err := NewWalletPool(ctx, chain, WalletPoolConfig{MaxPending: 1}, NewPrivateKeySigner(chain.Keys[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	to := chain.Account(3)
+	if _, err := pool.Send(ctx, PoolTransaction{To: &to, Value: big.NewInt(1)}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pool.Send(ctx, PoolTransaction{To: &to, Value: big.NewInt(1)}); !errors.Is(err, ErrNoSenderAvailable) {
+		t.Fatalf("expected ErrNoSenderAvailable with a transaction pending, got %v", err)
+	}
+
+	// The node drops the pending transaction, the sender is not locked out by MaxPending
+	chain.DiscardPending()
+	tx, err := pool.Send(ctx, PoolTransaction{To: &to, Value: big.NewInt(1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tx.Nonce() != 0 {
+		t.Fatalf("sent with nonce %d, expected the dropped nonce 0", tx.Nonce())
+	}
+	chain.Commit()
+	if receipt, err := chain.TransactionReceipt(ctx, tx.Hash()); err != nil || receipt.Status != types.ReceiptStatusSuccessful {
+		t.Fatalf("transaction not mined: %v", err)
+	}
+}
@@ -0,0 +1,621 @@
+package goethereumhelper
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// Headers sent with every webhook request.
+// The timestamp is in unix seconds. The signature is "sha256=" followed by the hex encoded
+// HMAC-SHA256 of "<timestamp>.<body>" using the endpoint secret.
+const (
+	WebhookSignatureHeader = "X-Webhook-Signature"
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+	WebhookDeliveryHeader  = "X-Webhook-Delivery"
+)
+
+// DefaultWebhookDeliveredHistory is the number of delivered events a WebhookDispatcher keeps for Status and Deliveries
+const DefaultWebhookDeliveredHistory = 1000
+
+// DefaultWebhookMaxSkew is the tolerance between the timestamp of a webhook request and the receiver clock
+// suggested for VerifyWebhookSignature
+const DefaultWebhookMaxSkew = 5 * time.Minute
+
+// ErrWebhookDispatcherStopped is returned by Enqueue and Redeliver when Start is not running. The delivery
+// is kept in the store and resumed by the next Start.
+var ErrWebhookDispatcherStopped = errors.New("webhook dispatcher is not running")
+
+// DeliveryStatus is the state of a webhook delivery
+type DeliveryStatus string
+
+// Webhook delivery states
+const (
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliveryDelivered DeliveryStatus = "delivered"
+	DeliveryFailed    DeliveryStatus = "failed"
+)
+
+// WebhookEndpoint is an HTTP endpoint receiving the events observed by a WebhookDispatcher
+type WebhookEndpoint struct {
+	URL    string
+	Secret []byte // Key used to sign the requests
+}
+
+// WebhookPayload is the JSON document POSTed to the endpoints.
+// Big integers are encoded as decimal strings and byte values as 0x prefixed hex strings.
+type WebhookPayload struct {
+	ID          string                 `json:"id"`
+	Event       string                 `json:"event,omitempty"`
+	Signature   string                 `json:"signature,omitempty"`
+	Decoded     bool                   `json:"decoded"`
+	Address     common.Address         `json:"address"`
+	Args        map[string]interface{} `json:"args,omitempty"`
+	BlockNumber uint64                 `json:"blockNumber"`
+	BlockHash   common.Hash            `json:"blockHash"`
+	TxHash      common.Hash            `json:"transactionHash"`
+	LogIndex    uint                   `json:"logIndex"`
+	Removed     bool                   `json:"removed"`
+	Topics      []common.Hash          `json:"topics"`
+	Data        hexutil.Bytes          `json:"data"`
+}
+
+// NewWebhookPayload converts a decoded event into its webhook representation.
+// The ID includes the block hash, so a log included again in another block after a reorg is a new event.
+func NewWebhookPayload(event DecodedEvent) (payload WebhookPayload) {
+	payload = WebhookPayload{
+		ID:          fmt.Sprintf("%s:%s:%d", event.Raw.BlockHash.Hex(), event.Raw.TxHash.Hex(), event.Raw.Index),
+		Event:       event.Name,
+		Signature:   event.Signature,
+		Decoded:     event.Decoded,
+		Address:     event.Address,
+		BlockNumber: event.Raw.BlockNumber,
+		BlockHash:   event.Raw.BlockHash,
+		TxHash:      event.Raw.TxHash,
+		LogIndex:    event.Raw.Index,
+		Removed:     event.Raw.Removed,
+		Topics:      event.Raw.Topics,
+		Data:        event.Raw.Data,
+	}
+	if event.Raw.Removed {
+		payload.ID += ":removed"
+	}
+	payload.Args = event.NormalizedArgs()
+	return
+}
+
+// WebhookDelivery tracks the delivery of one payload to one endpoint
+type WebhookDelivery struct {
+	ID          string         `json:"id"`
+	Endpoint    string         `json:"endpoint"`
+	Payload     WebhookPayload `json:"payload"`
+	Status      DeliveryStatus `json:"status"`
+	Attempts    int            `json:"attempts"`
+	LastError   string         `json:"lastError,omitempty"`
+	NextAttempt time.Time      `json:"nextAttempt"`
+	UpdatedAt   time.Time      `json:"updatedAt"`
+}
+
+// WebhookStore persists the deliveries not yet acknowledged by their endpoints
+type WebhookStore interface {
+	Save(delivery WebhookDelivery) error
+	Delete(id string) error
+	Undelivered() ([]WebhookDelivery, error)
+}
+
+// MemoryWebhookStore keeps undelivered events in memory. They are lost when the process exits.
+type MemoryWebhookStore struct {
+	mu         sync.Mutex
+	deliveries map[string]WebhookDelivery
+}
+
+// NewMemoryWebhookStore returns an empty MemoryWebhookStore
+func NewMemoryWebhookStore() *MemoryWebhookStore {
+	return &MemoryWebhookStore{deliveries: make(map[string]WebhookDelivery)}
+}
+
+// Save implements WebhookStore
+func (s *MemoryWebhookStore) Save(delivery WebhookDelivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deliveries[delivery.ID] = delivery
+	return nil
+}
+
+// Delete implements WebhookStore
+func (s *MemoryWebhookStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.deliveries, id)
+	return nil
+}
+
+// Undelivered implements WebhookStore
+func (s *MemoryWebhookStore) Undelivered() (deliveries []WebhookDelivery, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, delivery := range s.deliveries {
+		deliveries = append(deliveries, delivery)
+	}
+	return
+}
+
+// FileWebhookStore keeps each undelivered event as a JSON file within a directory, so they survive restarts
+type FileWebhookStore struct {
+	dir string
+}
+
+// NewFileWebhookStore returns a FileWebhookStore writing into dir, creating it if needed
+func NewFileWebhookStore(dir string) (store *FileWebhookStore, err error) {
+	err = os.MkdirAll(dir, 0700)
+	if err != nil {
+		err = fmt.Errorf("could not create webhook store directory %s: %w", dir, err)
+		return
+	}
+	store = &FileWebhookStore{dir: dir}
+	return
+}
+
+// Save implements WebhookStore
+func (s *FileWebhookStore) Save(delivery WebhookDelivery) (err error) {
+	content, err := json.Marshal(delivery)
+	if err != nil {
+		return
+	}
+	// Write and rename so a crash never leaves a truncated file behind
+	tmp := filepath.Join(s.dir, delivery.ID+".tmp")
+	err = os.WriteFile(tmp, content, 0600)
+	if err != nil {
+		return
+	}
+	err = os.Rename(tmp, filepath.Join(s.dir, delivery.ID+".json"))
+	return
+}
+
+// Delete implements WebhookStore
+func (s *FileWebhookStore) Delete(id string) (err error) {
+	err = os.Remove(filepath.Join(s.dir, id+".json"))
+	if errors.Is(err, os.ErrNotExist) {
+		err = nil
+	}
+	return
+}
+
+// Undelivered implements WebhookStore
+func (s *FileWebhookStore) Undelivered() (deliveries []WebhookDelivery, err error) {
+	files, err := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	if err != nil {
+		return
+	}
+	for _, file := range files {
+		content, errRead := os.ReadFile(file)
+		if errRead != nil {
+			err = errRead
+			return
+		}
+		var delivery WebhookDelivery
+		err = json.Unmarshal(content, &delivery)
+		if err != nil {
+			err = fmt.Errorf("corrupted webhook delivery file %s: %w", file, err)
+			return
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return
+}
+
+// WebhookDispatcher POSTs the events observed on the watched filters to HTTP endpoints.
+// Every event is delivered to every endpoint; failed deliveries are retried with exponential backoff
+// and kept in the store until they succeed or MaxAttempts is reached.
+type WebhookDispatcher struct {
+	MaxAttempts    int           // Attempts before a delivery is marked as failed. Zero means retry forever
+	InitialBackoff time.Duration // Delay before the first retry, doubled on every new attempt
+	MaxBackoff     time.Duration // Upper bound for the retry delay
+	// Delivered events kept for Status and Deliveries, the oldest are forgotten first. Pending and failed
+	// deliveries are always kept.
+	DeliveredHistory int
+	HTTPClient       *http.Client
+
+	client    ethereum.LogFilterer
+	decoder   *EventDecoder
+	store     WebhookStore
+	endpoints []WebhookEndpoint
+
+	mu         sync.Mutex
+	deliveries map[string]WebhookDelivery
+	delivered  []string // IDs of the delivered entries of deliveries, oldest first
+	inflight   map[string]bool
+	queue      chan WebhookDelivery
+	running    bool
+	stopped    chan struct{} // Closed when Start returns, nil when it is not running
+	wg         sync.WaitGroup
+}
+
+// NewWebhookDispatcher returns a dispatcher that decodes the logs read from client with decoder and
+// delivers them to endpoints. A nil store keeps undelivered events in memory only.
+func NewWebhookDispatcher(client ethereum.LogFilterer, decoder *EventDecoder, store WebhookStore, endpoints ...WebhookEndpoint) *WebhookDispatcher {
+	if decoder == nil {
+		decoder = NewEventDecoder()
+	}
+	if store == nil {
+		store = NewMemoryWebhookStore()
+	}
+	return &WebhookDispatcher{
+		MaxAttempts:      10,
+		InitialBackoff:   time.Second,
+		MaxBackoff:       5 * time.Minute,
+		DeliveredHistory: DefaultWebhookDeliveredHistory,
+		HTTPClient:       &http.Client{Timeout: 10 * time.Second},
+		client:           client,
+		decoder:          decoder,
+		store:            store,
+		endpoints:        endpoints,
+		deliveries:       make(map[string]WebhookDelivery),
+		inflight:         make(map[string]bool),
+		queue:            make(chan WebhookDelivery, 256),
+	}
+}
+
+// Start resumes the deliveries left in the store and delivers queued events until ctx is cancelled.
+// Deliveries still pending when it returns remain in the store and are resumed by the next Start.
+func (d *WebhookDispatcher) Start(ctx context.Context) (err error) {
+	d.mu.Lock()
+	if d.running {
+		d.mu.Unlock()
+		return errors.New("webhook dispatcher already started")
+	}
+	d.running = true
+	stopped := make(chan struct{})
+	d.stopped = stopped
+	d.mu.Unlock()
+	defer func() {
+		d.mu.Lock()
+		d.stopped = nil
+		close(stopped)
+		d.mu.Unlock()
+		// The queued deliveries are in the store, the next Start resumes them
+		for len(d.queue) > 0 {
+			<-d.queue
+		}
+		d.mu.Lock()
+		d.running = false
+		d.mu.Unlock()
+	}()
+
+	undelivered, err := d.store.Undelivered()
+	if err != nil {
+		log.Printf("[WebhookDispatcher] Failure loading undelivered events: %+v", err)
+		return
+	}
+	sort.Slice(undelivered, func(i, j int) bool {
+		return undelivered[i].UpdatedAt.Before(undelivered[j].UpdatedAt)
+	})
+	d.mu.Lock()
+	for _, delivery := range undelivered {
+		d.deliveries[delivery.ID] = delivery
+	}
+	d.mu.Unlock()
+	for _, delivery := range undelivered {
+		if delivery.Status == DeliveryPending {
+			d.spawn(ctx, delivery)
+		}
+	}
+
+	for {
+		select {
+		case delivery := <-d.queue:
+			d.spawn(ctx, delivery)
+		case <-ctx.Done():
+			d.wg.Wait()
+			return ctx.Err()
+		}
+	}
+}
+
+// Watch subscribes to the logs matching query and enqueues them for delivery.
+// The returned subscription follows the WatchDecodedLogs lifecycle.
+func (d *WebhookDispatcher) Watch(ctx context.Context, query ethereum.FilterQuery) (sub ethereum.Subscription, err error) {
+	events := make(chan DecodedEvent)
+	logSub, err := WatchDecodedLogs(ctx, d.client, query, d.decoder, events)
+	if err != nil {
+		return
+	}
+	go func() {
+		for {
+			select {
+			case event := <-events:
+				if err := d.Enqueue(event); err != nil {
+					log.Printf("[WebhookDispatcher] Failure persisting event %s: %+v", NewWebhookPayload(event).ID, err)
+				}
+			case <-logSub.Err():
+				return
+			}
+		}
+	}()
+	sub = logSub
+	return
+}
+
+// Enqueue persists the event once per endpoint and schedules its delivery. When Start is not running it
+// returns ErrWebhookDispatcherStopped, the event being delivered by the next Start.
+func (d *WebhookDispatcher) Enqueue(event DecodedEvent) (err error) {
+	payload := NewWebhookPayload(event)
+	for _, endpoint := range d.endpoints {
+		delivery := WebhookDelivery{
+			ID:        webhookDeliveryID(payload.ID, endpoint.URL),
+			Endpoint:  endpoint.URL,
+			Payload:   payload,
+			Status:    DeliveryPending,
+			UpdatedAt: time.Now(),
+		}
+		if errSave := d.store.Save(delivery); errSave != nil {
+			err = errSave
+			return
+		}
+		d.setDelivery(delivery)
+		// Keep persisting the other endpoints when stopped, so the next Start delivers to all of them
+		if errSchedule := d.schedule(delivery); errSchedule != nil {
+			err = errSchedule
+		}
+	}
+	return
+}
+
+// Redeliver schedules a failed delivery again, resetting its attempts counter
+func (d *WebhookDispatcher) Redeliver(id string) (err error) {
+	delivery, ok := d.Status(id)
+	if !ok {
+		err = fmt.Errorf("webhook delivery %s not found", id)
+		return
+	}
+	delivery.Status = DeliveryPending
+	delivery.Attempts = 0
+	delivery.NextAttempt = time.Time{}
+	err = d.store.Save(delivery)
+	if err != nil {
+		return
+	}
+	d.setDelivery(delivery)
+	err = d.schedule(delivery)
+	return
+}
+
+// schedule hands delivery to Start, failing with ErrWebhookDispatcherStopped when it is not running
+func (d *WebhookDispatcher) schedule(delivery WebhookDelivery) (err error) {
+	d.mu.Lock()
+	stopped := d.stopped
+	d.mu.Unlock()
+	if stopped == nil {
+		return ErrWebhookDispatcherStopped
+	}
+	select {
+	case d.queue <- delivery:
+	case <-stopped:
+		return ErrWebhookDispatcherStopped
+	}
+	return
+}
+
+// Status returns the current state of a delivery. Delivered events are forgotten after DeliveredHistory newer ones.
+func (d *WebhookDispatcher) Status(id string) (delivery WebhookDelivery, ok bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delivery, ok = d.deliveries[id]
+	return
+}
+
+// Deliveries returns the state of every delivery known by the dispatcher, oldest first
+func (d *WebhookDispatcher) Deliveries() (deliveries []WebhookDelivery) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, delivery := range d.deliveries {
+		deliveries = append(deliveries, delivery)
+	}
+	sort.Slice(deliveries, func(i, j int) bool {
+		return deliveries[i].UpdatedAt.Before(deliveries[j].UpdatedAt)
+	})
+	return
+}
+
+func (d *WebhookDispatcher) setDelivery(delivery WebhookDelivery) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.deliveries[delivery.ID] = delivery
+	if delivery.Status != DeliveryDelivered {
+		return
+	}
+	// Acknowledged events are only kept for the status API, forget the oldest ones
+	d.delivered = append(d.delivered, delivery.ID)
+	for len(d.delivered) > 0 && len(d.delivered) > d.DeliveredHistory {
+		id := d.delivered[0]
+		d.delivered = d.delivered[1:]
+		if d.deliveries[id].Status == DeliveryDelivered {
+			delete(d.deliveries, id)
+		}
+	}
+}
+
+// spawn starts delivering in background, unless the same delivery is already in progress or is no longer pending
+func (d *WebhookDispatcher) spawn(ctx context.Context, delivery WebhookDelivery) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.inflight[delivery.ID] {
+		return
+	}
+	if current, ok := d.deliveries[delivery.ID]; !ok || current.Status != DeliveryPending {
+		return
+	}
+	d.inflight[delivery.ID] = true
+	d.wg.Add(1)
+	go func() {
+		defer func() {
+			d.mu.Lock()
+			delete(d.inflight, delivery.ID)
+			d.mu.Unlock()
+			d.wg.Done()
+		}()
+		d.deliver(ctx, delivery)
+	}()
+}
+
+// deliver attempts to POST the payload until it succeeds, it runs out of attempts or ctx is cancelled
+func (d *WebhookDispatcher) deliver(ctx context.Context, delivery WebhookDelivery) {
+	endpoint, ok := d.endpoint(delivery.Endpoint)
+	if !ok {
+		log.Printf("[WebhookDispatcher] Endpoint %s of delivery %s is no longer configured", delivery.Endpoint, delivery.ID)
+		return
+	}
+	for {
+		if wait := time.Until(delivery.NextAttempt); wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			}
+		}
+
+		delivery.Attempts++
+		err := d.post(ctx, endpoint, delivery)
+		delivery.UpdatedAt = time.Now()
+		if err == nil {
+			delivery.Status = DeliveryDelivered
+			delivery.LastError = ""
+			d.setDelivery(delivery)
+			if err := d.store.Delete(delivery.ID); err != nil {
+				log.Printf("[WebhookDispatcher] Failure removing delivered event %s from store: %+v", delivery.ID, err)
+			}
+			return
+		}
+		if ctx.Err() != nil {
+			return
+		}
+
+		delivery.LastError = err.Error()
+		if d.MaxAttempts > 0 && delivery.Attempts >= d.MaxAttempts {
+			delivery.Status = DeliveryFailed
+			log.Printf("[WebhookDispatcher] Delivery %s to %s failed after %d attempts: %+v", delivery.ID, delivery.Endpoint, delivery.Attempts, err)
+		} else {
+			delivery.NextAttempt = delivery.UpdatedAt.Add(d.backoff(delivery.Attempts))
+		}
+		d.setDelivery(delivery)
+		if err := d.store.Save(delivery); err != nil {
+			log.Printf("[WebhookDispatcher] Failure persisting delivery %s: %+v", delivery.ID, err)
+		}
+		if delivery.Status == DeliveryFailed {
+			return
+		}
+	}
+}
+
+func (d *WebhookDispatcher) backoff(attempts int) (delay time.Duration) {
+	delay = d.InitialBackoff
+	for i := 1; i < attempts && delay < d.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if d.MaxBackoff > 0 && delay > d.MaxBackoff {
+		delay = d.MaxBackoff
+	}
+	return
+}
+
+func (d *WebhookDispatcher) endpoint(url string) (endpoint WebhookEndpoint, ok bool) {
+	for _, endpoint = range d.endpoints {
+		if endpoint.URL == url {
+			return endpoint, true
+		}
+	}
+	return
+}
+
+func (d *WebhookDispatcher) post(ctx context.Context, endpoint WebhookEndpoint, delivery WebhookDelivery) (err error) {
+	body, err := json.Marshal(delivery.Payload)
+	if err != nil {
+		return
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.URL, bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(WebhookDeliveryHeader, delivery.ID)
+	request.Header.Set(WebhookTimestampHeader, timestamp)
+	request.Header.Set(WebhookSignatureHeader, SignWebhookPayload(endpoint.Secret, timestamp, body))
+
+	response, err := d.HTTPClient.Do(request)
+	if err != nil {
+		return
+	}
+	defer response.Body.Close()
+	_, _ = io.Copy(io.Discard, response.Body)
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		err = fmt.Errorf("endpoint answered with HTTP status %d", response.StatusCode)
+	}
+	return
+}
+
+// SignWebhookPayload returns the signature sent in the WebhookSignatureHeader for body
+func SignWebhookPayload(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhookSignature checks the signature of a request received from a WebhookDispatcher.
+// Requests whose timestamp is more than maxSkew away from now are rejected, so captured requests
+// cannot be replayed later.
+func VerifyWebhookSignature(secret []byte, timestamp string, body []byte, signature string, maxSkew time.Duration) (err error) {
+	if !hmac.Equal([]byte(SignWebhookPayload(secret, timestamp, body)), []byte(signature)) {
+		return errors.New("invalid webhook signature")
+	}
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid webhook timestamp %q: %w", timestamp, err)
+	}
+	skew := time.Since(time.Unix(unix, 0))
+	if skew < 0 {
+		skew = -skew
+	}
+	if skew > maxSkew {
+		return fmt.Errorf("webhook timestamp %s is off by %s", timestamp, skew.Round(time.Second))
+	}
+	return
+}
+
+// VerifyWebhookRequest reads the body of a request received from a WebhookDispatcher and checks its signature
+func VerifyWebhookRequest(r *http.Request, secret []byte, maxSkew time.Duration) (body []byte, err error) {
+	body, err = io.ReadAll(r.Body)
+	if err != nil {
+		err = fmt.Errorf("could not read webhook body: %w", err)
+		return
+	}
+	err = VerifyWebhookSignature(secret, r.Header.Get(WebhookTimestampHeader), body, r.Header.Get(WebhookSignatureHeader), maxSkew)
+	return
+}
+
+func webhookDeliveryID(eventID, endpointURL string) string {
+	sum := sha256.Sum256([]byte(eventID + "|" + endpointURL))
+	return hex.EncodeToString(sum[:16])
+}
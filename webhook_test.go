@@ -0,0 +1,139 @@
+package goethereumhelper
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestWebhookDispatcher(t *testing.T) {
+	secret := []byte("secret")
+	received := make(chan WebhookPayload, 10)
+	var mu sync.Mutex
+	calls := 0
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := VerifyWebhookRequest(r, secret, DefaultWebhookMaxSkew)
+		if err != nil {
+			t.Errorf("request not verified: %v", err)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		mu.Lock()
+		calls++
+		first := calls == 1
+		mu.Unlock()
+		// Fail the first request to exercise the retry
+		if first {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var payload WebhookPayload
+		if err := json.Unmarshal(body, &payload); err != nil {
+			t.Errorf("invalid payload: %v", err)
+		}
+		received <- payload
+	}))
+	defer receiver.Close()
+
+	dispatcher := NewWebhookDispatcher(nil, nil, nil, WebhookEndpoint{URL: receiver.URL, Secret: secret})
+	dispatcher.InitialBackoff = 10 * time.Millisecond
+	dispatcher.DeliveredHistory = 1
+	event := DecodedEvent{
+		Address: common.HexToAddress("0x20"),
+		Raw: types.Log{
+			Address:   common.HexToAddress("0x20"),
+			BlockHash: common.HexToHash("0xb1"),
+			TxHash:    common.HexToHash("0x71"),
+			Index:     3,
+		},
+	}
+	if err := dispatcher.Enqueue(event); !errors.Is(err, ErrWebhookDispatcherStopped) {
+		t.Fatalf("expected ErrWebhookDispatcherStopped, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- dispatcher.Start(ctx) }()
+	payload := waitWebhookPayload(t, received)
+	if payload.ID != NewWebhookPayload(event).ID || payload.LogIndex != 3 {
+		t.Fatalf("unexpected payload %+v", payload)
+	}
+
+	// The same log included in another block after a reorg is a new event
+	reincluded := event
+	reincluded.Raw.BlockHash = common.HexToHash("0xb2")
+	if NewWebhookPayload(reincluded).ID == payload.ID {
+		t.Fatal("reincluded log has the ID of the original")
+	}
+	for {
+		err := dispatcher.Enqueue(reincluded)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, ErrWebhookDispatcherStopped) {
+			t.Fatal(err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if payload = waitWebhookPayload(t, received); payload.BlockHash != reincluded.Raw.BlockHash {
+		t.Fatalf("unexpected payload %+v", payload)
+	}
+
+	// Only the last delivered event is remembered
+	reincludedID := webhookDeliveryID(NewWebhookPayload(reincluded).ID, receiver.URL)
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(time.Millisecond) {
+		if delivery, _ := dispatcher.Status(reincludedID); delivery.Status == DeliveryDelivered {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("reincluded event not marked as delivered")
+		}
+	}
+	if _, ok := dispatcher.Status(webhookDeliveryID(NewWebhookPayload(event).ID, receiver.URL)); ok {
+		t.Fatal("oldest delivered event still kept")
+	}
+	if deliveries := dispatcher.Deliveries(); len(deliveries) != 1 {
+		t.Fatalf("%d deliveries kept, expected 1", len(deliveries))
+	}
+
+	cancel()
+	<-done
+	if err := dispatcher.Enqueue(event); !errors.Is(err, ErrWebhookDispatcherStopped) {
+		t.Fatalf("expected ErrWebhookDispatcherStopped after Start returned, got %v", err)
+	}
+}
+
+func TestVerifyWebhookSignature(t *testing.T) {
+	secret := []byte("secret")
+	body := []byte(`{"id":"1"}`)
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	if err := VerifyWebhookSignature(secret, now, body, SignWebhookPayload(secret, now, body), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyWebhookSignature([]byte("other"), now, body, SignWebhookPayload(secret, now, body), time.Minute); err == nil {
+		t.Fatal("expected an error for another secret")
+	}
+	stale := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+	if err := VerifyWebhookSignature(secret, stale, body, SignWebhookPayload(secret, stale, body), time.Minute); err == nil {
+		t.Fatal("expected an error for a stale timestamp")
+	}
+}
+
+func waitWebhookPayload(t *testing.T, received chan WebhookPayload) (payload WebhookPayload) {
+	t.Helper()
+	select {
+	case payload = <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook not delivered")
+	}
+	return
+}